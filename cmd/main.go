@@ -4,7 +4,9 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"strings"
 
 	"github.com/gden173/geotiff/geotiff"
 )
@@ -16,18 +18,105 @@ var geoHelp = flag.Bool("help", false, "Print the help message.")
 //
 // Prints the command line arguments usage
 func usage() {
-	fmt.Fprint(os.Stderr, "Usage: geotiff  [options] <geotiff> \n\n")
+	fmt.Fprint(os.Stderr, "Usage: geotiff  [options] <geotiff> \n")
+	fmt.Fprint(os.Stderr, "       geotiff serve [options] <geotiff>... \n\n")
 	fmt.Fprint(os.Stderr, "Options:\n\n")
 	flag.PrintDefaults()
 	os.Exit(2)
 }
 
+// serve
+//
+// Serves one or more geotiffs as Web Mercator tiles at /{z}/{x}/{y}.png
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "The address to listen on.")
+	palette := fs.String("palette", geotiff.Greys.String(), "The colour ramp, one of greys, viridis, inferno or terrain.")
+	stretch := fs.String("stretch", geotiff.StretchLinear.String(), "How values are spread over the ramp, one of linear, percentile or equalize.")
+	resampling := fs.String("resampling", geotiff.Nearest.String(), "The resampling, one of nearest, bilinear, cubic or lanczos.")
+	cache := fs.Int("cache", 1024, "The number of tiles cached, or -1 to disable caching.")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "Usage: geotiff serve [options] <geotiff>... \n\n")
+		fmt.Fprint(os.Stderr, "Options:\n\n")
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+	}
+
+	opts := &geotiff.TileOptions{Render: &geotiff.RenderOptions{}, CacheSize: *cache}
+	var ok bool
+	if opts.Render.Palette, ok = parseLabel(*palette, []geotiff.Palette{geotiff.Greys, geotiff.Viridis, geotiff.Inferno, geotiff.Terrain}); !ok {
+		fmt.Fprintf(os.Stderr, "[ERROR] Unrecognized palette %s\n", *palette)
+		fs.Usage()
+	}
+	if opts.Render.Stretch, ok = parseLabel(*stretch, []geotiff.Stretch{geotiff.StretchLinear, geotiff.StretchPercentile, geotiff.StretchEqualize}); !ok {
+		fmt.Fprintf(os.Stderr, "[ERROR] Unrecognized stretch %s\n", *stretch)
+		fs.Usage()
+	}
+	if opts.Resampling, ok = parseLabel(*resampling, []geotiff.Resampling{geotiff.Nearest, geotiff.Bilinear, geotiff.Cubic, geotiff.Lanczos}); !ok {
+		fmt.Fprintf(os.Stderr, "[ERROR] Unrecognized resampling %s\n", *resampling)
+		fs.Usage()
+	}
+
+	images := make([]*geotiff.GeoTIFF, 0, fs.NArg())
+	for _, name := range fs.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+			os.Exit(1)
+		}
+		gtiff, err := geotiff.Read(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[ERROR] %s: %s\n", name, err)
+			os.Exit(1)
+		}
+		images = append(images, gtiff)
+	}
+
+	server, err := geotiff.NewTileServer(images, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+		os.Exit(1)
+	}
+	host := *addr
+	if strings.HasPrefix(host, ":") {
+		host = "localhost" + host
+	}
+	fmt.Printf("Serving tiles at http://%s/{z}/{x}/{y}.png\n", host)
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseLabel
+//
+// Returns the value whose label is s
+func parseLabel[T fmt.Stringer](s string, values []T) (T, bool) {
+	for _, v := range values {
+		if v.String() == s {
+			return v, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
 func main() {
 
 	if len(os.Args) == 1 {
 		usage()
 	}
 
+	if os.Args[1] == "serve" {
+		serve(os.Args[2:])
+		return
+	}
+
 	flag.Parse()
 
 	if *geoHelp {
@@ -0,0 +1,534 @@
+package geotiff
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var errCalc = errors.New("unable to evaluate expression")
+
+// CalcOptions configures Calculate
+type CalcOptions struct {
+	// NoData is the nodata value of the result.
+	//
+	// Defaults to the nodata value of the input whose name sorts first, or
+	// NaN if it has none
+	NoData *float64
+}
+
+// Calculate evaluates a map algebra expression pixel by pixel over aligned
+// images, named by the variables of the expression, analogous to
+// gdal_calc.py
+//
+// Expressions are made of numbers, the names of the inputs, the constants
+// pi and e, parentheses and, from the lowest to the highest precedence, the
+// operators
+//
+//	||
+//	&&
+//	==  !=  <  <=  >  >=
+//	+  -
+//	*  /  %
+//	-  +  !  (unary)
+//	^  (power, right associative)
+//
+// Comparisons and logical operators give 1 for true and 0 for false, and
+// treat any value other than 0 as true. The functions are abs, sqrt, exp,
+// log, log10, sin, cos, tan, asin, acos, atan, atan2, floor, ceil, round,
+// pow, min, max and hypot, along with where(condition, a, b), which gives a
+// where the condition is true and b elsewhere, and isnodata(x), which gives
+// 1 where x is nodata and 0 elsewhere. For example
+//
+//	(A - B) / (A + B)
+//	where(A > 100 && !isnodata(B), 1, 0)
+//
+// Nodata pixels of any input make the result nodata, except through
+// isnodata or a branch of where which is not taken, as do results which are
+// not finite, such as division by zero.
+//
+// The inputs must share a coordinate reference system and pixel grid, and
+// the result takes the tags of the input whose name sorts first. Input
+// names take precedence over the constants. opts may be nil.
+func Calculate(expression string, inputs map[string]*GeoTIFF, opts *CalcOptions) (*GeoTIFF, error) {
+	if opts == nil {
+		opts = &CalcOptions{}
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("%w: no inputs", errCalc)
+	}
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	first := inputs[names[0]]
+	crs, err := first.CRS()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %s", errCalc, names[0], err)
+	}
+	bounds, err := first.Bounds()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %s", errCalc, names[0], err)
+	}
+	width, length := int(first.imageWidth), int(first.imageLength)
+
+	// Each input is read as float64, with NaN for nodata
+	rasters := make(map[string][]float64, len(inputs))
+	for _, name := range names {
+		g := inputs[name]
+		c, err := g.CRS()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %s", errCalc, name, err)
+		}
+		b, err := g.Bounds()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %s", errCalc, name, err)
+		}
+		// The tolerance allows for rounding error in the georeferencing
+		const tolerance = 1e-6
+		if !c.Equal(crs) || int(g.imageWidth) != width || int(g.imageLength) != length ||
+			math.Abs(g.PixelScaleX/first.PixelScaleX-1) > tolerance || math.Abs(g.PixelScaleY/first.PixelScaleY-1) > tolerance ||
+			math.Abs(b.UpperLeft.Lon-bounds.UpperLeft.Lon) > tolerance*first.PixelScaleX ||
+			math.Abs(b.UpperLeft.Lat-bounds.UpperLeft.Lat) > tolerance*first.PixelScaleY {
+			return nil, fmt.Errorf("%w: %s is not on the pixel grid of %s", errCalc, name, names[0])
+		}
+		nd := g.noData()
+		raster := g.raster()
+		values := make([]float64, len(raster))
+		for k, v := range raster {
+			values[k] = float64(v)
+			if nd.is(v) {
+				values[k] = math.NaN()
+			}
+		}
+		rasters[name] = values
+	}
+
+	p := &parser{input: expression, rasters: rasters}
+	p.next()
+	eval, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if p.err != nil || p.token.kind != tokenEnd {
+		return nil, p.unexpected()
+	}
+
+	out := first.noData()
+	outValue, _ := first.NoData()
+	if opts.NoData != nil {
+		outValue = *opts.NoData
+		out = noData{value: float32(outValue), set: true}
+	}
+	raster := make([]float32, width*length)
+	for k := range raster {
+		v := eval(k)
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			raster[k] = out.fill()
+			continue
+		}
+		raster[k] = float32(v)
+	}
+	g := first.derive(raster, width, length, bounds.UpperLeft, first.PixelScaleX, first.PixelScaleY)
+	if out.set {
+		g.SetNoData(outValue)
+	}
+	return g, nil
+}
+
+// evaluator returns the value of an expression at a pixel, NaN for nodata
+type evaluator func(k int) float64
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenNumber
+	tokenName
+	tokenOperator
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value float64
+	pos   int
+}
+
+// operators are the operator tokens, longest first
+var operators = []string{"||", "&&", "==", "!=", "<=", ">=", "<", ">", "+", "-", "*", "/", "%", "^", "!", "(", ")", ","}
+
+// parser compiles an expression by recursive descent, with a method for
+// each level of precedence
+type parser struct {
+	input   string
+	pos     int
+	token   token
+	rasters map[string][]float64
+	err     error
+}
+
+// next reads the next token, recording the first error
+func (p *parser) next() {
+	for p.pos < len(p.input) && strings.ContainsRune(" \t\r\n", rune(p.input[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos == len(p.input) {
+		p.token = token{kind: tokenEnd, pos: start}
+		return
+	}
+
+	c := p.input[p.pos]
+	switch {
+	case c >= '0' && c <= '9' || c == '.':
+		for p.pos < len(p.input) && (isDigit(p.input[p.pos]) || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		// An exponent, such as 1e-3
+		if p.pos < len(p.input) && (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') {
+			end := p.pos + 1
+			if end < len(p.input) && (p.input[end] == '+' || p.input[end] == '-') {
+				end++
+			}
+			if end < len(p.input) && isDigit(p.input[end]) {
+				p.pos = end
+				for p.pos < len(p.input) && isDigit(p.input[p.pos]) {
+					p.pos++
+				}
+			}
+		}
+		text := p.input[start:p.pos]
+		v, err := strconv.ParseFloat(text, 64)
+		if err != nil && p.err == nil {
+			p.err = fmt.Errorf("%w: invalid number %q at position %d", errCalc, text, start)
+		}
+		p.token = token{kind: tokenNumber, text: text, value: v, pos: start}
+		return
+	case isLetter(c):
+		for p.pos < len(p.input) && (isLetter(p.input[p.pos]) || isDigit(p.input[p.pos])) {
+			p.pos++
+		}
+		p.token = token{kind: tokenName, text: p.input[start:p.pos], pos: start}
+		return
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(p.input[p.pos:], op) {
+			p.pos += len(op)
+			p.token = token{kind: tokenOperator, text: op, pos: start}
+			return
+		}
+	}
+	if p.err == nil {
+		p.err = fmt.Errorf("%w: unexpected %q at position %d", errCalc, c, start)
+	}
+	p.token = token{kind: tokenEnd, pos: start}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// accept reads the current token if it is the operator op
+func (p *parser) accept(op string) bool {
+	if p.token.kind == tokenOperator && p.token.text == op {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// unexpected returns an error for the current token
+func (p *parser) unexpected() error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.token.kind == tokenEnd {
+		return fmt.Errorf("%w: unexpected end of expression", errCalc)
+	}
+	return fmt.Errorf("%w: unexpected %q at position %d", errCalc, p.token.text, p.token.pos)
+}
+
+// binary parses a left associative level of precedence with the given
+// operators, each operand parsed by operand
+func (p *parser) binary(operand func() (evaluator, error), ops map[string]func(a, b float64) float64) (evaluator, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.token.kind == tokenOperator {
+		f, ok := ops[p.token.text]
+		if !ok {
+			break
+		}
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(k int) float64 { return f(l(k), right(k)) }
+	}
+	return left, nil
+}
+
+// truth returns 1 for true and 0 for false
+func truth(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// pow returns a raised to the power b, or nodata if either is nodata, as
+// math.Pow gives 1 for Pow(NaN, 0) and Pow(1, NaN)
+func pow(a, b float64) float64 {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.NaN()
+	}
+	return math.Pow(a, b)
+}
+
+// logical returns a logical or comparison operator, which gives nodata if
+// either operand is nodata
+func logical(f func(a, b float64) bool) func(a, b float64) float64 {
+	return func(a, b float64) float64 {
+		if math.IsNaN(a) || math.IsNaN(b) {
+			return math.NaN()
+		}
+		return truth(f(a, b))
+	}
+}
+
+func (p *parser) expression() (evaluator, error) {
+	return p.binary(p.and, map[string]func(a, b float64) float64{
+		"||": logical(func(a, b float64) bool { return a != 0 || b != 0 }),
+	})
+}
+
+func (p *parser) and() (evaluator, error) {
+	return p.binary(p.comparison, map[string]func(a, b float64) float64{
+		"&&": logical(func(a, b float64) bool { return a != 0 && b != 0 }),
+	})
+}
+
+func (p *parser) comparison() (evaluator, error) {
+	return p.binary(p.sum, map[string]func(a, b float64) float64{
+		"==": logical(func(a, b float64) bool { return a == b }),
+		"!=": logical(func(a, b float64) bool { return a != b }),
+		"<":  logical(func(a, b float64) bool { return a < b }),
+		"<=": logical(func(a, b float64) bool { return a <= b }),
+		">":  logical(func(a, b float64) bool { return a > b }),
+		">=": logical(func(a, b float64) bool { return a >= b }),
+	})
+}
+
+func (p *parser) sum() (evaluator, error) {
+	return p.binary(p.product, map[string]func(a, b float64) float64{
+		"+": func(a, b float64) float64 { return a + b },
+		"-": func(a, b float64) float64 { return a - b },
+	})
+}
+
+func (p *parser) product() (evaluator, error) {
+	return p.binary(p.unary, map[string]func(a, b float64) float64{
+		"*": func(a, b float64) float64 { return a * b },
+		"/": func(a, b float64) float64 { return a / b },
+		"%": math.Mod,
+	})
+}
+
+func (p *parser) unary() (evaluator, error) {
+	switch {
+	case p.accept("-"):
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(k int) float64 { return -f(k) }, nil
+	case p.accept("+"):
+		return p.unary()
+	case p.accept("!"):
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(k int) float64 {
+			v := f(k)
+			if math.IsNaN(v) {
+				return v
+			}
+			return truth(v == 0)
+		}, nil
+	}
+	return p.power()
+}
+
+// power parses a power, which binds more tightly than a unary operator on
+// its left, so -2^2 is -4, but not on its right, so 2^-1 is 0.5
+func (p *parser) power() (evaluator, error) {
+	base, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept("^") {
+		return base, nil
+	}
+	exponent, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return func(k int) float64 { return pow(base(k), exponent(k)) }, nil
+}
+
+func (p *parser) primary() (evaluator, error) {
+	t := p.token
+	switch {
+	case p.err != nil:
+		return nil, p.err
+	case t.kind == tokenNumber:
+		p.next()
+		return func(int) float64 { return t.value }, nil
+	case p.accept("("):
+		f, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.unexpected()
+		}
+		return f, nil
+	case t.kind != tokenName:
+		return nil, p.unexpected()
+	}
+
+	p.next()
+	if p.accept("(") {
+		return p.call(t)
+	}
+	if values, ok := p.rasters[t.text]; ok {
+		return func(k int) float64 { return values[k] }, nil
+	}
+	if v, ok := calcConstants[t.text]; ok {
+		return func(int) float64 { return v }, nil
+	}
+	return nil, fmt.Errorf("%w: unknown input %q at position %d", errCalc, t.text, t.pos)
+}
+
+// calcConstants are the named constants of expressions
+var calcConstants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+// calcFunctions are the functions of expressions with a fixed number of
+// arguments, which propagate nodata as NaN
+var calcFunctions = map[string]struct {
+	args int
+	f    func(a []float64) float64
+}{
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"exp":   {1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"log":   {1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"log10": {1, func(a []float64) float64 { return math.Log10(a[0]) }},
+	"sin":   {1, func(a []float64) float64 { return math.Sin(a[0]) }},
+	"cos":   {1, func(a []float64) float64 { return math.Cos(a[0]) }},
+	"tan":   {1, func(a []float64) float64 { return math.Tan(a[0]) }},
+	"asin":  {1, func(a []float64) float64 { return math.Asin(a[0]) }},
+	"acos":  {1, func(a []float64) float64 { return math.Acos(a[0]) }},
+	"atan":  {1, func(a []float64) float64 { return math.Atan(a[0]) }},
+	"atan2": {2, func(a []float64) float64 { return math.Atan2(a[0], a[1]) }},
+	"floor": {1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"ceil":  {1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"round": {1, func(a []float64) float64 { return math.Round(a[0]) }},
+	"pow":   {2, func(a []float64) float64 { return pow(a[0], a[1]) }},
+	"hypot": {2, func(a []float64) float64 { return math.Hypot(a[0], a[1]) }},
+}
+
+// call parses the arguments of a call to the function named by t
+func (p *parser) call(t token) (evaluator, error) {
+	var args []evaluator
+	if !p.accept(")") {
+		for {
+			f, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, f)
+			if p.accept(")") {
+				break
+			}
+			if !p.accept(",") {
+				return nil, p.unexpected()
+			}
+		}
+	}
+	arity := func(n int) error {
+		if len(args) != n {
+			return fmt.Errorf("%w: %s at position %d takes %d arguments, not %d", errCalc, t.text, t.pos, n, len(args))
+		}
+		return nil
+	}
+
+	switch t.text {
+	case "where":
+		if err := arity(3); err != nil {
+			return nil, err
+		}
+		return func(k int) float64 {
+			c := args[0](k)
+			switch {
+			case math.IsNaN(c):
+				return c
+			case c != 0:
+				return args[1](k)
+			}
+			return args[2](k)
+		}, nil
+	case "isnodata":
+		if err := arity(1); err != nil {
+			return nil, err
+		}
+		return func(k int) float64 { return truth(math.IsNaN(args[0](k))) }, nil
+	case "min", "max":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%w: %s at position %d takes at least 1 argument", errCalc, t.text, t.pos)
+		}
+		f := math.Min
+		if t.text == "max" {
+			f = math.Max
+		}
+		return func(k int) float64 {
+			v := args[0](k)
+			for _, a := range args[1:] {
+				v = f(v, a(k))
+			}
+			return v
+		}, nil
+	}
+
+	fn, ok := calcFunctions[t.text]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown function %q at position %d", errCalc, t.text, t.pos)
+	}
+	if err := arity(fn.args); err != nil {
+		return nil, err
+	}
+	values := make([]float64, len(args))
+	return func(k int) float64 {
+		for n, a := range args {
+			values[n] = a(k)
+		}
+		return fn.f(values)
+	}, nil
+}
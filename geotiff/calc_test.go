@@ -0,0 +1,134 @@
+package geotiff
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_Calculate_Happy(t *testing.T) {
+	// A holds its column and B its row, plus one, over a 4x3 grid
+	a := demGeoTIFF(t, 4326, 135, -20, 0.1, 4, 3, func(i, j int) float32 { return float32(i) })
+	b := demGeoTIFF(t, 4326, 135, -20, 0.1, 4, 3, func(i, j int) float32 { return float32(j + 1) })
+	inputs := map[string]*GeoTIFF{"A": a, "B": b}
+	nan := float32(math.NaN())
+
+	tests := []struct {
+		expression string
+		want       map[[2]int]float32
+	}{
+		{"A + B * 2", map[[2]int]float32{{0, 0}: 2, {3, 2}: 9}},
+		{"(A - B) / (A + B)", map[[2]int]float32{{1, 0}: 0, {3, 0}: 0.5, {2, 1}: 0}},
+		{"-2^2 + 2^-1 + 2^3^2", map[[2]int]float32{{0, 0}: 508.5}},
+		{"10 % 4 - 1.5e1 * .1", map[[2]int]float32{{0, 0}: 0.5}},
+		{"A > 1 && B <= 2 || A == 0", map[[2]int]float32{{0, 2}: 1, {1, 0}: 0, {2, 1}: 1, {2, 2}: 0}},
+		{"!(A != 2)", map[[2]int]float32{{2, 0}: 1, {3, 0}: 0}},
+		{"where(A > 1, A, -B)", map[[2]int]float32{{3, 0}: 3, {1, 2}: -3}},
+		{"min(A, B, 2) + max(A, 1)", map[[2]int]float32{{0, 0}: 1, {3, 2}: 5}},
+		{"sqrt(A) * abs(-B) + floor(pi) + round(e)", map[[2]int]float32{{1, 1}: 8}},
+		{"hypot(3, 4) + atan2(0, 1) + pow(2, 3) + log10(100)", map[[2]int]float32{{0, 0}: 15}},
+		{"1 / A", map[[2]int]float32{{0, 0}: nan, {2, 0}: 0.5}},
+		{"sqrt(A - 2)", map[[2]int]float32{{1, 0}: nan, {3, 0}: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			g, err := Calculate(tt.expression, inputs, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkPixels(t, g, tt.want)
+		})
+	}
+
+	t.Run("nodata", func(t *testing.T) {
+		a := demGeoTIFF(t, 4326, 135, -20, 0.1, 4, 3, func(i, j int) float32 { return float32(i) })
+		a.SetNoData(0)
+		inputs := map[string]*GeoTIFF{"A": a, "B": b}
+		tests := []struct {
+			expression string
+			want       map[[2]int]float32
+		}{
+			{"A + B", map[[2]int]float32{{0, 0}: 0, {1, 0}: 2}},
+			{"A < 100", map[[2]int]float32{{0, 0}: 0, {1, 0}: 1}},
+			{"where(isnodata(A), B * 10, A)", map[[2]int]float32{{0, 1}: 20, {2, 1}: 2}},
+			{"where(B > 1, B, A)", map[[2]int]float32{{0, 0}: 0, {0, 1}: 2}},
+			{"A^0", map[[2]int]float32{{0, 0}: 0, {1, 0}: 1}},
+			{"pow(1, A)", map[[2]int]float32{{0, 0}: 0, {1, 0}: 1}},
+		}
+		for _, tt := range tests {
+			g, err := Calculate(tt.expression, inputs, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkPixels(t, g, tt.want)
+			if v, ok := g.NoData(); !ok || v != 0 {
+				t.Errorf("%s: got nodata %v, %v want 0", tt.expression, v, ok)
+			}
+		}
+
+		noData := -9999.0
+		g, err := Calculate("A * B", inputs, &CalcOptions{NoData: &noData})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPixels(t, g, map[[2]int]float32{{0, 0}: -9999, {3, 2}: 9})
+		if v, ok := g.NoData(); !ok || v != noData {
+			t.Errorf("got nodata %v, %v want %v", v, ok, noData)
+		}
+	})
+
+	t.Run("names", func(t *testing.T) {
+		// Inputs shadow the constants
+		g, err := Calculate("e + dem_2", map[string]*GeoTIFF{"e": a, "dem_2": b}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPixels(t, g, map[[2]int]float32{{3, 2}: 6})
+		if g.PixelScaleX != a.PixelScaleX || g.imageWidth != 4 || g.imageLength != 3 {
+			t.Errorf("got a %dx%d image of %v pixels", g.imageWidth, g.imageLength, g.PixelScaleX)
+		}
+	})
+}
+
+func Test_Calculate_Sad(t *testing.T) {
+	a := demGeoTIFF(t, 4326, 135, -20, 0.1, 4, 3, func(i, j int) float32 { return float32(i) })
+	inputs := map[string]*GeoTIFF{"A": a}
+	for _, expression := range []string{
+		"",
+		"A +",
+		"(A",
+		"A)",
+		"A $ 2",
+		"1.2.3",
+		"C * 2",
+		"foo(A)",
+		"where(A, 1)",
+		"min()",
+		"sqrt(A, 2)",
+		"A B",
+		"max(A,)",
+	} {
+		t.Run(expression, func(t *testing.T) {
+			if _, err := Calculate(expression, inputs, nil); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	t.Run("inputs", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			inputs map[string]*GeoTIFF
+		}{
+			{"none", nil},
+			{"size", map[string]*GeoTIFF{"A": a, "B": demGeoTIFF(t, 4326, 135, -20, 0.1, 3, 3, func(i, j int) float32 { return 0 })}},
+			{"offset", map[string]*GeoTIFF{"A": a, "B": demGeoTIFF(t, 4326, 135.05, -20, 0.1, 4, 3, func(i, j int) float32 { return 0 })}},
+			{"scale", map[string]*GeoTIFF{"A": a, "B": demGeoTIFF(t, 4326, 135, -20, 0.2, 4, 3, func(i, j int) float32 { return 0 })}},
+			{"crs", map[string]*GeoTIFF{"A": a, "B": demGeoTIFF(t, 28355, 135, -20, 0.1, 4, 3, func(i, j int) float32 { return 0 })}},
+		}
+		for _, tt := range tests {
+			if _, err := Calculate("A", tt.inputs, nil); err == nil {
+				t.Errorf("%s: expected an error", tt.name)
+			}
+		}
+	})
+}
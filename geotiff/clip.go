@@ -0,0 +1,88 @@
+package geotiff
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var errClip = errors.New("unable to clip GeoTIFF")
+
+// Clip returns the part of the image inside a bounding box in a coordinate
+// reference system, such as a longitude and latitude box in WGS 84, or the
+// coordinate reference system of the image if crs is nil
+//
+// A box in another coordinate reference system is replaced by the rectangle
+// containing it in the coordinate reference system of the image. The box is
+// snapped outwards to the pixel grid, so every pixel it touches is kept, and
+// limited to the extent of the image. An error is returned if the box does
+// not overlap the image.
+func (g *GeoTIFF) Clip(cc CornerCoordinates, crs *CRS) (*GeoTIFF, error) {
+	bounds, err := g.Bounds()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errClip, err)
+	}
+	if crs != nil {
+		from, err := g.CRS()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errClip, err)
+		}
+		box, err := transformBounds(&cc, crs, from)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errClip, err)
+		}
+		cc = *box
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range []Point{cc.UpperLeft, cc.LowerLeft, cc.UpperRight, cc.LowerRight} {
+		minX, maxX = math.Min(minX, p.Lon), math.Max(maxX, p.Lon)
+		minY, maxY = math.Min(minY, p.Lat), math.Max(maxY, p.Lat)
+	}
+
+	// The small tolerance keeps edges which lie on the pixel grid from
+	// taking in an extra pixel to rounding error
+	const tolerance = 1e-6
+	x0 := math.Floor((minX-bounds.UpperLeft.Lon)/g.PixelScaleX + tolerance)
+	x1 := math.Ceil((maxX-bounds.UpperLeft.Lon)/g.PixelScaleX - tolerance)
+	y0 := math.Floor((bounds.UpperLeft.Lat-maxY)/g.PixelScaleY + tolerance)
+	y1 := math.Ceil((bounds.UpperLeft.Lat-minY)/g.PixelScaleY - tolerance)
+	x0, y0 = math.Max(x0, 0), math.Max(y0, 0)
+	x1, y1 = math.Min(x1, float64(g.imageWidth)), math.Min(y1, float64(g.imageLength))
+	if !(x1 > x0 && y1 > y0) {
+		return nil, fmt.Errorf("%w: box does not overlap the image\n%s", errClip, cc)
+	}
+	return g.ClipWindow(int(x0), int(y0), int(x1-x0), int(y1-y0))
+}
+
+// ClipWindow returns a window of the image, width by length pixels with its
+// upper left corner at pixel x, y
+//
+// The window must lie inside the image.
+func (g *GeoTIFF) ClipWindow(x int, y int, width int, length int) (*GeoTIFF, error) {
+	if width < 1 || length < 1 || x < 0 || y < 0 ||
+		x+width > int(g.imageWidth) || y+length > int(g.imageLength) {
+		return nil, fmt.Errorf("%w: window %dx%d at %d, %d is outside the %dx%d image",
+			errClip, width, length, x, y, g.imageWidth, g.imageLength)
+	}
+	bounds, err := g.Bounds()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errClip, err)
+	}
+
+	raster := make([]float32, 0, width*length)
+	for j := y; j < y+length; j++ {
+		for i := x; i < x+width; i++ {
+			v, err := g.loc(i, j)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", errClip, err)
+			}
+			raster = append(raster, v)
+		}
+	}
+	upperLeft := Point{
+		Lon: bounds.UpperLeft.Lon + float64(x)*g.PixelScaleX,
+		Lat: bounds.UpperLeft.Lat - float64(y)*g.PixelScaleY,
+	}
+	return g.derive(raster, width, length, upperLeft, g.PixelScaleX, g.PixelScaleY), nil
+}
@@ -0,0 +1,157 @@
+package geotiff
+
+import (
+	"bytes"
+	"testing"
+)
+
+func box(minX float64, minY float64, maxX float64, maxY float64) CornerCoordinates {
+	return CornerCoordinates{
+		UpperLeft:  Point{Lon: minX, Lat: maxY},
+		LowerLeft:  Point{Lon: minX, Lat: minY},
+		UpperRight: Point{Lon: maxX, Lat: maxY},
+		LowerRight: Point{Lon: maxX, Lat: minY},
+	}
+}
+
+func Test_Clip_Happy(t *testing.T) {
+	// rampGeoTIFF holds x + 10 * y in 0.1 degree pixels from 135, -20
+	g := rampGeoTIFF(t, 10, 10)
+
+	tests := []struct {
+		name      string
+		box       CornerCoordinates
+		upperLeft Point
+		width     uint16
+		length    uint16
+		first     float32
+	}{
+		{"on the grid", box(135.2, -20.5, 135.5, -20.1), Point{Lon: 135.2, Lat: -20.1}, 3, 4, 12},
+		{"snapped outwards", box(135.25, -20.45, 135.41, -20.11), Point{Lon: 135.2, Lat: -20.1}, 3, 4, 12},
+		{"limited to the image", box(130, -30, 135.15, -20.85), Point{Lon: 135, Lat: -20.8}, 2, 2, 80},
+		{"whole image", box(135, -21, 136, -20), Point{Lon: 135, Lat: -20}, 10, 10, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := g.Clip(tt.box, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.imageWidth != tt.width || got.imageLength != tt.length {
+				t.Fatalf("got size %dx%d want %dx%d", got.imageWidth, got.imageLength, tt.width, tt.length)
+			}
+			bounds, err := got.Bounds()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !checkToTolerance(bounds.UpperLeft.Lon, tt.upperLeft.Lon, 1e-9) ||
+				!checkToTolerance(bounds.UpperLeft.Lat, tt.upperLeft.Lat, 1e-9) {
+				t.Errorf("got upper left %s want %s", bounds.UpperLeft, tt.upperLeft)
+			}
+			if v, _ := got.loc(0, 0); v != tt.first {
+				t.Errorf("got first pixel %v want %v", v, tt.first)
+			}
+			last, _ := got.loc(int(tt.width)-1, int(tt.length)-1)
+			if want := tt.first + float32(tt.width-1) + 10*float32(tt.length-1); last != want {
+				t.Errorf("got last pixel %v want %v", last, want)
+			}
+		})
+	}
+
+	t.Run("geographic box", func(t *testing.T) {
+		// A box of a tenth of a degree around the middle of a 100 km MGA zone
+		// 55 image of 1 km pixels
+		g := demGeoTIFF(t, 28355, 300000, 6000000, 1000, 100, 100, func(i, j int) float32 { return float32(i + 100*j) })
+		wgs84, _ := CRSFromEPSG(4326)
+		mga55, _ := CRSFromEPSG(28355)
+		mid, err := Transform(Point{Lon: 350000, Lat: 5950000}, mga55, wgs84)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lonLat := box(mid.Lon-0.05, mid.Lat-0.05, mid.Lon+0.05, mid.Lat+0.05)
+
+		got, err := g.Clip(lonLat, wgs84)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// About 9 km across and 11 km down, snapped outwards
+		if got.imageWidth < 9 || got.imageWidth > 12 || got.imageLength < 11 || got.imageLength > 14 {
+			t.Errorf("got size %dx%d want about 10x12", got.imageWidth, got.imageLength)
+		}
+		bounds, err := got.Bounds()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range []Point{lonLat.UpperLeft, lonLat.LowerLeft, lonLat.UpperRight, lonLat.LowerRight} {
+			q, err := Transform(p, wgs84, mga55)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bounds.Contains(q) {
+				t.Errorf("corner %s is outside the clipped image\n%s", p, bounds)
+			}
+		}
+
+		// Read as eastings and northings the box misses the image
+		if _, err := g.Clip(lonLat, nil); err == nil {
+			t.Error("expected an error for a geographic box in the image coordinates")
+		}
+	})
+
+	t.Run("clipped file", func(t *testing.T) {
+		g := readTestFile(t, testfile)
+		g.SetNoData(-9999)
+		got, err := g.Clip(box(137, -23, 138.5, -21), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := Write(&buf, got, nil); err != nil {
+			t.Fatal(err)
+		}
+		back, err := Read(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSameGeoTIFF(t, back, got)
+		if v, ok := back.NoData(); !ok || v != -9999 {
+			t.Errorf("got nodata %v, %v want -9999", v, ok)
+		}
+		crs, err := back.CRS()
+		if err != nil || crs.EPSG != 4326 {
+			t.Errorf("got %v, %v want EPSG:4326", crs, err)
+		}
+
+		// The values match the source image at the same coordinates
+		for _, p := range []Point{{Lon: 137.01, Lat: -21.01}, {Lon: 138.49, Lat: -22.99}, {Lon: 137.7, Lat: -22.2}} {
+			want, err := g.AtCoord(p.Lon, p.Lat, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := back.AtCoord(p.Lon, p.Lat, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v != want {
+				t.Errorf("%s: got %v want %v", p, v, want)
+			}
+		}
+	})
+}
+
+func Test_Clip_Sad(t *testing.T) {
+	g := rampGeoTIFF(t, 10, 10)
+	if _, err := g.Clip(box(140, -30, 141, -29), nil); err == nil {
+		t.Errorf("expected an error for a box outside the image")
+	}
+	if _, err := g.Clip(box(135.2, -20.2, 135.2, -20.1), nil); err == nil {
+		t.Errorf("expected an error for an empty box")
+	}
+
+	windows := [][4]int{{-1, 0, 2, 2}, {0, 0, 0, 2}, {9, 0, 2, 2}, {0, 5, 2, 6}}
+	for _, w := range windows {
+		if _, err := g.ClipWindow(w[0], w[1], w[2], w[3]); err == nil {
+			t.Errorf("window %v: expected an error", w)
+		}
+	}
+}
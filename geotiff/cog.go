@@ -0,0 +1,238 @@
+package geotiff
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// cogGhostArea is the GDAL structural metadata placed directly after the
+// header of a Cloud Optimized GeoTIFF, describing the layout of the file to
+// readers which can take advantage of it.
+//
+// See https://gdal.org/drivers/raster/cog.html#header-ghost-area
+const cogGhostArea = "LAYOUT=IFDS_BEFORE_DATA\n" +
+	"BLOCK_ORDER=ROW_MAJOR\n" +
+	"BLOCK_LEADER=SIZE_AS_UINT4\n" +
+	"BLOCK_TRAILER=LAST_4_BYTES_REPEATED\n" +
+	"KNOWN_INCOMPATIBLE_EDITION=NO\n"
+
+// COGOptions configures how a Cloud Optimized GeoTIFF is encoded
+type COGOptions struct {
+	// WriteOptions configures the byte order, compression, predictor and tile
+	// size of the full resolution image and its overviews. Strips are not
+	// supported.
+	WriteOptions
+
+	// Resampling used to build the overviews.
+	//
+	// Defaults to Nearest
+	Resampling Resampling
+
+	// Overviews is the number of overviews to build. Zero builds overviews
+	// until the smallest fits in a single tile.
+	Overviews int
+}
+
+// cogLevel is the full resolution image, or one of its overviews
+type cogLevel struct {
+	tags   Tags
+	blocks [][]byte
+}
+
+// overviews returns the reduced resolution versions of the image, each half
+// the size of the last, following the sizes used by GDAL.
+func (g *GeoTIFF) overviews(opts *COGOptions) ([]*GeoTIFF, error) {
+	tileWidth, tileLength := opts.tileSize(g)
+	nd := g.noData()
+
+	var levels []*GeoTIFF
+	prev := g
+	raster := g.raster()
+	for factor := 2; ; factor *= 2 {
+		if opts.Overviews > 0 && len(levels) == opts.Overviews {
+			break
+		}
+		if opts.Overviews == 0 && int(prev.imageWidth) <= tileWidth && int(prev.imageLength) <= tileLength {
+			break
+		}
+		width := (int(g.imageWidth) + factor - 1) / factor
+		length := (int(g.imageLength) + factor - 1) / factor
+		if width == int(prev.imageWidth) && length == int(prev.imageLength) {
+			break
+		}
+
+		rx := float64(prev.imageWidth) / float64(width)
+		ry := float64(prev.imageLength) / float64(length)
+		out, err := resample(raster, int(prev.imageWidth), int(prev.imageLength), width, length, rx, ry, opts.Resampling, nd)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errGeoTIFFWrite, err)
+		}
+		ovr := &GeoTIFF{
+			data:        tile(out, width, length, tileWidth, tileLength),
+			imageWidth:  uint16(width),
+			imageLength: uint16(length),
+			tileWidth:   uint16(tileWidth),
+			tileLength:  uint16(tileLength),
+			PixelScaleX: g.PixelScaleX * float64(g.imageWidth) / float64(width),
+			PixelScaleY: g.PixelScaleY * float64(g.imageLength) / float64(length),
+		}
+		levels = append(levels, ovr)
+		prev, raster = ovr, out
+	}
+	return levels, nil
+}
+
+// overviewTags returns the tags of an overview, which share the sample
+// format and compression of the full resolution image but carry no
+// georeferencing of their own
+func overviewTags(ovr *GeoTIFF, full Tags) Tags {
+	tags := Tags{
+		NewSubfileType: longTag(subfileReducedResolution),
+		ImageWidth:     shortTag(ovr.imageWidth),
+		ImageLength:    shortTag(ovr.imageLength),
+	}
+	for _, k := range [...]Tag{
+		BitsPerSample, Compression, PhotometricInterpretation, SamplesPerPixel,
+		PlanarConfiguration, Predictor, SampleFormat, GDALNoData,
+	} {
+		if v, ok := full[k]; ok {
+			tags[k] = v
+		}
+	}
+	return tags
+}
+
+// WriteCOG encodes the GeoTIFF as a Cloud Optimized GeoTIFF
+//
+// Overviews are built from the image with the requested resampling, and the
+// file is laid out following the GDAL COG driver:
+//
+//   - the GDAL structural metadata ghost area directly after the header
+//   - the IFDs of the full resolution image and then each overview, from the
+//     largest to the smallest, before any image data
+//   - the tile data of the smallest overview first and the full resolution
+//     image last, with the tiles of each image in row major order
+//   - each tile preceded by its size and followed by a copy of its last 4 bytes
+//
+// opts may be nil, in which case the defaults are used.
+func WriteCOG(w io.Writer, g *GeoTIFF, opts *COGOptions) error {
+	if opts == nil {
+		opts = &COGOptions{}
+	}
+	if opts.strips() {
+		return fmt.Errorf("%w: cloud optimized GeoTIFFs must be tiled", errGeoTIFFWrite)
+	}
+	if opts.Overviews < 0 {
+		return fmt.Errorf("%w: invalid number of overviews %d", errGeoTIFFWrite, opts.Overviews)
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	wo := &opts.WriteOptions
+	byteOrder := wo.byteOrder()
+
+	tags, err := g.imageTags(wo)
+	if err != nil {
+		return err
+	}
+	blocks, err := g.encodeBlocks(tags, wo)
+	if err != nil {
+		return err
+	}
+	levels := []cogLevel{{tags: tags, blocks: blocks}}
+
+	ovrs, err := g.overviews(opts)
+	if err != nil {
+		return err
+	}
+	for _, ovr := range ovrs {
+		ovrTags := overviewTags(ovr, tags)
+		ovrBlocks, err := ovr.encodeBlocks(ovrTags, wo)
+		if err != nil {
+			return err
+		}
+		levels = append(levels, cogLevel{tags: ovrTags, blocks: ovrBlocks})
+	}
+
+	// The ghost area is padded so the first IFD begins on a word boundary.
+	// The size line has a fixed length, so padding keeps it correct.
+	ghost := cogGhostArea
+	if (headerSize+len(cogGhostHeader(ghost))+len(ghost))%2 != 0 {
+		ghost += " "
+	}
+	ghost = cogGhostHeader(ghost) + ghost
+
+	// IFDs are placed one after the other, directly after the ghost area
+	ifdOffsets := make([]uint32, len(levels))
+	offset := uint64(headerSize + len(ghost))
+	for i, l := range levels {
+		ifdOffsets[i] = uint32(offset)
+		offset += uint64(ifdSize(l.tags))
+	}
+
+	// Tile data follows, from the smallest overview to the full resolution
+	// image, with the offsets pointing past the 4 byte leader
+	for i := len(levels) - 1; i >= 0; i-- {
+		offsets := blockOffsets(levels[i].tags)
+		for j, b := range levels[i].blocks {
+			offset += fourByte
+			if offset+uint64(len(b))+fourByte > math.MaxUint32 {
+				return fmt.Errorf("%w: file exceeds the 4GB TIFF limit", errGeoTIFFWrite)
+			}
+			offsets[j] = uint32(offset)
+			offset += uint64(len(b)) + fourByte
+		}
+	}
+
+	if _, err := w.Write(encodeHeader(byteOrder, ifdOffsets[0])); err != nil {
+		return fmt.Errorf("%w: %s", errGeoTIFFWrite, err)
+	}
+	if _, err := io.WriteString(w, ghost); err != nil {
+		return fmt.Errorf("%w: %s", errGeoTIFFWrite, err)
+	}
+	for i, l := range levels {
+		var next uint32
+		if i+1 < len(levels) {
+			next = ifdOffsets[i+1]
+		}
+		if _, err := w.Write(encodeIFD(l.tags, byteOrder, ifdOffsets[i], next)); err != nil {
+			return fmt.Errorf("%w: %s", errGeoTIFFWrite, err)
+		}
+	}
+	for i := len(levels) - 1; i >= 0; i-- {
+		for _, b := range levels[i].blocks {
+			if err := writeCOGBlock(w, b); err != nil {
+				return fmt.Errorf("%w: %s", errGeoTIFFWrite, err)
+			}
+		}
+	}
+	return nil
+}
+
+// cogGhostHeader returns the line giving the size of the ghost area
+func cogGhostHeader(ghost string) string {
+	return fmt.Sprintf("GDAL_STRUCTURAL_METADATA_SIZE=%06d bytes\n", len(ghost))
+}
+
+// writeCOGBlock writes a tile with its leader, the tile size as a little
+// endian uint32, and its trailer, a copy of the last 4 bytes of the tile
+func writeCOGBlock(w io.Writer, b []byte) error {
+	leader := make([]byte, fourByte)
+	binary.LittleEndian.PutUint32(leader, uint32(len(b)))
+	if _, err := w.Write(leader); err != nil {
+		return err
+	}
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	trailer := make([]byte, fourByte)
+	if len(b) >= fourByte {
+		copy(trailer, b[len(b)-fourByte:])
+	} else {
+		copy(trailer[fourByte-len(b):], b)
+	}
+	_, err := w.Write(trailer)
+	return err
+}
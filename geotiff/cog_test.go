@@ -0,0 +1,195 @@
+package geotiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_WriteCOG_Layout(t *testing.T) {
+	r, err := os.Open(testfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	want, err := Read(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &COGOptions{
+		WriteOptions: WriteOptions{
+			Compression: Deflate,
+			Predictor:   FloatingPointPredictor,
+			TileWidth:   32,
+			TileLength:  32,
+		},
+		Resampling: Average,
+	}
+	var buf bytes.Buffer
+	if err := WriteCOG(&buf, want, opts); err != nil {
+		t.Fatal(err)
+	}
+	file := buf.Bytes()
+
+	if !strings.HasPrefix(string(file[headerSize:]), "GDAL_STRUCTURAL_METADATA_SIZE=") {
+		t.Errorf("missing ghost area")
+	}
+
+	ifds, _, err := readIFDs(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 180x191 -> 90x96 -> 45x48 -> 23x24, the last fitting in a 32x32 tile
+	wantSizes := [][2]uint64{{180, 191}, {90, 96}, {45, 48}, {23, 24}}
+	if len(ifds) != len(wantSizes) {
+		t.Fatalf("got %d IFDs want %d", len(ifds), len(wantSizes))
+	}
+
+	var firstTile uint64 = 1 << 32
+	var previousStart uint64 = 1 << 32
+	for i, d := range ifds {
+		width, _ := d.tags.Uint64s(ImageWidth)
+		length, _ := d.tags.Uint64s(ImageLength)
+		if width[0] != wantSizes[i][0] || length[0] != wantSizes[i][1] {
+			t.Errorf("IFD %d: got size %dx%d want %dx%d", i, width[0], length[0], wantSizes[i][0], wantSizes[i][1])
+		}
+		subfile, err := d.tags.Uint64s(NewSubfileType)
+		if i == 0 && err == nil {
+			t.Errorf("full resolution image marked as %s %v", NewSubfileType, subfile)
+		}
+		if i > 0 && (err != nil || subfile[0] != uint64(subfileReducedResolution)) {
+			t.Errorf("IFD %d: got %s %v, %v", i, NewSubfileType, subfile, err)
+		}
+
+		offsets, _ := d.tags.Uint64s(TileOffsets)
+		counts, _ := d.tags.Uint64s(TileByteCounts)
+		for j, o := range offsets {
+			if o < firstTile {
+				firstTile = o
+			}
+			// tile leader and trailer
+			leader := binary.LittleEndian.Uint32(file[o-4:])
+			if uint64(leader) != counts[j] {
+				t.Errorf("IFD %d tile %d: got leader %d want %d", i, j, leader, counts[j])
+			}
+			end := o + counts[j]
+			if !bytes.Equal(file[end-4:end], file[end:end+4]) {
+				t.Errorf("IFD %d tile %d: trailer does not repeat the last 4 bytes", i, j)
+			}
+			if j > 0 && o <= offsets[j-1] {
+				t.Errorf("IFD %d: tiles are not in row major order", i)
+			}
+		}
+
+		// the data of each overview comes before the larger images
+		if offsets[0] > previousStart {
+			t.Errorf("IFD %d: data at %d is after the data of the larger image at %d", i, offsets[0], previousStart)
+		}
+		previousStart = offsets[0]
+	}
+
+	for _, d := range ifds {
+		if uint64(d.offset) > firstTile {
+			t.Errorf("IFD at %d is after the tile data at %d", d.offset, firstTile)
+		}
+	}
+
+	got, err := Read(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSameGeoTIFF(t, got, want)
+}
+
+func Test_WriteCOG_Header(t *testing.T) {
+	g := rampGeoTIFF(t, 10, 10)
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		t.Run(order.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			opts := &COGOptions{WriteOptions: WriteOptions{ByteOrder: order, TileWidth: 16, TileLength: 16}}
+			if err := WriteCOG(&buf, g, opts); err != nil {
+				t.Fatal(err)
+			}
+			file := buf.Bytes()
+
+			// IFDs begin on a word boundary
+			first := order.Uint32(file[4:8])
+			if first%2 != 0 {
+				t.Errorf("first IFD at odd offset %d", first)
+			}
+
+			// The declared size covers the rest of the ghost area
+			var size int
+			line := string(file[headerSize:])
+			if _, err := fmt.Sscanf(line, "GDAL_STRUCTURAL_METADATA_SIZE=%06d bytes\n", &size); err != nil {
+				t.Fatal(err)
+			}
+			ghostStart := headerSize + strings.Index(line, "\n") + 1
+			if want := int(first) - ghostStart; size != want {
+				t.Errorf("got ghost area size %d want %d", size, want)
+			}
+		})
+	}
+}
+
+func Test_Overviews_Resampling(t *testing.T) {
+	g := demGeoTIFF(t, 4326, 135, -20, 1, 32, 32, func(i, j int) float32 { return float32(i % 4) })
+	g.SetNoData(3)
+
+	tests := []struct {
+		method Resampling
+		want   float32
+	}{
+		// each 2x2 block is {0, 1, 0, 1} or {2, 3, 2, 3}, with 3 as nodata
+		{method: Nearest, want: 1},
+		{method: Average, want: 0.5},
+		{method: Min, want: 0},
+		{method: Max, want: 1},
+		{method: Mode, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method.String(), func(t *testing.T) {
+			ovrs, err := g.overviews(&COGOptions{Resampling: tt.method})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ovrs) != 1 {
+				t.Fatalf("got %d overviews want 1", len(ovrs))
+			}
+			o := ovrs[0]
+			if o.imageWidth != 16 || o.PixelScaleX != 2 {
+				t.Errorf("got width %d scale %f want 16, 2", o.imageWidth, o.PixelScaleX)
+			}
+			if v, _ := o.loc(0, 0); v != tt.want {
+				t.Errorf("got %f want %f", v, tt.want)
+			}
+			// the second block only has the nodata value 3 and the value 2
+			if v, _ := o.loc(1, 0); tt.method != Nearest && v != 2 {
+				t.Errorf("got %f want 2", v)
+			}
+		})
+	}
+}
+
+func Test_WriteCOG_Sad(t *testing.T) {
+	r, err := os.Open(testfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	g, err := Read(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteCOG(&bytes.Buffer{}, g, &COGOptions{WriteOptions: WriteOptions{RowsPerStrip: 16}}); err == nil {
+		t.Errorf("expected error for stripped COG")
+	}
+	if err := WriteCOG(&bytes.Buffer{}, g, &COGOptions{Resampling: Resampling(99)}); err == nil {
+		t.Errorf("expected error for unknown resampling")
+	}
+}
@@ -0,0 +1,221 @@
+package geotiff
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// CompressionScheme is the compression applied to each tile or strip
+//
+// From the Tiff 6.0 Specification (p.30) and the Adobe Deflate TIFF Technical Note
+type CompressionScheme uint16
+
+const (
+	Uncompressed CompressionScheme = 1 // No compression
+	LZW          CompressionScheme = 5 // Lempel-Ziv & Welch
+	Deflate      CompressionScheme = 8 // Adobe style DEFLATE (zlib)
+)
+
+// compression codes which are only read, never written
+const (
+	compressionNone          = uint64(Uncompressed)
+	compressionDeflateLegacy = 32946 // PKZIP style DEFLATE, superseded by Deflate
+)
+
+// PredictorScheme is the predictor applied before compression
+//
+// From the Tiff 6.0 Specification (p.64) and Adobe Photoshop TIFF Technical
+// Note 3
+type PredictorScheme uint16
+
+const (
+	NoPredictor            PredictorScheme = 1 // No prediction
+	HorizontalPredictor    PredictorScheme = 2 // Horizontal differencing of samples
+	FloatingPointPredictor PredictorScheme = 3 // Byte wise differencing of the shuffled floating point bytes
+)
+
+const predictorNone = uint64(NoPredictor)
+
+func (c CompressionScheme) String() string {
+	switch c {
+	case Uncompressed:
+		return "NONE"
+	case LZW:
+		return "LZW"
+	case Deflate, compressionDeflateLegacy:
+		return "DEFLATE"
+	}
+	return fmt.Sprintf("compression %d", uint16(c))
+}
+
+func (p PredictorScheme) String() string {
+	switch p {
+	case NoPredictor:
+		return "NONE"
+	case HorizontalPredictor:
+		return "HORIZONTAL"
+	case FloatingPointPredictor:
+		return "FLOATING_POINT"
+	}
+	return fmt.Sprintf("predictor %d", uint16(p))
+}
+
+// decodeBlock decompresses a tile or strip into 32 bit floats, where width
+// is the number of samples in each row of the block and size is the most
+// bytes it may decompress to
+func decodeBlock(raw []byte, width int, size int, compression uint16, predictor uint16, byteOrder binary.ByteOrder) ([]float32, error) {
+	b, err := decompress(raw, compression, size)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(b) / 4
+	data := make([]float32, n)
+	switch predictor {
+	case uint16(NoPredictor):
+		for i := range data {
+			data[i] = math.Float32frombits(byteOrder.Uint32(b[4*i:]))
+		}
+	case uint16(HorizontalPredictor):
+		// The differences are taken between the 32 bit samples, which are
+		// stored in the byte order of the file
+		for row := 0; row < n; row += width {
+			var prev uint32
+			for i := row; i < row+width && i < n; i++ {
+				prev += byteOrder.Uint32(b[4*i:])
+				data[i] = math.Float32frombits(prev)
+			}
+		}
+	case uint16(FloatingPointPredictor):
+		// The floating point predictor is independent of the byte order of the
+		// file, the bytes of each row are stored most significant byte first
+		for row := 0; row < n; row += width {
+			w := width
+			if row+w > n {
+				w = n - row
+			}
+			undoFloatingPointPredictor(b[4*row:4*(row+w)], data[row:row+w])
+		}
+	default:
+		return nil, fmt.Errorf("unsupported %s", PredictorScheme(predictor))
+	}
+	return data, nil
+}
+
+// decompress returns the bytes of a compressed tile or strip, which may be
+// at most size bytes long
+func decompress(raw []byte, compression uint16, size int) ([]byte, error) {
+	switch compression {
+	case uint16(Uncompressed):
+		return raw, nil
+	case uint16(LZW):
+		return lzwDecode(raw, size)
+	case uint16(Deflate), compressionDeflateLegacy:
+		zr, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("deflate: %w", err)
+		}
+		// One byte more than the block holds is read to find overlong blocks
+		b, err := io.ReadAll(io.LimitReader(zr, int64(size)+1))
+		if err != nil {
+			return nil, fmt.Errorf("deflate: %w", err)
+		}
+		if len(b) > size {
+			return nil, fmt.Errorf("deflate: block is longer than %d bytes", size)
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("unsupported %s", CompressionScheme(compression))
+	}
+}
+
+// undoFloatingPointPredictor reverses the floating point predictor on a
+// single row of bytes
+func undoFloatingPointPredictor(b []byte, data []float32) {
+	for i := 1; i < len(b); i++ {
+		b[i] += b[i-1]
+	}
+	w := len(data)
+	for i := range data {
+		bits := uint32(b[i])<<24 | uint32(b[w+i])<<16 | uint32(b[2*w+i])<<8 | uint32(b[3*w+i])
+		data[i] = math.Float32frombits(bits)
+	}
+}
+
+// encodeBlock applies the predictor and compression to a tile or strip, where
+// width is the number of samples in each row of the block
+func encodeBlock(data []float32, width int, byteOrder binary.ByteOrder, opts *WriteOptions) ([]byte, error) {
+	b := make([]byte, 4*len(data))
+	switch opts.predictor() {
+	case NoPredictor:
+		for i, v := range data {
+			byteOrder.PutUint32(b[4*i:], math.Float32bits(v))
+		}
+	case HorizontalPredictor:
+		for row := 0; row < len(data); row += width {
+			var prev uint32
+			for i := row; i < row+width && i < len(data); i++ {
+				bits := math.Float32bits(data[i])
+				byteOrder.PutUint32(b[4*i:], bits-prev)
+				prev = bits
+			}
+		}
+	case FloatingPointPredictor:
+		for row := 0; row < len(data); row += width {
+			w := width
+			if row+w > len(data) {
+				w = len(data) - row
+			}
+			applyFloatingPointPredictor(data[row:row+w], b[4*row:4*(row+w)])
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported %s", errGeoTIFFWrite, opts.predictor())
+	}
+
+	switch opts.compression() {
+	case Uncompressed:
+		return b, nil
+	case LZW:
+		return lzwEncode(b), nil
+	case Deflate:
+		level := zlib.DefaultCompression
+		if opts.CompressionLevel != 0 {
+			level = opts.CompressionLevel
+		}
+		var buf bytes.Buffer
+		zw, err := zlib.NewWriterLevel(&buf, level)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errGeoTIFFWrite, err)
+		}
+		if _, err := zw.Write(b); err != nil {
+			return nil, fmt.Errorf("%w: %s", errGeoTIFFWrite, err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("%w: %s", errGeoTIFFWrite, err)
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("%w: unsupported %s", errGeoTIFFWrite, opts.compression())
+}
+
+// applyFloatingPointPredictor shuffles the bytes of a row of floats into
+// planes, most significant byte first, and differences the resulting bytes
+//
+// See Adobe Photoshop TIFF Technical Note 3
+func applyFloatingPointPredictor(data []float32, b []byte) {
+	w := len(data)
+	for i, v := range data {
+		bits := math.Float32bits(v)
+		b[i] = byte(bits >> 24)
+		b[w+i] = byte(bits >> 16)
+		b[2*w+i] = byte(bits >> 8)
+		b[3*w+i] = byte(bits)
+	}
+	for i := len(b) - 1; i > 0; i-- {
+		b[i] -= b[i-1]
+	}
+}
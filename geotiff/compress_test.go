@@ -0,0 +1,209 @@
+package geotiff
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func Test_LZW_RoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := make([]byte, 100000)
+	rng.Read(random)
+	repetitive := bytes.Repeat([]byte("TOBEORNOTTOBEORTOBEORNOT"), 5000)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: []byte{}},
+		{name: "single byte", data: []byte{42}},
+		{name: "repeated byte", data: bytes.Repeat([]byte{7}, 70000)},
+		{name: "repetitive", data: repetitive},
+		// random data fills the string table and forces clear codes
+		{name: "random", data: random},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := lzwEncode(tt.data)
+			decoded, err := lzwDecode(encoded, len(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, tt.data) {
+				t.Errorf("decoded %d bytes which do not match the %d input bytes", len(decoded), len(tt.data))
+			}
+		})
+	}
+
+	t.Run("known encoding", func(t *testing.T) {
+		// Clear, 'a', 'b', EOI packed as 9 bit codes
+		got := lzwEncode([]byte("ab"))
+		want := []byte{0x80, 0x18, 0x4c, 0x50, 0x10}
+		if !bytes.Equal(got, want) {
+			t.Errorf("got %x want %x", got, want)
+		}
+	})
+}
+
+func Test_Write_Compressed_RoundTrip(t *testing.T) {
+	r, err := os.Open(testfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	want, err := Read(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var uncompressed bytes.Buffer
+	if err := Write(&uncompressed, want, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, byteOrder := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for _, compression := range []CompressionScheme{LZW, Deflate} {
+			for _, predictor := range []PredictorScheme{NoPredictor, HorizontalPredictor, FloatingPointPredictor} {
+				for _, rowsPerStrip := range []int{0, 7} {
+					opts := &WriteOptions{
+						ByteOrder:        byteOrder,
+						Compression:      compression,
+						CompressionLevel: 9,
+						Predictor:        predictor,
+						RowsPerStrip:     rowsPerStrip,
+					}
+					name := fmt.Sprintf("%s %s %s strips %d", byteOrder, compression, predictor, rowsPerStrip)
+					t.Run(name, func(t *testing.T) {
+						var buf bytes.Buffer
+						if err := Write(&buf, want, opts); err != nil {
+							t.Fatal(err)
+						}
+						if buf.Len() >= uncompressed.Len() {
+							t.Errorf("compressed size %d is not smaller than %d", buf.Len(), uncompressed.Len())
+						}
+
+						got, err := Read(bytes.NewReader(buf.Bytes()))
+						if err != nil {
+							t.Fatal(err)
+						}
+						tags := got.Tags()
+						if c, _ := tags.Uint64s(Compression); c[0] != uint64(compression) {
+							t.Errorf("got compression %d want %d", c[0], compression)
+						}
+						if rowsPerStrip > 0 {
+							if _, ok := tags[StripOffsets]; !ok {
+								t.Errorf("missing %s", StripOffsets)
+							}
+						}
+						checkSameGeoTIFF(t, got, want)
+					})
+				}
+			}
+		}
+	}
+}
+
+func Test_Read_Strips(t *testing.T) {
+	r, err := os.Open("./testdata/WCSServer_cropped.tif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	g, err := Read(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.imageWidth != 600 || g.imageLength != 600 {
+		t.Errorf("got size %dx%d want 600x600", g.imageWidth, g.imageLength)
+	}
+
+	// strips are written back out as tiles
+	var buf bytes.Buffer
+	if err := Write(&buf, g, &WriteOptions{Compression: Deflate, Predictor: FloatingPointPredictor}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.tileWidth != defaultTileSize || got.tileLength != defaultTileSize {
+		t.Errorf("got tile size %dx%d want %dx%d", got.tileWidth, got.tileLength, defaultTileSize, defaultTileSize)
+	}
+	checkSameGeoTIFF(t, got, g)
+}
+
+func Test_Decompress_Sad(t *testing.T) {
+	b := bytes.Repeat([]byte{7}, 101)
+	var deflated bytes.Buffer
+	zw := zlib.NewWriter(&deflated)
+	zw.Write(b)
+	zw.Close()
+
+	// Blocks which decompress to more than a block holds are refused
+	tests := []struct {
+		name        string
+		raw         []byte
+		compression CompressionScheme
+	}{
+		{"lzw", lzwEncode(b), LZW},
+		{"deflate", deflated.Bytes(), Deflate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decompress(tt.raw, uint16(tt.compression), len(b)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := decompress(tt.raw, uint16(tt.compression), len(b)-1); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+// rewriteTags returns a copy of a file written by Write with its tags
+// changed, which must not change the size of the IFD
+func rewriteTags(t *testing.T, file []byte, change func(tags Tags)) []byte {
+	t.Helper()
+	tags, h, err := readTags(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	change(tags)
+	file = append([]byte(nil), file...)
+	copy(file[headerSize:], encodeIFD(tags, h.byteOrder, headerSize, 0))
+	return file
+}
+
+func Test_Read_Sad(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, rampGeoTIFF(t, 10, 10), &WriteOptions{Compression: Deflate}); err != nil {
+		t.Fatal(err)
+	}
+	file := buf.Bytes()
+
+	tests := []struct {
+		name   string
+		change func(tags Tags)
+	}{
+		// A block claiming 4GB is refused before it is allocated
+		{"byte count beyond eof", func(tags Tags) { tags[TileByteCounts].longData[0] = math.MaxUint32 }},
+		{"offset beyond eof", func(tags Tags) { tags[TileOffsets].longData[0] = uint32(len(file)) }},
+		{"integer samples", func(tags Tags) { tags[SampleFormat] = shortTag(sampleFormatInt) }},
+		{"three bands", func(tags Tags) { tags[SamplesPerPixel] = shortTag(3) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(bytes.NewReader(rewriteTags(t, file, tt.change)))
+			if !errors.Is(err, errGeoTIFFData) {
+				t.Errorf("got %v want %v", err, errGeoTIFFData)
+			}
+		})
+	}
+}
@@ -77,7 +77,7 @@ var fieldTypeLen = [...]uint32{
 //
 // returns 0 if unrecognized
 func (f fieldType) bytes() uint32 {
-	if f == 0 || int(f) > len(fieldTypeLen) {
+	if f == 0 || int(f) >= len(fieldTypeLen) {
 		return fieldTypeLen[0]
 	}
 	return fieldTypeLen[int(f)]
@@ -113,6 +113,7 @@ type Tag uint16
 
 //nolint:unused
 const (
+	NewSubfileType            Tag = 254 // NewSubfileType
 	ImageWidth                Tag = 256 // ImageWidth
 	ImageLength               Tag = 257 // ImageLength
 	BitsPerSample             Tag = 258 // BitsPerSample
@@ -145,9 +146,14 @@ const (
 	ModelPixelScale     Tag = 33550
 	ModelTiepoint       Tag = 33922
 	ModelTransformation Tag = 34264
+
+	// GDAL Private Tags
+	GDALMetadata Tag = 42112 // XML encoded band and dataset metadata
+	GDALNoData   Tag = 42113 // ASCII encoded nodata value
 )
 
 var tagToLabel = map[Tag]string{
+	NewSubfileType:            "NewSubfileType",
 	ImageWidth:                "ImageWidth",
 	ImageLength:               "ImageLength",
 	BitsPerSample:             "BitsPerSample",
@@ -178,6 +184,8 @@ var tagToLabel = map[Tag]string{
 	ModelPixelScale:           "ModelPixelScale",
 	ModelTiepoint:             "ModelTiepoint",
 	ModelTransformation:       "ModelTransformation",
+	GDALMetadata:              "GDALMetadata",
+	GDALNoData:                "GDALNoData",
 }
 
 func (t Tag) String() string {
@@ -190,6 +198,7 @@ func (t Tag) String() string {
 
 //nolint:unused
 var tagToLen = map[Tag]uint32{
+	NewSubfileType:            1,
 	ImageWidth:                1,
 	ImageLength:               1,
 	BitsPerSample:             1,
@@ -220,6 +229,8 @@ var tagToLen = map[Tag]uint32{
 	ModelPixelScale:           0,
 	ModelTiepoint:             0,
 	ModelTransformation:       0,
+	GDALMetadata:              0,
+	GDALNoData:                0,
 }
 
 //nolint:unused
@@ -236,3 +247,26 @@ const (
 	yCbCr       photometricInterpretation = 6
 	cIELab      photometricInterpretation = 8
 )
+
+// From the Tiff 6.0 Specification (p.80)
+//
+// SampleFormat specifies how to interpret each data sample in a pixel.
+//
+//nolint:unused
+const (
+	sampleFormatUint      uint16 = 1 // unsigned integer data
+	sampleFormatInt       uint16 = 2 // two's complement signed integer data
+	sampleFormatIEEEFP    uint16 = 3 // IEEE floating point data
+	sampleFormatUndefined uint16 = 4 // undefined data format
+)
+
+// From the Tiff 6.0 Specification (p.36)
+//
+// NewSubfileType is a general indication of the kind of data contained in
+// this subfile.
+//
+//nolint:unused
+const (
+	subfileReducedResolution uint32 = 1 // reduced resolution version of another image in the file
+	subfileMask              uint32 = 4 // transparency mask for another image in the file
+)
@@ -0,0 +1,655 @@
+package geotiff
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var errContour = errors.New("unable to generate contours")
+
+// maxContourLevels limits the number of levels an interval can generate
+const maxContourLevels = 100000
+
+// ContourOptions configures Contours
+type ContourOptions struct {
+	// Interval is the spacing of the contour levels, which are offset from
+	// Base
+	Interval float64
+	Base     float64
+
+	// Levels are explicit contour levels, used instead of Interval
+	Levels []float64
+
+	// Polygons generates filled polygons of the bands between the levels,
+	// rather than lines along them
+	Polygons bool
+
+	// Attribute is the name of the feature property holding the level of a
+	// line. Polygons hold the range of their band in Attribute + "_min" and
+	// Attribute + "_max".
+	//
+	// Defaults to "elevation"
+	Attribute string
+}
+
+// levels returns the sorted contour levels over the range lo to hi
+func (o *ContourOptions) levels(lo float64, hi float64) ([]float64, error) {
+	if len(o.Levels) > 0 {
+		levels := append([]float64(nil), o.Levels...)
+		sort.Float64s(levels)
+		out := levels[:1]
+		for _, l := range levels[1:] {
+			if l != out[len(out)-1] {
+				out = append(out, l)
+			}
+		}
+		for _, l := range out {
+			if math.IsNaN(l) || math.IsInf(l, 0) {
+				return nil, fmt.Errorf("%w: level %v", errContour, l)
+			}
+		}
+		return out, nil
+	}
+	if !(o.Interval > 0) || math.IsInf(o.Interval, 0) {
+		return nil, fmt.Errorf("%w: an interval greater than zero or levels are required", errContour)
+	}
+	if math.IsNaN(lo) {
+		return nil, nil
+	}
+	first := math.Ceil((lo - o.Base) / o.Interval)
+	last := math.Floor((hi - o.Base) / o.Interval)
+	if last-first >= maxContourLevels {
+		return nil, fmt.Errorf("%w: interval %v gives more than %d levels", errContour, o.Interval, maxContourLevels)
+	}
+	var levels []float64
+	for k := first; k <= last; k++ {
+		levels = append(levels, o.Base+k*o.Interval)
+	}
+	return levels, nil
+}
+
+// Contours traces lines of equal value through the image, or with
+// ContourOptions.Polygons the areas between them, as GeoJSON features in the
+// coordinate reference system of the image
+//
+// Contours are traced with marching squares through the pixel centres, with
+// values interpolated linearly along the sides of each square. Squares where
+// a contour could pass either way between diagonally opposite pixels are
+// resolved by the mean of the four pixels. Squares touching nodata pixels
+// are skipped, so contours stop at nodata.
+//
+// Each line is a LineString feature, closed where the contour forms a loop.
+// Each band between consecutive levels, and below the first and above the
+// last level, is a MultiPolygon feature.
+func (g *GeoTIFF) Contours(opts *ContourOptions) (*FeatureCollection, error) {
+	if opts == nil {
+		opts = &ContourOptions{}
+	}
+	attribute := opts.Attribute
+	if attribute == "" {
+		attribute = "elevation"
+	}
+	stats := g.Stats()
+	levels, err := opts.levels(stats.Min, stats.Max)
+	if err != nil {
+		return nil, err
+	}
+	bounds, err := g.Bounds()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errContour, err)
+	}
+
+	c := &contourer{
+		raster: g.raster(),
+		width:  int(g.imageWidth),
+		length: int(g.imageLength),
+		nd:     g.noData(),
+		levels: levels,
+		world: func(x float64, y float64) Position {
+			return Position{
+				bounds.UpperLeft.Lon + (x+0.5)*g.PixelScaleX,
+				bounds.UpperLeft.Lat - (y+0.5)*g.PixelScaleY,
+			}
+		},
+	}
+	if !opts.Polygons {
+		return NewFeatureCollection(c.lines(attribute)), nil
+	}
+	return NewFeatureCollection(c.bands(attribute, stats)), nil
+}
+
+// contourKey identifies a vertex of a contour, either a pixel centre or the
+// point a level crosses the side of a square between two pixel centres
+type contourKey struct {
+	kind  int // one of keyCorner, keyAcross or keyDown
+	i, j  int // pixel of the corner or of the start of the side
+	level int // index of the level crossing the side
+}
+
+const (
+	keyCorner = iota // the centre of pixel i, j
+	keyAcross        // the side from pixel i, j to i + 1, j
+	keyDown          // the side from pixel i, j to i, j + 1
+)
+
+// contourer traces contours through a row major raster
+type contourer struct {
+	raster []float32
+	width  int
+	length int
+	nd     noData
+	levels []float64
+	world  func(x float64, y float64) Position
+}
+
+func (c *contourer) at(i int, j int) float64 {
+	return float64(c.raster[j*c.width+i])
+}
+
+// point returns the position of a vertex in world coordinates
+func (c *contourer) point(k contourKey) Position {
+	switch k.kind {
+	case keyAcross:
+		a, b := c.at(k.i, k.j), c.at(k.i+1, k.j)
+		return c.world(float64(k.i)+(c.levels[k.level]-a)/(b-a), float64(k.j))
+	case keyDown:
+		a, b := c.at(k.i, k.j), c.at(k.i, k.j+1)
+		return c.world(float64(k.i), float64(k.j)+(c.levels[k.level]-a)/(b-a))
+	}
+	return c.world(float64(k.i), float64(k.j))
+}
+
+// cell holds the four pixel centres at the corners of a marching square,
+// numbered clockwise from the upper left in image order. Side e runs from
+// corner e to corner e + 1, so the sides are the top, right, bottom and left.
+type cell struct {
+	i, j   int
+	values [4]float64
+}
+
+// corners are the offsets of the corners of a square from its upper left
+var corners = [4][2]int{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+
+// cell returns the square with its upper left corner at pixel i, j, or
+// false if any of its corners are nodata
+func (c *contourer) cell(i int, j int) (cell, bool) {
+	s := cell{i: i, j: j}
+	for n, o := range corners {
+		v := c.raster[(j+o[1])*c.width+i+o[0]]
+		if c.nd.is(v) || math.IsInf(float64(v), 0) {
+			return s, false
+		}
+		s.values[n] = float64(v)
+	}
+	return s, true
+}
+
+// side returns the key of a level crossing side e of the square
+func (s cell) side(e int, level int) contourKey {
+	switch e {
+	case 0:
+		return contourKey{kind: keyAcross, i: s.i, j: s.j, level: level}
+	case 1:
+		return contourKey{kind: keyDown, i: s.i + 1, j: s.j, level: level}
+	case 2:
+		return contourKey{kind: keyAcross, i: s.i, j: s.j + 1, level: level}
+	}
+	return contourKey{kind: keyDown, i: s.i, j: s.j, level: level}
+}
+
+// corner returns the key of corner n of the square
+func (s cell) corner(n int) contourKey {
+	return contourKey{kind: keyCorner, i: s.i + corners[n][0], j: s.j + corners[n][1]}
+}
+
+// pairs returns the sides of the square joined by a contour at a level,
+// where the corners at or above the level are separated from those below.
+// Saddles, where diagonally opposite corners are on the same side of the
+// level, are resolved by the mean of the corners, isolating the corners on
+// the other side of it.
+func (s cell) pairs(level float64) [][2]int {
+	var sides []int
+	for e := 0; e < 4; e++ {
+		if (s.values[e] >= level) != (s.values[(e+1)%4] >= level) {
+			sides = append(sides, e)
+		}
+	}
+	switch len(sides) {
+	case 2:
+		return [][2]int{{sides[0], sides[1]}}
+	case 4:
+		centre := (s.values[0]+s.values[1]+s.values[2]+s.values[3])/4 >= level
+		var pairs [][2]int
+		for n, v := range s.values {
+			if (v >= level) != centre {
+				// The sides either side of corner n
+				pairs = append(pairs, [2]int{(n + 3) % 4, n})
+			}
+		}
+		return pairs
+	}
+	return nil
+}
+
+// span returns the levels between the minimum and maximum corner of the
+// square, as the index of the first level and one past the last
+func (c *contourer) span(s cell) (int, int) {
+	lo, hi := s.values[0], s.values[0]
+	for _, v := range s.values[1:] {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	first := sort.Search(len(c.levels), func(k int) bool { return c.levels[k] > lo })
+	last := sort.Search(len(c.levels), func(k int) bool { return c.levels[k] > hi })
+	return first, last
+}
+
+// lines returns a LineString feature for each contour line
+func (c *contourer) lines(attribute string) []Feature {
+	// The neighbours of each vertex, of which there are at most two as a side
+	// is shared by at most two squares
+	links := make([]map[contourKey][]contourKey, len(c.levels))
+	for l := range links {
+		links[l] = make(map[contourKey][]contourKey)
+	}
+	for j := 0; j+1 < c.length; j++ {
+		for i := 0; i+1 < c.width; i++ {
+			s, ok := c.cell(i, j)
+			if !ok {
+				continue
+			}
+			first, last := c.span(s)
+			for l := first; l < last; l++ {
+				for _, p := range s.pairs(c.levels[l]) {
+					a, b := s.side(p[0], l), s.side(p[1], l)
+					links[l][a] = append(links[l][a], b)
+					links[l][b] = append(links[l][b], a)
+				}
+			}
+		}
+	}
+
+	var features []Feature
+	for l, link := range links {
+		// Lines are walked from their ends first, so the vertices left over
+		// belong to closed loops. Vertices are sorted so the output does not
+		// depend on the order of the map
+		keys := make([]contourKey, 0, len(link))
+		for k := range link {
+			keys = append(keys, k)
+		}
+		sortKeys(keys)
+		visited := make(map[contourKey]bool, len(link))
+		walk := func(start contourKey) {
+			line := []Position{c.point(start)}
+			visited[start] = true
+			cur := start
+			for {
+				next, found := contourKey{}, false
+				for _, n := range link[cur] {
+					if !visited[n] {
+						next, found = n, true
+						break
+					}
+				}
+				if !found {
+					// Close loops back to their start
+					for _, n := range link[cur] {
+						if n == start && len(line) > 2 {
+							line = append(line, line[0])
+							break
+						}
+					}
+					break
+				}
+				visited[next] = true
+				line = append(line, c.point(next))
+				cur = next
+			}
+			if len(line) < 2 {
+				return
+			}
+			features = append(features, NewFeature(
+				&Geometry{Type: GeometryLineString, Coordinates: line},
+				map[string]interface{}{attribute: c.levels[l]},
+			))
+		}
+		for _, k := range keys {
+			if !visited[k] && len(link[k]) == 1 {
+				walk(k)
+			}
+		}
+		for _, k := range keys {
+			if !visited[k] {
+				walk(k)
+			}
+		}
+	}
+	return features
+}
+
+// bands returns a MultiPolygon feature for each band between the levels,
+// and below the first and above the last level
+func (c *contourer) bands(attribute string, stats GeoTIFFStats) []Feature {
+	// Each band is built by tracing its outline in each square, then
+	// dissolving the squares by cancelling the sides they share
+	edges := make([]map[[2]contourKey]bool, len(c.levels)+1)
+	for b := range edges {
+		edges[b] = make(map[[2]contourKey]bool)
+	}
+	for j := 0; j+1 < c.length; j++ {
+		for i := 0; i+1 < c.width; i++ {
+			s, ok := c.cell(i, j)
+			if !ok {
+				continue
+			}
+			first, last := c.span(s)
+			for b := first; b <= last; b++ {
+				for _, ring := range c.outline(s, b) {
+					for k := range ring {
+						e := [2]contourKey{ring[k], ring[(k+1)%len(ring)]}
+						if reverse := [2]contourKey{e[1], e[0]}; edges[b][reverse] {
+							delete(edges[b], reverse)
+							continue
+						}
+						edges[b][e] = true
+					}
+				}
+			}
+		}
+	}
+
+	var features []Feature
+	for b, band := range edges {
+		if len(band) == 0 {
+			continue
+		}
+		lo, hi := stats.Min, stats.Max
+		if b > 0 {
+			lo = c.levels[b-1]
+		}
+		if b < len(c.levels) {
+			hi = c.levels[b]
+		}
+		features = append(features, NewFeature(
+			&Geometry{Type: GeometryMultiPolygon, Coordinates: c.polygons(band)},
+			map[string]interface{}{attribute + "_min": lo, attribute + "_max": hi},
+		))
+	}
+	return features
+}
+
+// contourNode is a vertex on the outline of a square
+type contourNode struct {
+	key   contourKey
+	level int // index of the level crossing, or -1 for a corner
+}
+
+// outline returns the rings, counterclockwise in world coordinates, of the
+// parts of a square in band b, which holds values at or above level b - 1
+// and below level b
+func (c *contourer) outline(s cell, b int) [][]contourKey {
+	inBand := func(v float64) (bool, bool) {
+		above := b == 0 || v >= c.levels[b-1]
+		below := b == len(c.levels) || v < c.levels[b]
+		return above, below
+	}
+
+	// The sides are walked from the upper left down, across and up, which is
+	// counterclockwise in world coordinates, with the crossings of the
+	// levels bounding the band in order along each side
+	var nodes []contourNode
+	var inside []bool
+	above, below := inBand(s.values[0])
+	for _, e := range []int{3, 2, 1, 0} {
+		from := (e + 1) % 4
+		nodes = append(nodes, contourNode{key: s.corner(from), level: -1})
+		inside = append(inside, above && below)
+		crossings := make([]contourNode, 0, 2)
+		for _, l := range []int{b - 1, b} {
+			if l < 0 || l >= len(c.levels) {
+				continue
+			}
+			level := c.levels[l]
+			if (s.values[e] >= level) != (s.values[from] >= level) {
+				crossings = append(crossings, contourNode{key: s.side(e, l), level: l})
+			}
+		}
+		// Both levels can cross the same side, nearer the end with the
+		// closer value first
+		if len(crossings) == 2 {
+			t := func(l int) float64 {
+				return (c.levels[l] - s.values[from]) / (s.values[e] - s.values[from])
+			}
+			if t(crossings[1].level) < t(crossings[0].level) {
+				crossings[0], crossings[1] = crossings[1], crossings[0]
+			}
+		}
+		for _, n := range crossings {
+			if n.level == b-1 {
+				above = !above
+			} else {
+				below = !below
+			}
+			nodes = append(nodes, n)
+			inside = append(inside, above && below)
+		}
+	}
+
+	// Crossings are joined across the square to the crossing of the same
+	// contour on another side
+	partner := make(map[contourKey]int, len(nodes))
+	index := make(map[contourKey]int, len(nodes))
+	for k, n := range nodes {
+		index[n.key] = k
+	}
+	for _, l := range []int{b - 1, b} {
+		if l < 0 || l >= len(c.levels) {
+			continue
+		}
+		for _, p := range s.pairs(c.levels[l]) {
+			x, y := s.side(p[0], l), s.side(p[1], l)
+			partner[x], partner[y] = index[y], index[x]
+		}
+	}
+
+	var rings [][]contourKey
+	visited := make([]bool, len(nodes))
+	for start := range nodes {
+		if !inside[start] || visited[start] {
+			continue
+		}
+		var ring []contourKey
+		for k := start; !visited[k]; {
+			visited[k] = true
+			ring = append(ring, nodes[k].key)
+			next := (k + 1) % len(nodes)
+			if inside[next] || nodes[next].level < 0 {
+				k = next
+				continue
+			}
+			// Leaving the band, so cross to where the contour meets the
+			// outline again
+			ring = append(ring, nodes[next].key)
+			p, ok := partner[nodes[next].key]
+			if !ok || !inside[p] {
+				break
+			}
+			k = p
+		}
+		if len(ring) >= 3 {
+			rings = append(rings, ring)
+		}
+	}
+	return rings
+}
+
+// polygons joins the edges of a band into polygons, with their holes
+func (c *contourer) polygons(band map[[2]contourKey]bool) [][][]Position {
+	next := make(map[contourKey][]contourKey, len(band))
+	for e := range band {
+		next[e[0]] = append(next[e[0]], e[1])
+	}
+	starts := make([]contourKey, 0, len(next))
+	for k, n := range next {
+		starts = append(starts, k)
+		sortKeys(n)
+	}
+	sortKeys(starts)
+
+	var rings [][]Position
+	for _, start := range starts {
+		for len(next[start]) > 0 {
+			var ring []Position
+			for k := start; len(next[k]) > 0; {
+				ring = append(ring, c.point(k))
+				n := next[k][0]
+				next[k] = next[k][1:]
+				k = n
+				if k == start {
+					break
+				}
+			}
+			rings = append(rings, ring)
+		}
+	}
+	return nestRings(rings)
+}
+
+// nestRings simplifies and closes open rings and groups them into polygons,
+// where counterclockwise rings are exteriors and clockwise rings are holes
+// of the smallest exterior containing them
+//
+// Rings which pass through a vertex twice, where a region touches itself,
+// are first split into simple rings which touch at the vertex.
+func nestRings(rings [][]Position) [][][]Position {
+	var simple [][]Position
+	for _, ring := range rings {
+		simple = append(simple, splitRing(ring)...)
+	}
+	var exteriors, holes [][]Position
+	for _, ring := range simple {
+		ring = simplifyRing(ring)
+		if len(ring) < 3 {
+			continue
+		}
+		ring = append(ring, ring[0])
+		if ringArea(ring) > 0 {
+			exteriors = append(exteriors, ring)
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+
+	polygons := make([][][]Position, len(exteriors))
+	for i, e := range exteriors {
+		polygons[i] = [][]Position{e}
+	}
+	for _, h := range holes {
+		mid := Position{(h[0][0] + h[1][0]) / 2, (h[0][1] + h[1][1]) / 2}
+		best, bestArea := -1, math.Inf(1)
+		for i, e := range exteriors {
+			if a := ringArea(e); a < bestArea && inRing(mid, e) {
+				best, bestArea = i, a
+			}
+		}
+		if best >= 0 {
+			polygons[best] = append(polygons[best], h)
+		}
+	}
+	return polygons
+}
+
+// splitRing splits an open ring into rings which each pass through a vertex
+// once, by cutting out the loop between each repeated vertex
+func splitRing(ring []Position) [][]Position {
+	var rings [][]Position
+	stack := make([]Position, 0, len(ring))
+	index := make(map[[2]float64]int, len(ring))
+	for _, p := range ring {
+		k := [2]float64{p[0], p[1]}
+		n, ok := index[k]
+		if !ok {
+			index[k] = len(stack)
+			stack = append(stack, p)
+			continue
+		}
+		loop := append([]Position(nil), stack[n:]...)
+		rings = append(rings, loop)
+		for _, q := range stack[n+1:] {
+			delete(index, [2]float64{q[0], q[1]})
+		}
+		stack = stack[:n+1]
+	}
+	return append(rings, stack)
+}
+
+// sortKeys sorts vertex keys so contours do not depend on map order
+func sortKeys(keys []contourKey) {
+	sort.Slice(keys, func(a, b int) bool {
+		x, y := keys[a], keys[b]
+		if x.j != y.j {
+			return x.j < y.j
+		}
+		if x.i != y.i {
+			return x.i < y.i
+		}
+		if x.kind != y.kind {
+			return x.kind < y.kind
+		}
+		return x.level < y.level
+	})
+}
+
+// simplifyRing removes the vertices of an open ring which lie on a straight
+// line between their neighbours
+func simplifyRing(ring []Position) []Position {
+	straight := func(a Position, p Position, b Position) bool {
+		cross := (p[0]-a[0])*(b[1]-p[1]) - (p[1]-a[1])*(b[0]-p[0])
+		scale := math.Abs(p[0]-a[0]) + math.Abs(p[1]-a[1]) + math.Abs(b[0]-p[0]) + math.Abs(b[1]-p[1])
+		return math.Abs(cross) <= 1e-12*scale*scale
+	}
+	out := make([]Position, 0, len(ring))
+	for _, p := range ring {
+		for len(out) >= 2 && straight(out[len(out)-2], out[len(out)-1], p) {
+			out = out[:len(out)-1]
+		}
+		out = append(out, p)
+	}
+	// The ring wraps around, so its ends are checked against each other
+	for len(out) >= 3 {
+		n := len(out)
+		switch {
+		case straight(out[n-2], out[n-1], out[0]):
+			out = out[:n-1]
+		case straight(out[n-1], out[0], out[1]):
+			out = out[1:]
+		default:
+			return out
+		}
+	}
+	return out
+}
+
+// ringArea returns the signed area of a closed ring, which is positive for
+// counterclockwise rings
+func ringArea(ring []Position) float64 {
+	var sum float64
+	for k := 0; k+1 < len(ring); k++ {
+		sum += ring[k][0]*ring[k+1][1] - ring[k+1][0]*ring[k][1]
+	}
+	return sum / 2
+}
+
+// inRing reports if a point is inside a closed ring by the even-odd rule
+func inRing(p Position, ring []Position) bool {
+	inside := false
+	for k := 0; k+1 < len(ring); k++ {
+		a, b := ring[k], ring[k+1]
+		if (a[1] > p[1]) != (b[1] > p[1]) && p[0] < a[0]+(p[1]-a[1])*(b[0]-a[0])/(b[1]-a[1]) {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,254 @@
+package geotiff
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// eastRamp returns a WGS 84 image of 0.1 degree pixels, with an upper left
+// corner at 135, -20, where each pixel holds its column
+func eastRamp(t *testing.T, width int, length int) *GeoTIFF {
+	return demGeoTIFF(t, 4326, 135, -20, 0.1, width, length, func(i, j int) float32 { return float32(i) })
+}
+
+// peak returns an image of zeros with a square of ones in the middle
+func peak(t *testing.T) *GeoTIFF {
+	return demGeoTIFF(t, 4326, 135, -20, 0.1, 7, 7, func(i, j int) float32 {
+		if i >= 2 && i <= 4 && j >= 2 && j <= 4 {
+			return 1
+		}
+		return 0
+	})
+}
+
+func lineStrings(t *testing.T, fc *FeatureCollection) [][]Position {
+	t.Helper()
+	var lines [][]Position
+	for _, f := range fc.Features {
+		if f.Geometry.Type != GeometryLineString {
+			t.Fatalf("got a %s want a %s", f.Geometry.Type, GeometryLineString)
+		}
+		lines = append(lines, f.Geometry.Coordinates.([]Position))
+	}
+	return lines
+}
+
+func multiPolygons(t *testing.T, fc *FeatureCollection) [][][][]Position {
+	t.Helper()
+	var polygons [][][][]Position
+	for _, f := range fc.Features {
+		if f.Geometry.Type != GeometryMultiPolygon {
+			t.Fatalf("got a %s want a %s", f.Geometry.Type, GeometryMultiPolygon)
+		}
+		polygons = append(polygons, f.Geometry.Coordinates.([][][]Position))
+	}
+	return polygons
+}
+
+func Test_Contours_Happy(t *testing.T) {
+	t.Run("line", func(t *testing.T) {
+		fc, err := eastRamp(t, 10, 5).Contours(&ContourOptions{Levels: []float64{2.5}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := lineStrings(t, fc)
+		if len(lines) != 1 || len(lines[0]) != 5 {
+			t.Fatalf("got %v want one line of 5 points", lines)
+		}
+		// Halfway between the centres of the third and fourth columns
+		for _, p := range lines[0] {
+			if !checkToTolerance(p[0], 135.3, 1e-9) {
+				t.Errorf("got longitude %v want 135.3", p[0])
+			}
+		}
+		if got := fc.Features[0].Properties["elevation"]; got != 2.5 {
+			t.Errorf("got elevation %v want 2.5", got)
+		}
+	})
+
+	t.Run("closed loop", func(t *testing.T) {
+		fc, err := peak(t).Contours(&ContourOptions{Levels: []float64{0.5}, Attribute: "height"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := lineStrings(t, fc)
+		if len(lines) != 1 {
+			t.Fatalf("got %d lines want 1", len(lines))
+		}
+		line := lines[0]
+		if !reflect.DeepEqual(line[0], line[len(line)-1]) || len(line) != 13 {
+			t.Errorf("got %v want a closed loop of 12 points", line)
+		}
+		if got := fc.Features[0].Properties["height"]; got != 0.5 {
+			t.Errorf("got height %v want 0.5", got)
+		}
+	})
+
+	t.Run("interval", func(t *testing.T) {
+		g := eastRamp(t, 10, 5)
+		tests := []struct {
+			opts   ContourOptions
+			levels []float64
+		}{
+			{ContourOptions{Interval: 2}, []float64{2, 4, 6, 8}},
+			{ContourOptions{Interval: 2, Base: 1}, []float64{1, 3, 5, 7, 9}},
+			{ContourOptions{Interval: 20}, nil},
+		}
+		for _, tt := range tests {
+			fc, err := g.Contours(&tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var levels []float64
+			for _, f := range fc.Features {
+				levels = append(levels, f.Properties["elevation"].(float64))
+			}
+			if !reflect.DeepEqual(levels, tt.levels) {
+				t.Errorf("interval %v base %v: got levels %v want %v", tt.opts.Interval, tt.opts.Base, levels, tt.levels)
+			}
+		}
+	})
+
+	t.Run("nodata", func(t *testing.T) {
+		g := eastRamp(t, 10, 5)
+		g.SetNoData(-1)
+		for i := 0; i < 10; i++ {
+			g.data[0][2*16+i] = -1
+		}
+		fc, err := g.Contours(&ContourOptions{Levels: []float64{4.5}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lines := lineStrings(t, fc); len(lines) != 2 || len(lines[0]) != 2 || len(lines[1]) != 2 {
+			t.Errorf("got %v want two lines either side of the nodata row", lines)
+		}
+	})
+
+	t.Run("bands", func(t *testing.T) {
+		fc, err := eastRamp(t, 10, 5).Contours(&ContourOptions{Levels: []float64{4.5}, Polygons: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		polygons := multiPolygons(t, fc)
+		if len(polygons) != 2 {
+			t.Fatalf("got %d bands want 2", len(polygons))
+		}
+		wantArea := []float64{4.5 * 4 * 0.01, 4.5 * 4 * 0.01}
+		for b, mp := range polygons {
+			if len(mp) != 1 || len(mp[0]) != 1 || len(mp[0][0]) != 5 {
+				t.Fatalf("band %d: got %v want a single rectangle", b, mp)
+			}
+			if a := ringArea(mp[0][0]); !checkToTolerance(a, wantArea[b], 1e-9) {
+				t.Errorf("band %d: got area %v want %v", b, a, wantArea[b])
+			}
+		}
+		props := []map[string]interface{}{fc.Features[0].Properties, fc.Features[1].Properties}
+		want := []map[string]interface{}{
+			{"elevation_min": 0.0, "elevation_max": 4.5},
+			{"elevation_min": 4.5, "elevation_max": 9.0},
+		}
+		if !reflect.DeepEqual(props, want) {
+			t.Errorf("got properties %v want %v", props, want)
+		}
+		if _, err := json.Marshal(fc); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("band with a hole", func(t *testing.T) {
+		fc, err := peak(t).Contours(&ContourOptions{Levels: []float64{0.5}, Polygons: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		polygons := multiPolygons(t, fc)
+		if len(polygons) != 2 || len(polygons[0]) != 1 || len(polygons[1]) != 1 {
+			t.Fatalf("got %v want a polygon in each band", polygons)
+		}
+		outside, inside := polygons[0][0], polygons[1][0]
+		if len(outside) != 2 || len(inside) != 1 {
+			t.Fatalf("got %d and %d rings want 2 and 1", len(outside), len(inside))
+		}
+		if ringArea(outside[0]) <= 0 || ringArea(outside[1]) >= 0 {
+			t.Error("want a counterclockwise exterior and a clockwise hole")
+		}
+		// The bands cover the square between the outer pixel centres
+		total := ringArea(outside[0]) + ringArea(outside[1]) + ringArea(inside[0])
+		if !checkToTolerance(total, 0.36, 1e-9) || !checkToTolerance(-ringArea(outside[1]), ringArea(inside[0]), 1e-9) {
+			t.Errorf("got total area %v want 0.36", total)
+		}
+	})
+
+	t.Run("saddles", func(t *testing.T) {
+		// High values in the upper left and lower right, with a mean of 0.5
+		g := demGeoTIFF(t, 4326, 135, -20, 0.1, 2, 2, func(i, j int) float32 { return float32(1 - (i+j)%2) })
+		tests := []struct {
+			level float64
+			below int
+			above int
+		}{
+			// The high corners join through the middle
+			{0.4, 2, 1},
+			// The low corners join through the middle
+			{0.6, 1, 2},
+		}
+		for _, tt := range tests {
+			fc, err := g.Contours(&ContourOptions{Levels: []float64{tt.level}, Polygons: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			polygons := multiPolygons(t, fc)
+			if len(polygons) != 2 || len(polygons[0]) != tt.below || len(polygons[1]) != tt.above {
+				t.Errorf("level %v: got %v want %d polygons below and %d above", tt.level, polygons, tt.below, tt.above)
+			}
+			lines, err := g.Contours(&ContourOptions{Levels: []float64{tt.level}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(lines.Features) != 2 {
+				t.Errorf("level %v: got %d lines want 2", tt.level, len(lines.Features))
+			}
+		}
+	})
+}
+
+func Test_Cell_Pairs(t *testing.T) {
+	// Clockwise from the upper left, a saddle with a mean of 0.5
+	s := cell{values: [4]float64{1, 0, 1, 0}}
+	tests := []struct {
+		level float64
+		want  [][2]int
+	}{
+		// Isolating the low corners, upper right and lower left
+		{0.4, [][2]int{{0, 1}, {2, 3}}},
+		// Isolating the high corners, upper left and lower right
+		{0.6, [][2]int{{3, 0}, {1, 2}}},
+		{2, nil},
+	}
+	for _, tt := range tests {
+		if got := s.pairs(tt.level); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("level %v: got %v want %v", tt.level, got, tt.want)
+		}
+	}
+}
+
+func Test_Contours_Sad(t *testing.T) {
+	g := eastRamp(t, 10, 5)
+	tests := []struct {
+		name string
+		opts *ContourOptions
+	}{
+		{"no interval or levels", nil},
+		{"negative interval", &ContourOptions{Interval: -1}},
+		{"too many levels", &ContourOptions{Interval: 1e-6}},
+		{"infinite level", &ContourOptions{Levels: []float64{math.Inf(1)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := g.Contours(tt.opts); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
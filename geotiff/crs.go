@@ -0,0 +1,581 @@
+package geotiff
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ErrUnsupportedCRS is returned when a coordinate reference system is not
+// one of those implemented by this package
+var ErrUnsupportedCRS = errors.New("unsupported coordinate reference system")
+
+// projectionMethod is the map projection of a coordinate reference system
+type projectionMethod int
+
+const (
+	methodGeographic projectionMethod = iota
+	methodWebMercator
+	methodTransverseMercator
+	methodLambertConformalConic
+	methodAlbersEqualArea
+)
+
+// From the OGC GeoTIFF Standard (Annex C)
+//
+// Values of the ProjCoordTransGeoKey
+const (
+	coordTransTransverseMercator  uint16 = 1
+	coordTransLambertConfConic2SP uint16 = 8
+	coordTransLambertConfConic1SP uint16 = 9
+	coordTransAlbersEqualArea     uint16 = 11
+)
+
+// EPSG codes of units and geographic coordinate reference systems used in
+// the GeoKeys
+const (
+	epsgMetre  uint16 = 9001
+	epsgDegree uint16 = 9102
+	epsgWGS84         = 4326
+	epsgGDA94         = 4283
+	epsgGDA20         = 7844
+)
+
+// crsParams are the parameters defining a coordinate reference system,
+// angles are in degrees and distances in metres
+type crsParams struct {
+	method        projectionMethod
+	ellipsoid     ellipsoid
+	lat0          float64 // latitude of origin
+	lon0          float64 // central meridian
+	lat1          float64 // first standard parallel
+	lat2          float64 // second standard parallel
+	k0            float64 // scale factor at the origin
+	falseEasting  float64
+	falseNorthing float64
+}
+
+// CRS is a coordinate reference system
+//
+// Geographic coordinate reference systems hold longitude and latitude in
+// degrees, projected coordinate reference systems hold easting and northing
+// in metres. In both cases the first coordinate is stored in Point.Lon and
+// the second in Point.Lat.
+//
+// Datum transformations are not applied, GDA94, GDA2020 and WGS 84 are
+// treated as the same datum, which is accurate to within two metres across
+// Australia.
+type CRS struct {
+	// EPSG is the EPSG code of the coordinate reference system, or zero if it
+	// is user defined
+	EPSG int
+	// Name is the EPSG name of the coordinate reference system, or its
+	// citation if it is user defined
+	Name string
+
+	// geographic is the EPSG code of the underlying geographic coordinate
+	// reference system, or zero if it is user defined
+	geographic int
+	params     crsParams
+	proj       projection
+}
+
+// newCRS creates a coordinate reference system, building its projection
+// from the parameters
+func newCRS(epsg int, name string, geographic int, p crsParams) *CRS {
+	c := &CRS{EPSG: epsg, Name: name, geographic: geographic, params: p}
+	d2R := func(deg float64) float64 { return deg * math.Pi / 180 }
+	switch p.method {
+	case methodWebMercator:
+		c.proj = webMercator{r: p.ellipsoid.a}
+	case methodTransverseMercator:
+		c.proj = newTransverseMercator(p.ellipsoid, d2R(p.lat0), d2R(p.lon0), p.k0, p.falseEasting, p.falseNorthing)
+	case methodLambertConformalConic:
+		c.proj = newLambertConformalConic(p.ellipsoid, d2R(p.lat0), d2R(p.lon0), d2R(p.lat1), d2R(p.lat2),
+			p.k0, p.falseEasting, p.falseNorthing)
+	case methodAlbersEqualArea:
+		c.proj = newAlbersEqualArea(p.ellipsoid, d2R(p.lat0), d2R(p.lon0), d2R(p.lat1), d2R(p.lat2),
+			p.falseEasting, p.falseNorthing)
+	}
+	return c
+}
+
+// geographicCRS returns a geographic coordinate reference system by its EPSG
+// code
+func geographicCRS(code int) (*CRS, error) {
+	switch code {
+	case epsgWGS84:
+		return newCRS(code, "WGS 84", code, crsParams{ellipsoid: wgs84Ellipsoid}), nil
+	case epsgGDA94:
+		return newCRS(code, "GDA94", code, crsParams{ellipsoid: grs80Ellipsoid}), nil
+	case epsgGDA20:
+		return newCRS(code, "GDA2020", code, crsParams{ellipsoid: grs80Ellipsoid}), nil
+	}
+	return nil, fmt.Errorf("%w: EPSG:%d", ErrUnsupportedCRS, code)
+}
+
+// utmParams returns the parameters of a Universal Transverse Mercator zone
+func utmParams(zone int, south bool, el ellipsoid) crsParams {
+	p := crsParams{
+		method:       methodTransverseMercator,
+		ellipsoid:    el,
+		lon0:         float64(zone*6 - 183),
+		k0:           0.9996,
+		falseEasting: 500000,
+	}
+	if south {
+		p.falseNorthing = 10000000
+	}
+	return p
+}
+
+// CRSFromEPSG returns a coordinate reference system by its EPSG code
+//
+// The supported coordinate reference systems are
+//
+//   - EPSG:4326 WGS 84, EPSG:4283 GDA94 and EPSG:7844 GDA2020
+//   - EPSG:3857 WGS 84 / Pseudo-Mercator, used by web maps
+//   - EPSG:32601 to 32660 and 32701 to 32760, WGS 84 / UTM zones
+//   - EPSG:28348 to 28358, GDA94 / MGA zones 48 to 58
+//   - EPSG:7846 to 7859, GDA2020 / MGA zones 46 to 59
+//   - EPSG:3577 GDA94 and EPSG:9473 GDA2020 / Australian Albers
+//   - EPSG:3112 GDA94 / Geoscience Australia Lambert and EPSG:7845 GDA2020 / GA LCC
+func CRSFromEPSG(code int) (*CRS, error) {
+	switch {
+	case code == epsgWGS84 || code == epsgGDA94 || code == epsgGDA20:
+		return geographicCRS(code)
+	case code == 3857:
+		return newCRS(code, "WGS 84 / Pseudo-Mercator", epsgWGS84,
+			crsParams{method: methodWebMercator, ellipsoid: wgs84Ellipsoid}), nil
+	case code >= 32601 && code <= 32660:
+		zone := code - 32600
+		return newCRS(code, fmt.Sprintf("WGS 84 / UTM zone %dN", zone), epsgWGS84,
+			utmParams(zone, false, wgs84Ellipsoid)), nil
+	case code >= 32701 && code <= 32760:
+		zone := code - 32700
+		return newCRS(code, fmt.Sprintf("WGS 84 / UTM zone %dS", zone), epsgWGS84,
+			utmParams(zone, true, wgs84Ellipsoid)), nil
+	case code >= 28348 && code <= 28358:
+		zone := code - 28300
+		return newCRS(code, fmt.Sprintf("GDA94 / MGA zone %d", zone), epsgGDA94,
+			utmParams(zone, true, grs80Ellipsoid)), nil
+	case code >= 7846 && code <= 7859:
+		zone := code - 7800
+		return newCRS(code, fmt.Sprintf("GDA2020 / MGA zone %d", zone), epsgGDA20,
+			utmParams(zone, true, grs80Ellipsoid)), nil
+	case code == 3577 || code == 9473:
+		name, geographic := "GDA94 / Australian Albers", epsgGDA94
+		if code == 9473 {
+			name, geographic = "GDA2020 / Australian Albers", epsgGDA20
+		}
+		return newCRS(code, name, geographic, crsParams{
+			method:    methodAlbersEqualArea,
+			ellipsoid: grs80Ellipsoid,
+			lon0:      132,
+			lat1:      -18,
+			lat2:      -36,
+		}), nil
+	case code == 3112 || code == 7845:
+		name, geographic := "GDA94 / Geoscience Australia Lambert", epsgGDA94
+		if code == 7845 {
+			name, geographic = "GDA2020 / GA LCC", epsgGDA20
+		}
+		return newCRS(code, name, geographic, crsParams{
+			method:    methodLambertConformalConic,
+			ellipsoid: grs80Ellipsoid,
+			lon0:      134,
+			lat1:      -18,
+			lat2:      -36,
+			k0:        1,
+		}), nil
+	}
+	return nil, fmt.Errorf("%w: EPSG:%d", ErrUnsupportedCRS, code)
+}
+
+// Geographic reports if the coordinate reference system holds longitude and
+// latitude, rather than projected coordinates
+func (c *CRS) Geographic() bool {
+	return c.params.method == methodGeographic
+}
+
+// Equal reports if two coordinate reference systems define the same
+// coordinates, regardless of their EPSG codes and names
+func (c *CRS) Equal(o *CRS) bool {
+	return c.params == o.params
+}
+
+func (c *CRS) String() string {
+	if c.EPSG == 0 {
+		return c.Name
+	}
+	return fmt.Sprintf("EPSG:%d (%s)", c.EPSG, c.Name)
+}
+
+// toLonLat returns the longitude and latitude in radians of a point
+func (c *CRS) toLonLat(p Point) (float64, float64) {
+	if c.proj == nil {
+		return p.Lon * math.Pi / 180, p.Lat * math.Pi / 180
+	}
+	return c.proj.inverse(p.Lon, p.Lat)
+}
+
+// fromLonLat returns the point at a longitude and latitude in radians
+func (c *CRS) fromLonLat(lon float64, lat float64) Point {
+	if c.proj == nil {
+		return Point{Lon: lon * 180 / math.Pi, Lat: lat * 180 / math.Pi}
+	}
+	x, y := c.proj.forward(lon, lat)
+	return Point{Lon: x, Lat: y}
+}
+
+// Transform converts a point between coordinate reference systems
+func Transform(p Point, from *CRS, to *CRS) (Point, error) {
+	if from.Equal(to) {
+		return p, nil
+	}
+	if from.Geographic() && (p.Lat < -90 || p.Lat > 90) {
+		return Point{}, fmt.Errorf("latitude of %s is outside the range -90 to 90", p)
+	}
+	lon, lat := from.toLonLat(p)
+	out := to.fromLonLat(lon, lat)
+	for _, v := range [...]float64{lon, lat, out.Lon, out.Lat} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return Point{}, fmt.Errorf("%s can not be transformed from %s to %s", p, from, to)
+		}
+	}
+	return out, nil
+}
+
+// crsFromGeoKeys returns the coordinate reference system described by the
+// GeoKeys
+//
+// Coordinate reference systems are looked up by their EPSG code where one is
+// given, otherwise they are built from the user defined projection
+// parameters.
+func crsFromGeoKeys(keys geoKeys) (*CRS, error) {
+	modelType, ok := keys.short(GTModelTypeGeoKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing %s", ErrUnsupportedCRS, GTModelTypeGeoKey)
+	}
+	if units, ok := keys.short(GeogAngularUnitsGeoKey); ok && units != epsgDegree {
+		return nil, fmt.Errorf("%w: %s %d", ErrUnsupportedCRS, GeogAngularUnitsGeoKey, units)
+	}
+
+	// The ellipsoid of the geographic coordinate reference system, used by
+	// user defined projections
+	geographic, ok := keys.short(GeographicTypeGeoKey)
+	base, err := geographicCRS(int(geographic))
+	if !ok || err != nil {
+		if ok && geographic != userDefined {
+			return nil, err
+		}
+		el := wgs84Ellipsoid
+		if a, ok := keys.double(GeogSemiMajorAxisGeoKey); ok {
+			el.a = a
+			if invF, ok := keys.double(GeogInvFlatteningGeoKey); ok {
+				el.invF = invF
+			} else if b, ok := keys.double(GeogSemiMinorAxisGeoKey); ok && b != a {
+				el.invF = a / (a - b)
+			}
+		}
+		name := keys[GeogCitationGeoKey].asciiData
+		if name == "" {
+			name = "unknown"
+		}
+		base = newCRS(0, name, 0, crsParams{ellipsoid: el})
+	}
+
+	switch modelType {
+	case modelTypeGeographic:
+		return base, nil
+	case modelTypeProjected:
+	default:
+		return nil, fmt.Errorf("%w: %s %d", ErrUnsupportedCRS, GTModelTypeGeoKey, modelType)
+	}
+
+	if units, ok := keys.short(ProjLinearUnitsGeoKey); ok && units != epsgMetre {
+		return nil, fmt.Errorf("%w: %s %d", ErrUnsupportedCRS, ProjLinearUnitsGeoKey, units)
+	}
+	code, ok := keys.short(ProjectedCSTypeGeoKey)
+	if ok && code != userDefined {
+		return CRSFromEPSG(int(code))
+	}
+
+	name := keys[PCSCitationGeoKey].asciiData
+	if name == "" {
+		name = keys[GTCitationGeoKey].asciiData
+	}
+	el := base.params.ellipsoid
+
+	// Projection codes 16001 to 16060 and 16101 to 16160 are the UTM zones
+	if proj, ok := keys.short(ProjectionGeoKey); ok && proj != userDefined {
+		switch {
+		case proj >= 16001 && proj <= 16060:
+			return newCRS(0, name, base.geographic, utmParams(int(proj)-16000, false, el)), nil
+		case proj >= 16101 && proj <= 16160:
+			return newCRS(0, name, base.geographic, utmParams(int(proj)-16100, true, el)), nil
+		}
+		return nil, fmt.Errorf("%w: %s %d", ErrUnsupportedCRS, ProjectionGeoKey, proj)
+	}
+
+	double := func(key GeoKey, fallback ...GeoKey) float64 {
+		for _, k := range append([]GeoKey{key}, fallback...) {
+			if v, ok := keys.double(k); ok {
+				return v
+			}
+		}
+		return 0
+	}
+	p := crsParams{
+		ellipsoid:     el,
+		lat0:          double(ProjNatOriginLatGeoKey, ProjFalseOriginLatGeoKey, ProjCenterLatGeoKey),
+		lon0:          double(ProjNatOriginLongGeoKey, ProjFalseOriginLongGeoKey, ProjCenterLongGeoKey),
+		falseEasting:  double(ProjFalseEastingGeoKey, ProjFalseOriginEastingGeoKey),
+		falseNorthing: double(ProjFalseNorthingGeoKey, ProjFalseOriginNorthingGeoKey),
+		k0:            1,
+	}
+	if k0, ok := keys.double(ProjScaleAtNatOriginGeoKey); ok {
+		p.k0 = k0
+	}
+	transform, _ := keys.short(ProjCoordTransGeoKey)
+	switch transform {
+	case coordTransTransverseMercator:
+		p.method = methodTransverseMercator
+	case coordTransLambertConfConic2SP:
+		p.method = methodLambertConformalConic
+		p.lat1, p.lat2 = double(ProjStdParallel1GeoKey), double(ProjStdParallel2GeoKey)
+	case coordTransLambertConfConic1SP:
+		p.method = methodLambertConformalConic
+		p.lat1, p.lat2 = p.lat0, p.lat0
+	case coordTransAlbersEqualArea:
+		p.method = methodAlbersEqualArea
+		p.lat1, p.lat2 = double(ProjStdParallel1GeoKey), double(ProjStdParallel2GeoKey)
+		p.k0 = 0
+	default:
+		return nil, fmt.Errorf("%w: %s %d", ErrUnsupportedCRS, ProjCoordTransGeoKey, transform)
+	}
+	if name == "" {
+		name = "user defined"
+	}
+	return newCRS(0, name, base.geographic, p), nil
+}
+
+// geoKeyEntry is a single GeoKey to encode, holding either a SHORT value
+// or DOUBLE or ASCII parameters
+type geoKeyEntry struct {
+	key    GeoKey
+	short  uint16
+	double []float64
+	ascii  string
+}
+
+// geoKeyTags returns the GeoKeyDirectory, GeoDoubleParams and GeoASCIIParams
+// tags describing the coordinate reference system
+func (c *CRS) geoKeyTags() Tags {
+	geographic := userDefined
+	if c.geographic != 0 {
+		geographic = uint16(c.geographic)
+	}
+
+	var entries []geoKeyEntry
+	if c.Geographic() {
+		entries = append(entries,
+			geoKeyEntry{key: GTModelTypeGeoKey, short: modelTypeGeographic},
+			geoKeyEntry{key: GTRasterTypeGeoKey, short: rasterPixelIsArea},
+			geoKeyEntry{key: GeographicTypeGeoKey, short: geographic},
+			geoKeyEntry{key: GeogCitationGeoKey, ascii: c.Name},
+			geoKeyEntry{key: GeogAngularUnitsGeoKey, short: epsgDegree},
+		)
+	} else {
+		entries = append(entries,
+			geoKeyEntry{key: GTModelTypeGeoKey, short: modelTypeProjected},
+			geoKeyEntry{key: GTRasterTypeGeoKey, short: rasterPixelIsArea},
+			geoKeyEntry{key: GTCitationGeoKey, ascii: c.Name},
+			geoKeyEntry{key: GeographicTypeGeoKey, short: geographic},
+			geoKeyEntry{key: GeogAngularUnitsGeoKey, short: epsgDegree},
+			geoKeyEntry{key: ProjLinearUnitsGeoKey, short: epsgMetre},
+		)
+		if c.EPSG != 0 {
+			entries = append(entries, geoKeyEntry{key: ProjectedCSTypeGeoKey, short: uint16(c.EPSG)})
+		} else {
+			entries = append(entries, c.projectionEntries()...)
+		}
+	}
+	if c.geographic == 0 {
+		entries = append(entries,
+			geoKeyEntry{key: GeogSemiMajorAxisGeoKey, double: []float64{c.params.ellipsoid.a}},
+			geoKeyEntry{key: GeogInvFlatteningGeoKey, double: []float64{c.params.ellipsoid.invF}},
+		)
+	}
+
+	// Keys are sorted as required by the GeoTIFF standard
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	dir := []uint16{1, 1, 0, uint16(len(entries))}
+	var doubles []float64
+	var ascii string
+	for _, e := range entries {
+		switch {
+		case e.double != nil:
+			dir = append(dir, uint16(e.key), uint16(GeoDoubleParams), uint16(len(e.double)), uint16(len(doubles)))
+			doubles = append(doubles, e.double...)
+		case e.ascii != "":
+			// Strings are terminated with a | in place of the NUL terminator
+			s := e.ascii + "|"
+			dir = append(dir, uint16(e.key), uint16(GeoASCIIParams), uint16(len(s)), uint16(len(ascii)))
+			ascii += s
+		default:
+			dir = append(dir, uint16(e.key), 0, 1, e.short)
+		}
+	}
+	tags := Tags{GeoKeyDirectory: shortTag(dir...)}
+	if len(doubles) > 0 {
+		tags[GeoDoubleParams] = doubleTag(doubles...)
+	}
+	if ascii != "" {
+		tags[GeoASCIIParams] = asciiTag(ascii)
+	}
+	return tags
+}
+
+// projectionEntries returns the GeoKeys of a user defined projection
+func (c *CRS) projectionEntries() []geoKeyEntry {
+	p := c.params
+	double := func(k GeoKey, v float64) geoKeyEntry {
+		return geoKeyEntry{key: k, double: []float64{v}}
+	}
+	entries := []geoKeyEntry{
+		{key: ProjectedCSTypeGeoKey, short: userDefined},
+		{key: ProjectionGeoKey, short: userDefined},
+		double(ProjFalseEastingGeoKey, p.falseEasting),
+		double(ProjFalseNorthingGeoKey, p.falseNorthing),
+	}
+	switch p.method {
+	case methodTransverseMercator:
+		entries = append(entries,
+			geoKeyEntry{key: ProjCoordTransGeoKey, short: coordTransTransverseMercator},
+			double(ProjNatOriginLatGeoKey, p.lat0),
+			double(ProjNatOriginLongGeoKey, p.lon0),
+			double(ProjScaleAtNatOriginGeoKey, p.k0),
+		)
+	case methodLambertConformalConic:
+		if p.lat1 == p.lat0 && p.lat2 == p.lat0 {
+			return append(entries,
+				geoKeyEntry{key: ProjCoordTransGeoKey, short: coordTransLambertConfConic1SP},
+				double(ProjNatOriginLatGeoKey, p.lat0),
+				double(ProjNatOriginLongGeoKey, p.lon0),
+				double(ProjScaleAtNatOriginGeoKey, p.k0),
+			)
+		}
+		entries = append(entries,
+			geoKeyEntry{key: ProjCoordTransGeoKey, short: coordTransLambertConfConic2SP},
+			double(ProjStdParallel1GeoKey, p.lat1),
+			double(ProjStdParallel2GeoKey, p.lat2),
+			double(ProjNatOriginLatGeoKey, p.lat0),
+			double(ProjNatOriginLongGeoKey, p.lon0),
+			double(ProjScaleAtNatOriginGeoKey, p.k0),
+		)
+	case methodAlbersEqualArea:
+		entries = append(entries,
+			geoKeyEntry{key: ProjCoordTransGeoKey, short: coordTransAlbersEqualArea},
+			double(ProjStdParallel1GeoKey, p.lat1),
+			double(ProjStdParallel2GeoKey, p.lat2),
+			double(ProjNatOriginLatGeoKey, p.lat0),
+			double(ProjNatOriginLongGeoKey, p.lon0),
+		)
+	}
+	return entries
+}
+
+// CRS returns the coordinate reference system of the image, read from its
+// GeoKeys
+func (g *GeoTIFF) CRS() (*CRS, error) {
+	keys, err := readGeoKeys(g.tags)
+	if err != nil {
+		return nil, err
+	}
+	return crsFromGeoKeys(keys)
+}
+
+// AtLonLat returns the value closest to a WGS 84 longitude and latitude,
+// transforming the point into the coordinate reference system of the image
+//
+// Interp indicates if bilinear interpolation should be done along
+// either direction
+func (g *GeoTIFF) AtLonLat(lon float64, lat float64, interp bool) (float32, error) {
+	crs, err := g.CRS()
+	if err != nil {
+		return 0, err
+	}
+	wgs84, err := CRSFromEPSG(epsgWGS84)
+	if err != nil {
+		return 0, err
+	}
+	p, err := Transform(Point{Lon: lon, Lat: lat}, wgs84, crs)
+	if err != nil {
+		return 0, err
+	}
+	return g.AtCoord(p.Lon, p.Lat, interp)
+}
+
+// boundsEdgePoints is the number of points each edge of the image is
+// divided into when transforming its bounds
+const boundsEdgePoints = 21
+
+// BoundsIn returns the bounding rectangle of the image in another coordinate
+// reference system
+//
+// Straight edges in one coordinate reference system are generally curved in
+// another, so each edge of the image is densified before being transformed,
+// and the smallest rectangle containing all the points is returned.
+func (g *GeoTIFF) BoundsIn(crs *CRS) (*CornerCoordinates, error) {
+	cc, err := g.Bounds()
+	if err != nil {
+		return nil, err
+	}
+	from, err := g.CRS()
+	if err != nil {
+		return nil, err
+	}
+	return transformBounds(cc, from, crs)
+}
+
+// transformBounds returns the bounding rectangle in the to coordinate
+// reference system of a rectangle in the from coordinate reference system,
+// densifying each edge before it is transformed
+func transformBounds(cc *CornerCoordinates, from *CRS, to *CRS) (*CornerCoordinates, error) {
+	if from.Equal(to) {
+		return cc, nil
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	edges := [][2]Point{
+		{cc.UpperLeft, cc.UpperRight},
+		{cc.UpperRight, cc.LowerRight},
+		{cc.LowerRight, cc.LowerLeft},
+		{cc.LowerLeft, cc.UpperLeft},
+	}
+	for _, e := range edges {
+		for i := 0; i < boundsEdgePoints; i++ {
+			f := float64(i) / float64(boundsEdgePoints-1)
+			p := Point{
+				Lon: e[0].Lon + f*(e[1].Lon-e[0].Lon),
+				Lat: e[0].Lat + f*(e[1].Lat-e[0].Lat),
+			}
+			q, err := Transform(p, from, to)
+			if err != nil {
+				return nil, err
+			}
+			minX, maxX = math.Min(minX, q.Lon), math.Max(maxX, q.Lon)
+			minY, maxY = math.Min(minY, q.Lat), math.Max(maxY, q.Lat)
+		}
+	}
+	return &CornerCoordinates{
+		UpperLeft:  Point{Lon: minX, Lat: maxY},
+		LowerLeft:  Point{Lon: minX, Lat: minY},
+		UpperRight: Point{Lon: maxX, Lat: maxY},
+		LowerRight: Point{Lon: maxX, Lat: minY},
+	}, nil
+}
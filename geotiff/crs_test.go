@@ -0,0 +1,218 @@
+package geotiff
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func Test_CRSFromEPSG_Happy(t *testing.T) {
+	tests := []struct {
+		code       int
+		name       string
+		geographic bool
+	}{
+		{4326, "WGS 84", true},
+		{4283, "GDA94", true},
+		{3857, "WGS 84 / Pseudo-Mercator", false},
+		{32755, "WGS 84 / UTM zone 55S", false},
+		{32601, "WGS 84 / UTM zone 1N", false},
+		{28355, "GDA94 / MGA zone 55", false},
+		{7856, "GDA2020 / MGA zone 56", false},
+		{3577, "GDA94 / Australian Albers", false},
+		{7845, "GDA2020 / GA LCC", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crs, err := CRSFromEPSG(tt.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if crs.EPSG != tt.code || crs.Name != tt.name || crs.Geographic() != tt.geographic {
+				t.Errorf("got %s geographic %v", crs, crs.Geographic())
+			}
+		})
+	}
+}
+
+func Test_CRSFromEPSG_Sad(t *testing.T) {
+	for _, code := range []int{0, 2193, 28347, 32661, 4269} {
+		if _, err := CRSFromEPSG(code); !errors.Is(err, ErrUnsupportedCRS) {
+			t.Errorf("EPSG:%d: got error %v want %v", code, err, ErrUnsupportedCRS)
+		}
+	}
+}
+
+func Test_Transform_Happy(t *testing.T) {
+	wgs84, _ := CRSFromEPSG(4326)
+	mga55, _ := CRSFromEPSG(28355)
+	mga54, _ := CRSFromEPSG(28354)
+
+	// Flinders Peak, from the GDA94 Technical Manual
+	flinders := Point{Lon: dms(144, 25, 29.5244), Lat: dms(-37, 57, 3.7203)}
+	got, err := Transform(flinders, wgs84, mga55)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !checkToTolerance(got.Lon, 273741.297, 1e-3) || !checkToTolerance(got.Lat, 5796489.777, 1e-3) {
+		t.Errorf("got %s", got)
+	}
+
+	// Between projected zones through geographic coordinates
+	inZone54, err := Transform(got, mga55, mga54)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := Transform(inZone54, mga54, wgs84)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !checkToTolerance(back.Lon, flinders.Lon, 1e-9) || !checkToTolerance(back.Lat, flinders.Lat, 1e-9) {
+		t.Errorf("got %s want %s", back, flinders)
+	}
+
+	same, err := Transform(flinders, wgs84, wgs84)
+	if err != nil || !same.Equals(flinders) {
+		t.Errorf("got %s, %v want %s", same, err, flinders)
+	}
+}
+
+func Test_Transform_Sad(t *testing.T) {
+	wgs84, _ := CRSFromEPSG(4326)
+	mercator, _ := CRSFromEPSG(3857)
+	if _, err := Transform(Point{Lon: 0, Lat: 91}, wgs84, mercator); err == nil {
+		t.Errorf("expected an error for a latitude outside -90 to 90")
+	}
+}
+
+func Test_GeoKeys_RoundTrip(t *testing.T) {
+	var crss []*CRS
+	for _, code := range []int{4326, 7844, 3857, 32755, 28356, 3577, 3112} {
+		crs, err := CRSFromEPSG(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		crss = append(crss, crs)
+	}
+	crss = append(crss,
+		newCRS(0, "custom TM", 0, crsParams{
+			method: methodTransverseMercator, ellipsoid: grs80Ellipsoid,
+			lat0: -10, lon0: 140, k0: 0.9999, falseEasting: 100000, falseNorthing: 200000,
+		}),
+		newCRS(0, "custom LCC 1SP", epsgGDA94, crsParams{
+			method: methodLambertConformalConic, ellipsoid: grs80Ellipsoid,
+			lat0: -30, lon0: 135, lat1: -30, lat2: -30, k0: 0.99,
+		}),
+		newCRS(0, "custom Albers", epsgWGS84, crsParams{
+			method: methodAlbersEqualArea, ellipsoid: wgs84Ellipsoid,
+			lat0: -20, lon0: 135, lat1: -15, lat2: -40, falseEasting: 1000,
+		}),
+	)
+
+	for _, want := range crss {
+		t.Run(want.Name, func(t *testing.T) {
+			keys, err := readGeoKeys(want.geoKeyTags())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := crsFromGeoKeys(keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(want) || got.EPSG != want.EPSG || got.Name != want.Name {
+				t.Errorf("got %s %+v want %s %+v", got, got.params, want, want.params)
+			}
+		})
+	}
+}
+
+func Test_GeoTIFF_CRS(t *testing.T) {
+	r, err := os.Open(testfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	g, err := Read(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crs, err := g.CRS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crs.EPSG != 4326 {
+		t.Errorf("got %s want EPSG:4326", crs)
+	}
+
+	t.Run("at lon lat", func(t *testing.T) {
+		want, err := g.AtCoord(137.5, -22.5, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := g.AtLonLat(137.5, -22.5, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("got %v want %v", got, want)
+		}
+	})
+
+	t.Run("bounds in web mercator", func(t *testing.T) {
+		mercator, _ := CRSFromEPSG(3857)
+		got, err := g.BoundsIn(mercator)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bounds, _ := g.Bounds()
+		upperLeft, _ := Transform(bounds.UpperLeft, crs, mercator)
+		lowerRight, _ := Transform(bounds.LowerRight, crs, mercator)
+		if !checkToTolerance(got.UpperLeft.Lon, upperLeft.Lon, 1e-6) || !checkToTolerance(got.UpperLeft.Lat, upperLeft.Lat, 1e-6) ||
+			!checkToTolerance(got.LowerRight.Lon, lowerRight.Lon, 1e-6) || !checkToTolerance(got.LowerRight.Lat, lowerRight.Lat, 1e-6) {
+			t.Errorf("got\n%swant %s %s", got, upperLeft, lowerRight)
+		}
+	})
+
+	t.Run("bounds in MGA", func(t *testing.T) {
+		// The image lies west of the central meridian of MGA zone 54, where the
+		// meridians converge towards the south, so the western most point is
+		// the upper left corner
+		mga54, _ := CRSFromEPSG(28354)
+		got, err := g.BoundsIn(mga54)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bounds, _ := g.Bounds()
+		for _, p := range []Point{bounds.UpperLeft, bounds.LowerLeft, bounds.UpperRight, bounds.LowerRight} {
+			q, _ := Transform(p, crs, mga54)
+			if !got.Contains(q) {
+				t.Errorf("bounds\n%sdo not contain %s", got, q)
+			}
+		}
+		upperLeft, _ := Transform(bounds.UpperLeft, crs, mga54)
+		lowerLeft, _ := Transform(bounds.LowerLeft, crs, mga54)
+		if !checkToTolerance(got.UpperLeft.Lon, upperLeft.Lon, 1e-6) || lowerLeft.Lon <= upperLeft.Lon {
+			t.Errorf("got\n%swant left edge %s", got, upperLeft)
+		}
+	})
+}
+
+func Test_AtLonLat_Projected(t *testing.T) {
+	// A 10x10 image of 1km pixels in MGA zone 55 around Flinders Peak, with
+	// each pixel holding its column plus 100 times its row
+	g := demGeoTIFF(t, 28355, 270000, 5800000, 1000, 10, 10, func(i, j int) float32 { return float32(i + 100*j) })
+
+	// Flinders Peak is at 273741.297, 5796489.777
+	got, err := g.AtLonLat(dms(144, 25, 29.5244), dms(-37, 57, 3.7203), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 303 {
+		t.Errorf("got %v want 303", got)
+	}
+
+	if _, err := g.AtLonLat(150, -37, false); err == nil {
+		t.Errorf("expected an error for a point outside the image")
+	}
+}
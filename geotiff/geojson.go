@@ -0,0 +1,131 @@
+package geotiff
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var errGeoJSON = errors.New("invalid GeoJSON")
+
+// GeoJSON geometry types
+//
+// See RFC 7946 (3.1)
+const (
+	GeometryPoint           = "Point"
+	GeometryMultiPoint      = "MultiPoint"
+	GeometryLineString      = "LineString"
+	GeometryMultiLineString = "MultiLineString"
+	GeometryPolygon         = "Polygon"
+	GeometryMultiPolygon    = "MultiPolygon"
+)
+
+// Geometry is a GeoJSON geometry
+//
+// Coordinates holds a position for a Point, a []Position for a MultiPoint or
+// LineString, a [][]Position for a MultiLineString or Polygon and a
+// [][][]Position for a MultiPolygon.
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// Position is a GeoJSON position, the longitude and latitude of a point
+// with an optional elevation
+type Position []float64
+
+// point returns the position as a Point
+func (p Position) point() Point {
+	return Point{Lon: p[0], Lat: p[1]}
+}
+
+// position returns the point as a Position
+func (p Point) position() Position {
+	return Position{p.Lon, p.Lat}
+}
+
+// UnmarshalJSON decodes a geometry, decoding the coordinates to the type
+// given by the geometry type
+func (g *Geometry) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	var coordinates interface{}
+	switch raw.Type {
+	case GeometryPoint:
+		coordinates = &Position{}
+	case GeometryMultiPoint, GeometryLineString:
+		coordinates = &[]Position{}
+	case GeometryMultiLineString, GeometryPolygon:
+		coordinates = &[][]Position{}
+	case GeometryMultiPolygon:
+		coordinates = &[][][]Position{}
+	default:
+		return fmt.Errorf("%w: unsupported geometry type %q", errGeoJSON, raw.Type)
+	}
+	if err := json.Unmarshal(raw.Coordinates, coordinates); err != nil {
+		return fmt.Errorf("%w: %s coordinates: %s", errGeoJSON, raw.Type, err)
+	}
+
+	g.Type = raw.Type
+	switch c := coordinates.(type) {
+	case *Position:
+		g.Coordinates = *c
+	case *[]Position:
+		g.Coordinates = *c
+	case *[][]Position:
+		g.Coordinates = *c
+	case *[][][]Position:
+		g.Coordinates = *c
+	}
+	return nil
+}
+
+// polygons returns the rings of a Polygon or MultiPolygon geometry, with a
+// Polygon returned as a single polygon
+func (g *Geometry) polygons() ([][][]Position, error) {
+	switch c := g.Coordinates.(type) {
+	case [][]Position:
+		if g.Type == GeometryPolygon {
+			return [][][]Position{c}, nil
+		}
+	case [][][]Position:
+		if g.Type == GeometryMultiPolygon {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s is not a %s or %s", errGeoJSON, g.Type, GeometryPolygon, GeometryMultiPolygon)
+}
+
+// Feature is a GeoJSON feature
+type Feature struct {
+	Type       string                 `json:"type"`
+	Geometry   *Geometry              `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// NewFeature returns a feature holding a geometry
+func NewFeature(geometry *Geometry, properties map[string]interface{}) Feature {
+	if properties == nil {
+		properties = map[string]interface{}{}
+	}
+	return Feature{Type: "Feature", Geometry: geometry, Properties: properties}
+}
+
+// FeatureCollection is a GeoJSON feature collection
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// NewFeatureCollection returns a feature collection holding features
+func NewFeatureCollection(features []Feature) *FeatureCollection {
+	if features == nil {
+		features = []Feature{}
+	}
+	return &FeatureCollection{Type: "FeatureCollection", Features: features}
+}
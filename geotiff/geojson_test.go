@@ -0,0 +1,53 @@
+package geotiff
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func Test_Geometry_Happy(t *testing.T) {
+	tests := []struct {
+		name     string
+		geometry Geometry
+	}{
+		{"point", Geometry{Type: GeometryPoint, Coordinates: Position{135, -20, 10}}},
+		{"line string", Geometry{Type: GeometryLineString, Coordinates: []Position{{135, -20}, {136, -21}}}},
+		{"polygon", Geometry{Type: GeometryPolygon, Coordinates: [][]Position{{{135, -20}, {136, -20}, {136, -21}, {135, -20}}}}},
+		{"multipolygon", Geometry{Type: GeometryMultiPolygon, Coordinates: [][][]Position{{{{135, -20}, {136, -20}, {136, -21}, {135, -20}}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(tt.geometry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got Geometry
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.geometry) {
+				t.Errorf("got %v want %v", got, tt.geometry)
+			}
+		})
+	}
+}
+
+func Test_Geometry_Sad(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"unsupported type", `{"type": "GeometryCollection", "geometries": []}`},
+		{"wrong nesting", `{"type": "Polygon", "coordinates": [135, -20]}`},
+		{"not an object", `[]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var g Geometry
+			if err := json.Unmarshal([]byte(tt.json), &g); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
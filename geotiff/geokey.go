@@ -0,0 +1,224 @@
+package geotiff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// GeoKey identifies a GeoTIFF configuration key
+//
+// From the OGC GeoTIFF Standard (Annex B)
+//
+// GeoKeys are stored in the GeoKeyDirectory tag, with values either held in
+// the directory itself, or in the GeoDoubleParams and GeoASCIIParams tags.
+type GeoKey uint16
+
+//nolint:unused
+const (
+	// GeoTIFF Configuration Keys
+	GTModelTypeGeoKey  GeoKey = 1024 // model coordinate system type
+	GTRasterTypeGeoKey GeoKey = 1025 // raster space, PixelIsArea or PixelIsPoint
+	GTCitationGeoKey   GeoKey = 1026 // ASCII description of the model
+
+	// Geographic CRS Parameter Keys
+	GeographicTypeGeoKey        GeoKey = 2048 // EPSG geographic CRS code
+	GeogCitationGeoKey          GeoKey = 2049
+	GeogGeodeticDatumGeoKey     GeoKey = 2050
+	GeogPrimeMeridianGeoKey     GeoKey = 2051
+	GeogLinearUnitsGeoKey       GeoKey = 2052
+	GeogAngularUnitsGeoKey      GeoKey = 2054
+	GeogEllipsoidGeoKey         GeoKey = 2056
+	GeogSemiMajorAxisGeoKey     GeoKey = 2057
+	GeogSemiMinorAxisGeoKey     GeoKey = 2058
+	GeogInvFlatteningGeoKey     GeoKey = 2059
+	GeogPrimeMeridianLongGeoKey GeoKey = 2061
+
+	// Projected CRS Parameter Keys
+	ProjectedCSTypeGeoKey          GeoKey = 3072 // EPSG projected CRS code
+	PCSCitationGeoKey              GeoKey = 3073
+	ProjectionGeoKey               GeoKey = 3074
+	ProjCoordTransGeoKey           GeoKey = 3075
+	ProjLinearUnitsGeoKey          GeoKey = 3076
+	ProjStdParallel1GeoKey         GeoKey = 3078
+	ProjStdParallel2GeoKey         GeoKey = 3079
+	ProjNatOriginLongGeoKey        GeoKey = 3080
+	ProjNatOriginLatGeoKey         GeoKey = 3081
+	ProjFalseEastingGeoKey         GeoKey = 3082
+	ProjFalseNorthingGeoKey        GeoKey = 3083
+	ProjFalseOriginLongGeoKey      GeoKey = 3084
+	ProjFalseOriginLatGeoKey       GeoKey = 3085
+	ProjFalseOriginEastingGeoKey   GeoKey = 3086
+	ProjFalseOriginNorthingGeoKey  GeoKey = 3087
+	ProjCenterLongGeoKey           GeoKey = 3088
+	ProjCenterLatGeoKey            GeoKey = 3089
+	ProjScaleAtNatOriginGeoKey     GeoKey = 3092
+	ProjScaleAtCenterGeoKey        GeoKey = 3093
+	ProjAzimuthAngleGeoKey         GeoKey = 3094
+	ProjStraightVertPoleLongGeoKey GeoKey = 3095
+
+	// Vertical CRS Parameter Keys
+	VerticalCSTypeGeoKey   GeoKey = 4096
+	VerticalCitationGeoKey GeoKey = 4097
+	VerticalDatumGeoKey    GeoKey = 4098
+	VerticalUnitsGeoKey    GeoKey = 4099
+)
+
+var geoKeyToLabel = map[GeoKey]string{
+	GTModelTypeGeoKey:              "GTModelTypeGeoKey",
+	GTRasterTypeGeoKey:             "GTRasterTypeGeoKey",
+	GTCitationGeoKey:               "GTCitationGeoKey",
+	GeographicTypeGeoKey:           "GeographicTypeGeoKey",
+	GeogCitationGeoKey:             "GeogCitationGeoKey",
+	GeogGeodeticDatumGeoKey:        "GeogGeodeticDatumGeoKey",
+	GeogPrimeMeridianGeoKey:        "GeogPrimeMeridianGeoKey",
+	GeogLinearUnitsGeoKey:          "GeogLinearUnitsGeoKey",
+	GeogAngularUnitsGeoKey:         "GeogAngularUnitsGeoKey",
+	GeogEllipsoidGeoKey:            "GeogEllipsoidGeoKey",
+	GeogSemiMajorAxisGeoKey:        "GeogSemiMajorAxisGeoKey",
+	GeogSemiMinorAxisGeoKey:        "GeogSemiMinorAxisGeoKey",
+	GeogInvFlatteningGeoKey:        "GeogInvFlatteningGeoKey",
+	GeogPrimeMeridianLongGeoKey:    "GeogPrimeMeridianLongGeoKey",
+	ProjectedCSTypeGeoKey:          "ProjectedCSTypeGeoKey",
+	PCSCitationGeoKey:              "PCSCitationGeoKey",
+	ProjectionGeoKey:               "ProjectionGeoKey",
+	ProjCoordTransGeoKey:           "ProjCoordTransGeoKey",
+	ProjLinearUnitsGeoKey:          "ProjLinearUnitsGeoKey",
+	ProjStdParallel1GeoKey:         "ProjStdParallel1GeoKey",
+	ProjStdParallel2GeoKey:         "ProjStdParallel2GeoKey",
+	ProjNatOriginLongGeoKey:        "ProjNatOriginLongGeoKey",
+	ProjNatOriginLatGeoKey:         "ProjNatOriginLatGeoKey",
+	ProjFalseEastingGeoKey:         "ProjFalseEastingGeoKey",
+	ProjFalseNorthingGeoKey:        "ProjFalseNorthingGeoKey",
+	ProjFalseOriginLongGeoKey:      "ProjFalseOriginLongGeoKey",
+	ProjFalseOriginLatGeoKey:       "ProjFalseOriginLatGeoKey",
+	ProjFalseOriginEastingGeoKey:   "ProjFalseOriginEastingGeoKey",
+	ProjFalseOriginNorthingGeoKey:  "ProjFalseOriginNorthingGeoKey",
+	ProjCenterLongGeoKey:           "ProjCenterLongGeoKey",
+	ProjCenterLatGeoKey:            "ProjCenterLatGeoKey",
+	ProjScaleAtNatOriginGeoKey:     "ProjScaleAtNatOriginGeoKey",
+	ProjScaleAtCenterGeoKey:        "ProjScaleAtCenterGeoKey",
+	ProjAzimuthAngleGeoKey:         "ProjAzimuthAngleGeoKey",
+	ProjStraightVertPoleLongGeoKey: "ProjStraightVertPoleLongGeoKey",
+	VerticalCSTypeGeoKey:           "VerticalCSTypeGeoKey",
+	VerticalCitationGeoKey:         "VerticalCitationGeoKey",
+	VerticalDatumGeoKey:            "VerticalDatumGeoKey",
+	VerticalUnitsGeoKey:            "VerticalUnitsGeoKey",
+}
+
+func (k GeoKey) String() string {
+	v, ok := geoKeyToLabel[k]
+	if !ok {
+		return fmt.Sprintf("GeoKey %d", k)
+	}
+	return v
+}
+
+// From the OGC GeoTIFF Standard (Annex B)
+//
+// Values of the GTModelTypeGeoKey and GTRasterTypeGeoKey
+//
+//nolint:unused
+const (
+	modelTypeProjected  uint16 = 1 // projected coordinate reference system
+	modelTypeGeographic uint16 = 2 // geographic 2D coordinate reference system
+	modelTypeGeocentric uint16 = 3 // geocentric cartesian 3D coordinate reference system
+
+	rasterPixelIsArea  uint16 = 1
+	rasterPixelIsPoint uint16 = 2
+
+	// userDefined marks a key whose value is described by other keys,
+	// rather than an EPSG code
+	userDefined uint16 = 32767
+)
+
+var errGeoKeys = errors.New("invalid GeoKeyDirectory")
+
+// geoKeyValue is the value of a single GeoKey, only one of the fields is used
+type geoKeyValue struct {
+	shortData  []uint16
+	doubleData []float64
+	asciiData  string
+}
+
+// geoKeys holds the GeoKeys of an image
+type geoKeys map[GeoKey]geoKeyValue
+
+// short returns the value of a SHORT GeoKey
+func (k geoKeys) short(key GeoKey) (uint16, bool) {
+	v, ok := k[key]
+	if !ok || len(v.shortData) == 0 {
+		return 0, false
+	}
+	return v.shortData[0], true
+}
+
+// double returns the value of a DOUBLE GeoKey
+func (k geoKeys) double(key GeoKey) (float64, bool) {
+	v, ok := k[key]
+	if !ok || len(v.doubleData) == 0 {
+		return 0, false
+	}
+	return v.doubleData[0], true
+}
+
+// readGeoKeys parses the GeoKeyDirectory and the parameters it references
+//
+// From the OGC GeoTIFF Standard (Annex B)
+//
+// The GeoKeyDirectory begins with a header of KeyDirectoryVersion,
+// KeyRevision, MinorRevision and NumberOfKeys, followed by NumberOfKeys
+// entries of KeyID, TIFFTagLocation, Count and ValueOffset. A TIFFTagLocation
+// of 0 means the value is held in the ValueOffset itself.
+func readGeoKeys(tags Tags) (geoKeys, error) {
+	dir, err := tags.Uint64s(GeoKeyDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errGeoKeys, err)
+	}
+	if len(dir) < 4 {
+		return nil, fmt.Errorf("%w: missing header", errGeoKeys)
+	}
+	n := int(dir[3])
+	if len(dir) < 4+4*n {
+		return nil, fmt.Errorf("%w: %d keys do not fit in %d values", errGeoKeys, n, len(dir))
+	}
+	doubles, _ := tags.Float64s(GeoDoubleParams)
+	ascii, _ := tags.ASCII(GeoASCIIParams)
+
+	keys := make(geoKeys, n)
+	for i := 0; i < n; i++ {
+		e := dir[4+4*i : 8+4*i]
+		key, location, count, offset := GeoKey(e[0]), Tag(e[1]), int(e[2]), int(e[3])
+		switch location {
+		case 0:
+			keys[key] = geoKeyValue{shortData: []uint16{uint16(offset)}}
+		case GeoKeyDirectory:
+			if offset+count > len(dir) {
+				return nil, fmt.Errorf("%w: %s values out of range", errGeoKeys, key)
+			}
+			v := make([]uint16, count)
+			for j := range v {
+				v[j] = uint16(dir[offset+j])
+			}
+			keys[key] = geoKeyValue{shortData: v}
+		case GeoDoubleParams:
+			if offset+count > len(doubles) {
+				return nil, fmt.Errorf("%w: %s values out of range", errGeoKeys, key)
+			}
+			keys[key] = geoKeyValue{doubleData: doubles[offset : offset+count]}
+		case GeoASCIIParams:
+			if offset+count > len(ascii)+1 {
+				return nil, fmt.Errorf("%w: %s values out of range", errGeoKeys, key)
+			}
+			// Strings are terminated with a | in place of the NUL terminator
+			end := offset + count
+			if end > len(ascii) {
+				end = len(ascii)
+			}
+			keys[key] = geoKeyValue{asciiData: strings.TrimRight(ascii[offset:end], "|")}
+		default:
+			return nil, fmt.Errorf("%w: %s stored in unsupported tag %s", errGeoKeys, key, location)
+		}
+	}
+	return keys, nil
+}
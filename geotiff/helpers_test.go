@@ -0,0 +1,53 @@
+package geotiff
+
+import (
+	"testing"
+)
+
+// demGeoTIFF returns an image with the upper left corner at x, y in a
+// coordinate reference system, where pixel i, j holds f(i, j)
+func demGeoTIFF(t *testing.T, epsg int, x float64, y float64, scale float64, width int, length int, f func(i int, j int) float32) *GeoTIFF {
+	t.Helper()
+	crs, err := CRSFromEPSG(epsg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raster := make([]float32, width*length)
+	for k := range raster {
+		raster[k] = f(k%width, k/width)
+	}
+	tags := crs.geoKeyTags()
+	tags[ModelTiepoint] = doubleTag(0, 0, 0, x, y, 0)
+	g, err := New(tile(raster, width, length, 16, 16), uint16(width), uint16(length), 16, 16, scale, scale, tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// rampGeoTIFF returns a WGS 84 image of 0.1 degree pixels, with an upper
+// left corner at 135, -20, where each pixel holds x + 10 * y
+func rampGeoTIFF(t *testing.T, width int, length int) *GeoTIFF {
+	t.Helper()
+	return demGeoTIFF(t, 4326, 135, -20, 0.1, width, length, func(i, j int) float32 { return float32(i + 10*j) })
+}
+
+// constGeoTIFF returns a WGS 84 image with its upper left corner at x, y
+// where every pixel holds v
+func constGeoTIFF(t *testing.T, x float64, y float64, scale float64, width int, length int, v float32) *GeoTIFF {
+	t.Helper()
+	return demGeoTIFF(t, 4326, x, y, scale, width, length, func(i, j int) float32 { return v })
+}
+
+// centre returns the centre of pixel i, j
+func centre(t *testing.T, g *GeoTIFF, i int, j int) Point {
+	t.Helper()
+	b, err := g.Bounds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Point{
+		Lon: b.UpperLeft.Lon + (float64(i)+0.5)*g.PixelScaleX,
+		Lat: b.UpperLeft.Lat - (float64(j)+0.5)*g.PixelScaleY,
+	}
+}
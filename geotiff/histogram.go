@@ -0,0 +1,234 @@
+package geotiff
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var errHistogram = errors.New("unable to compute histogram")
+
+// defaultBins is the number of histogram bins if none are given
+const defaultBins = 256
+
+// HistogramOptions configures Histogram
+type HistogramOptions struct {
+	// Bins is the number of equal width bins between Min and Max.
+	//
+	// Defaults to 256
+	Bins int
+
+	// Min and Max are the range of the bins.
+	//
+	// Default to the minimum and maximum valid values of the image
+	Min *float64
+	Max *float64
+
+	// Edges are explicit bin edges in increasing order, so n edges make n - 1
+	// bins. Edges take precedence over Bins, Min and Max.
+	Edges []float64
+
+	// IncludeOutOfRange counts values below the first edge in the first bin
+	// and values above the last edge in the last bin, rather than counting
+	// them separately in Below and Above
+	IncludeOutOfRange bool
+}
+
+// Histogram is the distribution of the valid values of an image
+//
+// Each bin includes its lower edge and excludes its upper edge, apart from
+// the last bin which includes both.
+type Histogram struct {
+	Edges  []float64 // bin edges, one more than the number of bins
+	Counts []int     // number of values in each bin
+	Below  int       // number of values below the first edge
+	Above  int       // number of values above the last edge
+	NoData int       // number of nodata, NaN or infinite values
+}
+
+// Total returns the number of values in the bins
+func (h *Histogram) Total() int {
+	total := 0
+	for _, c := range h.Counts {
+		total += c
+	}
+	return total
+}
+
+// Percentile returns an approximation of the p-th percentile, for p between
+// 0 and 100, of the values in the bins
+//
+// Values are assumed to be spread evenly across each bin, so the error is
+// at most the width of a bin. Values outside the range of the bins are not
+// included unless the histogram was computed with IncludeOutOfRange. Use
+// GeoTIFF.Percentiles for exact percentiles.
+func (h *Histogram) Percentile(p float64) (float64, error) {
+	if !(p >= 0 && p <= 100) {
+		return 0, fmt.Errorf("%w: percentile %v is outside 0 to 100", errHistogram, p)
+	}
+	total := h.Total()
+	if total == 0 {
+		return 0, fmt.Errorf("%w: no values in the bins", errHistogram)
+	}
+
+	rank := p / 100 * float64(total)
+	cumulative := 0.0
+	for i, c := range h.Counts {
+		if c == 0 {
+			continue
+		}
+		if next := cumulative + float64(c); rank <= next {
+			return h.Edges[i] + (rank-cumulative)/float64(c)*(h.Edges[i+1]-h.Edges[i]), nil
+		}
+		cumulative += float64(c)
+	}
+	return h.Edges[len(h.Edges)-1], nil
+}
+
+// Histogram returns the histogram of the valid values of the image
+//
+// opts may be nil, in which case 256 equal width bins span the range of the
+// values.
+func (g *GeoTIFF) Histogram(opts *HistogramOptions) (*Histogram, error) {
+	if opts == nil {
+		opts = &HistogramOptions{}
+	}
+	values, noData := g.validValues()
+
+	edges := opts.Edges
+	if edges == nil {
+		var err error
+		edges, err = opts.equalWidthEdges(values)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(edges) < 2 {
+		return nil, fmt.Errorf("%w: %d edges, at least 2 are required", errHistogram, len(edges))
+	}
+	for i := 1; i < len(edges); i++ {
+		if !(edges[i] > edges[i-1]) {
+			return nil, fmt.Errorf("%w: edges are not increasing at %d", errHistogram, i)
+		}
+	}
+
+	h := &Histogram{
+		Edges:  append([]float64(nil), edges...),
+		Counts: make([]int, len(edges)-1),
+		NoData: noData,
+	}
+	last := len(h.Counts) - 1
+	for _, v := range values {
+		v := float64(v)
+		switch {
+		case v < edges[0]:
+			if !opts.IncludeOutOfRange {
+				h.Below++
+				continue
+			}
+			h.Counts[0]++
+		case v > edges[len(edges)-1]:
+			if !opts.IncludeOutOfRange {
+				h.Above++
+				continue
+			}
+			h.Counts[last]++
+		default:
+			// The first edge greater than the value closes its bin
+			i := sort.Search(len(edges), func(i int) bool { return edges[i] > v }) - 1
+			h.Counts[clamp(i, 0, last)]++
+		}
+	}
+	return h, nil
+}
+
+// equalWidthEdges returns the edges of equal width bins over the range of
+// the options, or of the values
+func (o *HistogramOptions) equalWidthEdges(values []float32) ([]float64, error) {
+	bins := o.Bins
+	if bins == 0 {
+		bins = defaultBins
+	}
+	if bins < 0 {
+		return nil, fmt.Errorf("%w: %d bins", errHistogram, bins)
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		lo, hi = math.Min(lo, float64(v)), math.Max(hi, float64(v))
+	}
+	if len(values) == 0 {
+		lo, hi = 0, 1
+	}
+	if o.Min != nil {
+		lo = *o.Min
+	}
+	if o.Max != nil {
+		hi = *o.Max
+	}
+	// A single value is given a bin either side of it
+	if lo == hi && o.Min == nil && o.Max == nil {
+		lo, hi = lo-0.5, hi+0.5
+	}
+	if !(hi > lo) || math.IsInf(lo, 0) || math.IsInf(hi, 0) {
+		return nil, fmt.Errorf("%w: range %v to %v is empty", errHistogram, lo, hi)
+	}
+
+	edges := make([]float64, bins+1)
+	for i := range edges {
+		edges[i] = lo + (hi-lo)*float64(i)/float64(bins)
+	}
+	edges[bins] = hi
+	return edges, nil
+}
+
+// Percentiles returns the exact p-th percentiles, for p between 0 and 100,
+// of the valid values of the image
+//
+// Percentiles are linearly interpolated between the closest ranks, in the
+// same way as the default method of numpy.percentile. For example
+// Percentiles(5, 50, 95) returns the 5th percentile, median and 95th
+// percentile.
+func (g *GeoTIFF) Percentiles(p ...float64) ([]float64, error) {
+	for _, v := range p {
+		if !(v >= 0 && v <= 100) {
+			return nil, fmt.Errorf("%w: percentile %v is outside 0 to 100", errHistogram, v)
+		}
+	}
+	values, _ := g.validValues()
+	if len(values) == 0 {
+		return nil, fmt.Errorf("%w: no valid values", errHistogram)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	out := make([]float64, len(p))
+	for i, v := range p {
+		out[i] = percentile(values, v)
+	}
+	return out, nil
+}
+
+// percentile returns the p-th percentile of sorted values, linearly
+// interpolated between the closest ranks
+func percentile(sorted []float32, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return float64(sorted[lo]) + (rank-float64(lo))*(float64(sorted[hi])-float64(sorted[lo]))
+}
+
+// validValues returns the values of the image which are not nodata, NaN or
+// infinite, and the number of values which are
+func (g *GeoTIFF) validValues() ([]float32, int) {
+	nd := g.noData()
+	raster := g.raster()
+	values := raster[:0]
+	for _, v := range raster {
+		if nd.is(v) || math.IsInf(float64(v), 0) {
+			continue
+		}
+		values = append(values, v)
+	}
+	return values, len(raster) - len(values)
+}
@@ -0,0 +1,146 @@
+package geotiff
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func Test_Histogram_Happy(t *testing.T) {
+	// rampGeoTIFF holds each of 0 to 99 once
+	g := rampGeoTIFF(t, 10, 10)
+	lo, hi := 0.0, 200.0
+
+	tests := []struct {
+		name   string
+		opts   *HistogramOptions
+		counts []int
+		below  int
+		above  int
+	}{
+		{"bin count", &HistogramOptions{Bins: 10}, []int{10, 10, 10, 10, 10, 10, 10, 10, 10, 10}, 0, 0},
+		{"range", &HistogramOptions{Bins: 4, Min: &lo, Max: &hi}, []int{50, 50, 0, 0}, 0, 0},
+		{"edges", &HistogramOptions{Edges: []float64{10, 20, 50}}, []int{10, 31}, 10, 49},
+		{"edges including out of range", &HistogramOptions{Edges: []float64{10, 20, 50}, IncludeOutOfRange: true}, []int{20, 80}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := g.Histogram(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(h.Counts, tt.counts) || h.Below != tt.below || h.Above != tt.above {
+				t.Errorf("got counts %v below %d above %d want %v, %d, %d", h.Counts, h.Below, h.Above, tt.counts, tt.below, tt.above)
+			}
+			if len(h.Edges) != len(h.Counts)+1 {
+				t.Errorf("got %d edges for %d bins", len(h.Edges), len(h.Counts))
+			}
+		})
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		h, err := g.Histogram(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(h.Counts) != defaultBins || h.Total() != 100 || h.Edges[0] != 0 || h.Edges[defaultBins] != 99 {
+			t.Errorf("got %d bins, %d values from %v to %v", len(h.Counts), h.Total(), h.Edges[0], h.Edges[defaultBins])
+		}
+	})
+
+	t.Run("nodata", func(t *testing.T) {
+		g := rampGeoTIFF(t, 10, 10)
+		g.SetNoData(0)
+		g.data[0][1] = float32(math.NaN())
+		g.data[0][2] = float32(math.Inf(1))
+		h, err := g.Histogram(&HistogramOptions{Bins: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.NoData != 3 || h.Total() != 97 || h.Edges[0] != 3 {
+			t.Errorf("got nodata %d total %d first edge %v want 3, 97, 3", h.NoData, h.Total(), h.Edges[0])
+		}
+	})
+}
+
+func Test_Histogram_Sad(t *testing.T) {
+	g := rampGeoTIFF(t, 10, 10)
+	lo, hi := 5.0, 5.0
+	tests := []struct {
+		name string
+		opts *HistogramOptions
+	}{
+		{"one edge", &HistogramOptions{Edges: []float64{1}}},
+		{"decreasing edges", &HistogramOptions{Edges: []float64{1, 3, 2}}},
+		{"negative bins", &HistogramOptions{Bins: -1}},
+		{"empty range", &HistogramOptions{Min: &lo, Max: &hi}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := g.Histogram(tt.opts); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func Test_Percentiles_Happy(t *testing.T) {
+	g := rampGeoTIFF(t, 10, 10)
+	p := []float64{0, 5, 50, 95, 100}
+	want := []float64{0, 4.95, 49.5, 94.05, 99}
+
+	t.Run("exact", func(t *testing.T) {
+		got, err := g.Percentiles(p...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range want {
+			if !checkToTolerance(got[i], want[i], 1e-9) {
+				t.Errorf("percentile %v: got %v want %v", p[i], got[i], want[i])
+			}
+		}
+	})
+
+	t.Run("approximate", func(t *testing.T) {
+		h, err := g.Histogram(&HistogramOptions{Bins: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range want {
+			got, err := h.Percentile(p[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Within the width of a bin
+			if !checkToTolerance(got, want[i], 0.99) {
+				t.Errorf("percentile %v: got %v want %v", p[i], got, want[i])
+			}
+		}
+	})
+}
+
+func Test_Percentiles_Sad(t *testing.T) {
+	g := rampGeoTIFF(t, 10, 10)
+	if _, err := g.Percentiles(101); err == nil {
+		t.Error("expected an error for a percentile above 100")
+	}
+	h, err := g.Histogram(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Percentile(-1); err == nil {
+		t.Error("expected an error for a negative percentile")
+	}
+
+	empty := constGeoTIFF(t, 135, -20, 0.1, 2, 2, float32(math.NaN()))
+	if _, err := empty.Percentiles(50); err == nil {
+		t.Error("expected an error for an image without valid values")
+	}
+	h, err = empty.Histogram(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Percentile(50); err == nil {
+		t.Error("expected an error for an empty histogram")
+	}
+}
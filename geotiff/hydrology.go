@@ -0,0 +1,427 @@
+package geotiff
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var errHydrology = errors.New("unable to compute hydrology")
+
+// flowNoData is the value of flow direction and accumulation pixels without
+// elevation
+const flowNoData = -1
+
+// d8Neighbours are the offsets of the eight neighbours of a pixel, clockwise
+// from the east with rows running down. The D8 code of a direction is 1
+// shifted left by its index.
+var d8Neighbours = [8][2]int{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+
+// FlowMethod selects how water leaving a pixel is routed to its neighbours
+type FlowMethod int
+
+const (
+	// D8 sends all the flow of a pixel to the neighbour with the steepest
+	// descent.
+	//
+	// See J. O'Callaghan and D. Mark (1984), The extraction of drainage
+	// networks from digital elevation data
+	D8 FlowMethod = iota
+
+	// DInfinity sends the flow of a pixel down the steepest of the eight
+	// triangular facets around it, shared between the two neighbours either
+	// side of the direction of descent.
+	//
+	// See D. Tarboton (1997), A new method for the determination of flow
+	// directions and upslope areas in grid digital elevation models
+	DInfinity
+)
+
+var flowMethodToLabel = map[FlowMethod]string{
+	D8:        "d8",
+	DInfinity: "d-infinity",
+}
+
+func (m FlowMethod) String() string {
+	v, ok := flowMethodToLabel[m]
+	if !ok {
+		return fmt.Sprintf("unrecognized flow method %d", int(m))
+	}
+	return v
+}
+
+// FillOptions configures FillSinks
+type FillOptions struct {
+	// Epsilon is the least drop from each filled pixel to the pixel it
+	// drains to, so that filled depressions slope gently to their outlet
+	// rather than being left flat. Any positive value rises by at least the
+	// smallest step of a float32.
+	//
+	// Defaults to zero, leaving filled depressions flat
+	Epsilon float64
+}
+
+// FlowOptions configures FlowDirection and FlowAccumulation
+type FlowOptions struct {
+	// Method is D8 or DInfinity.
+	//
+	// Defaults to D8
+	Method FlowMethod
+}
+
+// FillSinks raises the depressions of an elevation image to the level of
+// their lowest outlet, so that every pixel drains to the edge of the data
+//
+// Pixels on the edges of the image or next to nodata pixels are outlets.
+// The depressions are filled by priority flood, working inwards from the
+// outlets in order of elevation.
+//
+// See R. Barnes, C. Lehman and D. Mulla (2014), Priority-flood: an optimal
+// depression-filling and watershed-labeling algorithm for digital elevation
+// models. opts may be nil.
+func (g *GeoTIFF) FillSinks(opts *FillOptions) (*GeoTIFF, error) {
+	if opts == nil {
+		opts = &FillOptions{}
+	}
+	if !(opts.Epsilon >= 0) || math.IsInf(opts.Epsilon, 0) {
+		return nil, fmt.Errorf("%w: epsilon %v must be zero or positive", errHydrology, opts.Epsilon)
+	}
+	bounds, err := g.Bounds()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errHydrology, err)
+	}
+
+	width, length := int(g.imageWidth), int(g.imageLength)
+	nd := g.noData()
+	z := g.raster()
+	closed := make([]bool, len(z))
+	open := &pixelHeap{}
+	for k, v := range z {
+		if nd.is(v) {
+			closed[k] = true
+		} else if edge(z, width, length, k, nd) {
+			closed[k] = true
+			heap.Push(open, pixel{k, v})
+		}
+	}
+
+	// Pixels raised to the level of a depression are filled outwards from
+	// where it was entered before the rest of the open pixels
+	var pit []int
+	for open.Len() > 0 || len(pit) > 0 {
+		var c int
+		if len(pit) > 0 {
+			c, pit = pit[0], pit[1:]
+		} else {
+			c = heap.Pop(open).(pixel).k
+		}
+		level := z[c]
+		if opts.Epsilon > 0 {
+			level = float32(float64(z[c]) + opts.Epsilon)
+			if level <= z[c] {
+				level = math.Nextafter32(z[c], float32(math.Inf(1)))
+			}
+		}
+		for _, n := range d8Neighbours {
+			i, j := c%width+n[0], c/width+n[1]
+			if i < 0 || i >= width || j < 0 || j >= length || closed[j*width+i] {
+				continue
+			}
+			m := j*width + i
+			closed[m] = true
+			if z[m] <= level {
+				z[m] = level
+				pit = append(pit, m)
+				continue
+			}
+			heap.Push(open, pixel{m, z[m]})
+		}
+	}
+	return g.derive(z, width, length, bounds.UpperLeft, g.PixelScaleX, g.PixelScaleY), nil
+}
+
+// edge reports if pixel k has data and lies on the edge of the image or next
+// to a nodata pixel
+func edge(raster []float32, width int, length int, k int, nd noData) bool {
+	for _, n := range d8Neighbours {
+		i, j := k%width+n[0], k/width+n[1]
+		if i < 0 || i >= width || j < 0 || j >= length || nd.is(raster[j*width+i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// pixel is an entry of a pixelHeap
+type pixel struct {
+	k int
+	z float32
+}
+
+// pixelHeap is a priority queue of pixels, lowest first
+type pixelHeap []pixel
+
+func (h pixelHeap) Len() int            { return len(h) }
+func (h pixelHeap) Less(i, j int) bool  { return h[i].z < h[j].z }
+func (h pixelHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *pixelHeap) Push(x interface{}) { *h = append(*h, x.(pixel)) }
+func (h *pixelHeap) Pop() interface{} {
+	old := *h
+	p := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return p
+}
+
+// drainage holds the neighbours each pixel of an elevation image drains to
+type drainage struct {
+	width  int
+	length int
+	valid  []bool // the pixel has an elevation
+	// The pixels each pixel drains to, or -1, with the share of the flow
+	// going to the first
+	to    [][2]int
+	share []float64
+	// The D8 code or D-infinity angle of each pixel
+	direction []float32
+}
+
+// drainage returns where each pixel of the image drains to
+//
+// Distances are measured in metres, as for Slope. A pixel only drains to
+// neighbours with data that are lower than it, so pixels in pits, on flats
+// or at outlets drain nowhere.
+func (g *GeoTIFF) drainage(method FlowMethod) (*drainage, error) {
+	if _, ok := flowMethodToLabel[method]; !ok {
+		return nil, fmt.Errorf("%w: %s", errHydrology, method)
+	}
+	crs, err := g.CRS()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errHydrology, err)
+	}
+	bounds, err := g.Bounds()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errHydrology, err)
+	}
+
+	raster := g.raster()
+	width, length := int(g.imageWidth), int(g.imageLength)
+	nd := g.noData()
+	d := &drainage{
+		width:     width,
+		length:    length,
+		valid:     make([]bool, len(raster)),
+		to:        make([][2]int, len(raster)),
+		share:     make([]float64, len(raster)),
+		direction: make([]float32, len(raster)),
+	}
+	for k, v := range raster {
+		d.valid[k] = !nd.is(v)
+		d.to[k] = [2]int{-1, -1}
+	}
+	at := func(i int, j int) (float64, bool) {
+		if i < 0 || i >= width || j < 0 || j >= length || !d.valid[j*width+i] {
+			return 0, false
+		}
+		return float64(raster[j*width+i]), true
+	}
+
+	for j := 0; j < length; j++ {
+		dx, dy := g.pixelSize(crs, bounds.UpperLeft.Lat-(float64(j)+0.5)*g.PixelScaleY)
+		for i := 0; i < width; i++ {
+			k := j*width + i
+			if !d.valid[k] {
+				d.direction[k] = flowNoData
+				continue
+			}
+			z0 := float64(raster[k])
+			switch method {
+			case D8:
+				best := 0.0
+				for n, o := range d8Neighbours {
+					z, ok := at(i+o[0], j+o[1])
+					if !ok {
+						continue
+					}
+					if s := (z0 - z) / math.Hypot(float64(o[0])*dx, float64(o[1])*dy); s > best {
+						best = s
+						d.to[k] = [2]int{(j+o[1])*width + i + o[0], -1}
+						d.share[k] = 1
+						d.direction[k] = float32(int(1) << n)
+					}
+				}
+			case DInfinity:
+				d.direction[k] = flowNoData
+				best := 0.0
+				for _, f := range dInfinityFacets {
+					e1, ok1 := at(i+f.e1[0], j+f.e1[1])
+					e2, ok2 := at(i+f.e2[0], j+f.e2[1])
+					d1, d2 := dx, dy
+					if f.e1[0] == 0 {
+						d1, d2 = dy, dx
+					}
+					rMax := math.Atan2(d2, d1)
+
+					// The direction of descent across the facet, from the
+					// first neighbour towards the second, is kept to the
+					// facet, falling back to its edges
+					var r, s float64
+					switch {
+					case ok1 && ok2:
+						s1, s2 := (z0-e1)/d1, (e1-e2)/d2
+						r, s = math.Atan2(s2, s1), math.Hypot(s1, s2)
+						if r < 0 {
+							r, s = 0, s1
+						} else if r > rMax {
+							r, s = rMax, (z0-e2)/math.Hypot(d1, d2)
+						}
+					case ok1:
+						r, s = 0, (z0-e1)/d1
+					case ok2:
+						r, s = rMax, (z0-e2)/math.Hypot(d1, d2)
+					default:
+						continue
+					}
+					if s <= best {
+						continue
+					}
+					best = s
+					first, second := (j+f.e1[1])*width+i+f.e1[0], (j+f.e2[1])*width+i+f.e2[0]
+					switch {
+					case r == 0:
+						d.to[k], d.share[k] = [2]int{first, -1}, 1
+					case r == rMax:
+						d.to[k], d.share[k] = [2]int{second, -1}, 1
+					default:
+						d.to[k], d.share[k] = [2]int{first, second}, 1-r/rMax
+					}
+					angle := f.ac*math.Pi/2 + f.af*r
+					if angle >= 2*math.Pi {
+						angle -= 2 * math.Pi
+					}
+					d.direction[k] = float32(angle)
+				}
+			}
+		}
+	}
+	return d, nil
+}
+
+// dInfinityFacets are the eight triangular facets around a pixel, each
+// between a direct neighbour e1 and a diagonal neighbour e2, with angles
+// measured counterclockwise from the east as ac * pi / 2 + af * r for a
+// direction r from e1 towards e2
+var dInfinityFacets = [8]struct {
+	e1, e2 [2]int
+	ac, af float64
+}{
+	{[2]int{1, 0}, [2]int{1, -1}, 0, 1},
+	{[2]int{0, -1}, [2]int{1, -1}, 1, -1},
+	{[2]int{0, -1}, [2]int{-1, -1}, 1, 1},
+	{[2]int{-1, 0}, [2]int{-1, -1}, 2, -1},
+	{[2]int{-1, 0}, [2]int{-1, 1}, 2, 1},
+	{[2]int{0, 1}, [2]int{-1, 1}, 3, -1},
+	{[2]int{0, 1}, [2]int{1, 1}, 3, 1},
+	{[2]int{1, 0}, [2]int{1, 1}, 4, -1},
+}
+
+// accumulation returns the number of pixels draining through each pixel,
+// including itself, visiting each pixel after all the pixels draining to it
+func (d *drainage) accumulation() []float64 {
+	inflow := make([]int, len(d.valid))
+	for _, to := range d.to {
+		for _, m := range to {
+			if m >= 0 {
+				inflow[m]++
+			}
+		}
+	}
+	acc := make([]float64, len(d.valid))
+	var queue []int
+	for k, valid := range d.valid {
+		if valid {
+			acc[k] = 1
+			if inflow[k] == 0 {
+				queue = append(queue, k)
+			}
+		}
+	}
+	for len(queue) > 0 {
+		k := queue[0]
+		queue = queue[1:]
+		for n, m := range d.to[k] {
+			if m < 0 {
+				continue
+			}
+			share := d.share[k]
+			if n == 1 {
+				share = 1 - share
+			}
+			acc[m] += share * acc[k]
+			if inflow[m]--; inflow[m] == 0 {
+				queue = append(queue, m)
+			}
+		}
+	}
+	return acc
+}
+
+// FlowDirection returns the direction water flows from each pixel of an
+// elevation image
+//
+// D8 directions are coded clockwise from the east as 1, 2, 4, 8, 16, 32, 64
+// and 128 for the east, south east, south, south west, west, north west,
+// north and north east, with 0 for pixels that drain nowhere. DInfinity
+// directions are angles in radians counterclockwise from the east, from 0
+// to 2 pi, with pixels that drain nowhere set to nodata. Pixels without
+// elevation are nodata, -1.
+//
+// Pits and flats drain nowhere, so the image is usually filled first with
+// FillSinks and a positive epsilon. opts may be nil.
+func (g *GeoTIFF) FlowDirection(opts *FlowOptions) (*GeoTIFF, error) {
+	if opts == nil {
+		opts = &FlowOptions{}
+	}
+	d, err := g.drainage(opts.Method)
+	if err != nil {
+		return nil, err
+	}
+	return g.flowImage(d.direction)
+}
+
+// FlowAccumulation returns the number of pixels draining through each pixel
+// of an elevation image, including itself
+//
+// With DInfinity the flow of a pixel is shared between two neighbours, so
+// the counts are fractional. Multiplying by the pixel area gives the area
+// upstream of each pixel. Pixels without elevation are nodata, -1. See
+// FlowDirection for the treatment of pits and flats. opts may be nil.
+func (g *GeoTIFF) FlowAccumulation(opts *FlowOptions) (*GeoTIFF, error) {
+	if opts == nil {
+		opts = &FlowOptions{}
+	}
+	d, err := g.drainage(opts.Method)
+	if err != nil {
+		return nil, err
+	}
+	acc := d.accumulation()
+	out := make([]float32, len(acc))
+	for k, v := range acc {
+		out[k] = flowNoData
+		if d.valid[k] {
+			out[k] = float32(v)
+		}
+	}
+	return g.flowImage(out)
+}
+
+// flowImage returns an image of the same grid as g with a nodata value of -1
+func (g *GeoTIFF) flowImage(raster []float32) (*GeoTIFF, error) {
+	bounds, err := g.Bounds()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errHydrology, err)
+	}
+	out := g.derive(raster, int(g.imageWidth), int(g.imageLength), bounds.UpperLeft, g.PixelScaleX, g.PixelScaleY)
+	out.SetNoData(flowNoData)
+	return out, nil
+}
@@ -0,0 +1,228 @@
+package geotiff
+
+import (
+	"math"
+	"testing"
+)
+
+// basin returns a 5x5 image in MGA zone 55 of 10 m pixels holding a
+// depression at the centre, which spills east to an outlet on the edge
+func basin(t *testing.T) *GeoTIFF {
+	rows := [][]float32{
+		{9, 9, 9, 9, 9},
+		{9, 6, 6, 6, 9},
+		{9, 6, 1, 4, 3},
+		{9, 6, 6, 6, 9},
+		{9, 9, 9, 9, 9},
+	}
+	return demGeoTIFF(t, 28355, 300000, 6000000, 10, 5, 5, func(i, j int) float32 { return rows[j][i] })
+}
+
+func Test_FillSinks_Happy(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *FillOptions
+		want float32
+	}{
+		{"flat", nil, 4},
+		{"epsilon", &FillOptions{Epsilon: 0.5}, 4.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := basin(t)
+			filled, err := g.FillSinks(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			before, after := g.raster(), filled.raster()
+			for k := range before {
+				want := before[k]
+				if k == 2*5+2 {
+					want = tt.want
+				}
+				if after[k] != want {
+					t.Errorf("pixel %d, %d: got %v want %v", k%5, k/5, after[k], want)
+				}
+			}
+		})
+	}
+
+	t.Run("smallest epsilon", func(t *testing.T) {
+		filled, err := basin(t).FillSinks(&FillOptions{Epsilon: 1e-12})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := filled.raster()[2*5+2], math.Nextafter32(4, 5); got != want {
+			t.Errorf("got %v want %v", got, want)
+		}
+	})
+
+	t.Run("nodata outlet", func(t *testing.T) {
+		g := basin(t)
+		g.SetNoData(-9999)
+		g.data[0][1*16+1] = -9999
+		filled, err := g.FillSinks(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The depression drains into the nodata pixel
+		if got := filled.raster()[2*5+2]; got != 1 {
+			t.Errorf("got %v want 1", got)
+		}
+		if v, ok := filled.NoData(); !ok || v != -9999 {
+			t.Errorf("got nodata %v, %v want -9999", v, ok)
+		}
+	})
+
+	t.Run("test file", func(t *testing.T) {
+		g := readTestFile(t, testfile)
+		filled, err := g.FillSinks(&FillOptions{Epsilon: 1e-3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		before, after := g.raster(), filled.raster()
+		for k := range before {
+			if after[k] < before[k] {
+				t.Fatalf("pixel %d lowered from %v to %v", k, before[k], after[k])
+			}
+		}
+		// Every pixel drains to the edge of the image
+		d, err := filled.drainage(D8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		width, length := int(g.imageWidth), int(g.imageLength)
+		for k, to := range d.to {
+			if i, j := k%width, k/width; to[0] < 0 && i > 0 && i < width-1 && j > 0 && j < length-1 {
+				t.Fatalf("pixel %d, %d drains nowhere", i, j)
+			}
+		}
+		acc := d.accumulation()
+		var outlets float64
+		for k, to := range d.to {
+			if to[0] < 0 {
+				outlets += acc[k]
+			}
+		}
+		if outlets != float64(width*length) {
+			t.Errorf("got %v pixels reaching outlets want %d", outlets, width*length)
+		}
+	})
+}
+
+func Test_FlowDirection_Happy(t *testing.T) {
+	t.Run("d8", func(t *testing.T) {
+		// Rising east and south, so draining north west except on the edges
+		g := demGeoTIFF(t, 28355, 300000, 6000000, 10, 4, 4, func(i, j int) float32 { return float32(i + j) })
+		dir, err := g.FlowDirection(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPixels(t, dir, map[[2]int]float32{{0, 0}: 0, {1, 0}: 16, {0, 1}: 64, {1, 1}: 32, {3, 3}: 32})
+	})
+
+	t.Run("d-infinity", func(t *testing.T) {
+		tests := []struct {
+			name string
+			f    func(i, j int) float32
+			want float64
+		}{
+			{"west", func(i, j int) float32 { return float32(i) }, math.Pi},
+			{"north", func(i, j int) float32 { return float32(j) }, math.Pi / 2},
+			{"south east", func(i, j int) float32 { return float32(-i - j) }, 7 * math.Pi / 4},
+			{"between", func(i, j int) float32 { return float32(2*i + j) }, math.Pi - math.Atan(0.5)},
+		}
+		for _, tt := range tests {
+			g := demGeoTIFF(t, 28355, 300000, 6000000, 10, 4, 4, tt.f)
+			dir, err := g.FlowDirection(&FlowOptions{Method: DInfinity})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := float64(dir.raster()[1*4+2]); !checkToTolerance(got, tt.want, 1e-6) {
+				t.Errorf("%s: got %v want %v", tt.name, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("geographic", func(t *testing.T) {
+		// At 60 degrees south a degree of longitude is half a degree of
+		// latitude, so the steeper drop is to the west
+		g := demGeoTIFF(t, 4326, 135, -60, 0.1, 3, 3, func(i, j int) float32 { return float32(float64(i) + 1.2*float64(j)) })
+		dir, err := g.FlowDirection(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPixels(t, dir, map[[2]int]float32{{1, 1}: 16})
+	})
+
+	t.Run("nodata", func(t *testing.T) {
+		g := demGeoTIFF(t, 28355, 300000, 6000000, 10, 3, 3, func(i, j int) float32 { return float32(i) })
+		g.SetNoData(0)
+		for _, method := range []FlowMethod{D8, DInfinity} {
+			dir, err := g.FlowDirection(&FlowOptions{Method: method})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The first column is nodata, so the second drains nowhere
+			want := map[[2]int]float32{{0, 1}: flowNoData, {1, 1}: 0, {2, 1}: 16}
+			if method == DInfinity {
+				want = map[[2]int]float32{{0, 1}: flowNoData, {1, 1}: flowNoData, {2, 1}: math.Pi}
+			}
+			checkPixels(t, dir, want)
+			if v, ok := dir.NoData(); !ok || v != flowNoData {
+				t.Errorf("got nodata %v, %v want %v", v, ok, flowNoData)
+			}
+		}
+	})
+}
+
+func Test_FlowAccumulation_Happy(t *testing.T) {
+	// Draining west along each row
+	g := demGeoTIFF(t, 28355, 300000, 6000000, 10, 5, 3, func(i, j int) float32 { return float32(i) })
+	for _, method := range []FlowMethod{D8, DInfinity} {
+		t.Run(method.String(), func(t *testing.T) {
+			acc, err := g.FlowAccumulation(&FlowOptions{Method: method})
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkPixels(t, acc, map[[2]int]float32{{0, 0}: 5, {1, 1}: 4, {4, 2}: 1})
+		})
+	}
+
+	t.Run("shared flow", func(t *testing.T) {
+		// Draining between the west and north west, with the flow of each
+		// pixel shared between them
+		g := demGeoTIFF(t, 28355, 300000, 6000000, 10, 6, 6, func(i, j int) float32 { return float32(2*i + j) })
+		d, err := g.drainage(DInfinity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		k := 3*6 + 3
+		if d.to[k] != [2]int{3*6 + 2, 2*6 + 2} || !checkToTolerance(d.share[k], 1-math.Atan(0.5)/(math.Pi/4), 1e-9) {
+			t.Errorf("got %v with share %v", d.to[k], d.share[k])
+		}
+		acc := d.accumulation()
+		var outlets float64
+		for k, to := range d.to {
+			if to[0] < 0 {
+				outlets += acc[k]
+			}
+		}
+		if !checkToTolerance(outlets, 36, 1e-9) {
+			t.Errorf("got %v pixels reaching outlets want 36", outlets)
+		}
+	})
+}
+
+func Test_Hydrology_Sad(t *testing.T) {
+	g := basin(t)
+	if _, err := g.FillSinks(&FillOptions{Epsilon: -1}); err == nil {
+		t.Error("expected an error for a negative epsilon")
+	}
+	if _, err := g.FlowDirection(&FlowOptions{Method: FlowMethod(7)}); err == nil {
+		t.Error("expected an error for an unrecognized method")
+	}
+	if _, err := g.FlowAccumulation(&FlowOptions{Method: FlowMethod(7)}); err == nil {
+		t.Error("expected an error for an unrecognized method")
+	}
+}
@@ -0,0 +1,314 @@
+package geotiff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+)
+
+var errDecode = errors.New("unable to decode TIFF")
+
+// Importing the package registers TIFFs with the image package, so
+// image.Decode returns an *Image for 32 bit floating point GeoTIFFs, and an
+// *image.Gray or *image.Gray16 for 8 or 16 bit greyscale TIFFs. Formats are
+// tried in the order they are registered, and image.Decode does not fall back
+// to later ones, so this decoder takes over TIFFs from any TIFF decoder, such
+// as golang.org/x/image/tiff, imported after it. Other TIFFs, such as RGB or
+// palette images, give an error.
+func init() {
+	image.RegisterFormat("geotiff", "II*\x00", Decode, DecodeConfig)
+	image.RegisterFormat("geotiff", "MM\x00*", Decode, DecodeConfig)
+}
+
+// Float is the colour of a pixel of an Image, holding its value
+//
+// Values convert to other colour models as shades of grey, from black at
+// Min to white at Max, with NaN, for nodata, transparent.
+type Float struct {
+	V   float32
+	Min float64
+	Max float64
+}
+
+// RGBA returns the alpha premultiplied grey level of the value
+func (c Float) RGBA() (uint32, uint32, uint32, uint32) {
+	if math.IsNaN(float64(c.V)) {
+		return 0, 0, 0, 0
+	}
+	t := 0.5
+	if c.Max > c.Min {
+		t = math.Max(0, math.Min(1, (float64(c.V)-c.Min)/(c.Max-c.Min)))
+	}
+	y := uint32(math.Round(t * 0xffff))
+	return y, y, y, 0xffff
+}
+
+// FloatModel returns the colour model of an image with values from min to
+// max, which converts other colours to values by their grey level
+func FloatModel(min float64, max float64) color.Model {
+	return color.ModelFunc(func(c color.Color) color.Color {
+		if f, ok := c.(Float); ok {
+			return Float{V: f.V, Min: min, Max: max}
+		}
+		if _, _, _, a := c.RGBA(); a == 0 {
+			return Float{V: float32(math.NaN()), Min: min, Max: max}
+		}
+		y := float64(color.Gray16Model.Convert(c).(color.Gray16).Y) / 0xffff
+		return Float{V: float32(min + y*(max-min)), Min: min, Max: max}
+	})
+}
+
+// Image adapts a GeoTIFF to the image.Image interface, with its georeferencing
+// available through the embedded GeoTIFF
+//
+// Pixels are Float colours holding the values of the image, with NaN for
+// nodata, and shade from black to white over the range of the valid values
+// when drawn into other images.
+type Image struct {
+	*GeoTIFF
+	raster []float32
+	min    float64
+	max    float64
+}
+
+// NewImage returns an image.Image of a GeoTIFF
+func NewImage(g *GeoTIFF) *Image {
+	raster := g.raster()
+	nd := g.noData()
+	for k, v := range raster {
+		if nd.is(v) {
+			raster[k] = float32(math.NaN())
+		}
+	}
+	m := &Image{GeoTIFF: g, raster: raster}
+	if stats := g.Stats(); stats.Count > 0 {
+		m.min, m.max = stats.Min, stats.Max
+	}
+	return m
+}
+
+// ColorModel returns the FloatModel of the range of the image
+func (m *Image) ColorModel() color.Model {
+	return FloatModel(m.min, m.max)
+}
+
+// Bounds returns the pixel bounds of the image, from 0, 0 at the upper
+// left. The georeferenced bounds are returned by m.GeoTIFF.Bounds.
+func (m *Image) Bounds() image.Rectangle {
+	return image.Rect(0, 0, int(m.imageWidth), int(m.imageLength))
+}
+
+// At returns the colour of the pixel at x, y
+func (m *Image) At(x int, y int) color.Color {
+	return Float{V: m.FloatAt(x, y), Min: m.min, Max: m.max}
+}
+
+// FloatAt returns the value of the pixel at x, y, or NaN for nodata or
+// outside the image
+func (m *Image) FloatAt(x int, y int) float32 {
+	if !(image.Point{x, y}.In(m.Bounds())) {
+		return float32(math.NaN())
+	}
+	return m.raster[y*int(m.imageWidth)+x]
+}
+
+// Decode reads a 32 bit floating point GeoTIFF as an *Image, or an 8 or 16
+// bit greyscale TIFF as an *image.Gray or *image.Gray16, for use with
+// image.Decode
+func Decode(r io.Reader) (image.Image, error) {
+	rs, err := readSeeker(r)
+	if err != nil {
+		return nil, err
+	}
+	tags, header, err := readTags(rs)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errDecode, err)
+	}
+	l, err := grayLayout(tags)
+	if err != nil {
+		return nil, err
+	}
+	if l.bitsPerSample != 32 {
+		return decodeGray(rs, tags, header, l)
+	}
+
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	g, err := Read(rs)
+	if err != nil {
+		return nil, err
+	}
+	return NewImage(g), nil
+}
+
+// DecodeConfig reads the size of a GeoTIFF without its data, for use with
+// image.DecodeConfig
+//
+// The colour model spans 0 to 1, as the range of the values is not known
+// without reading them.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	rs, err := readSeeker(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	tags, _, err := readTags(rs)
+	if err != nil {
+		return image.Config{}, fmt.Errorf("%w: %s", errDecode, err)
+	}
+	l, err := grayLayout(tags)
+	if err != nil {
+		return image.Config{}, err
+	}
+	model := FloatModel(0, 1)
+	switch l.bitsPerSample {
+	case 8:
+		model = color.GrayModel
+	case 16:
+		model = color.Gray16Model
+	}
+	return image.Config{
+		ColorModel: model,
+		Width:      int(l.imageWidth),
+		Height:     int(l.imageLength),
+	}, nil
+}
+
+// grayLayout reads the layout of a single band TIFF of 8 or 16 bit unsigned
+// integers or 32 bit floats, the TIFFs Decode supports
+func grayLayout(tags Tags) (*layout, error) {
+	l, err := parseLayout(tags)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errDecode, err)
+	}
+	field := func(t Tag, def uint64) uint64 {
+		if v, err := tags.Uint64s(t); err == nil && len(v) > 0 {
+			return v[0]
+		}
+		return def
+	}
+	if n := field(SamplesPerPixel, 1); n != 1 {
+		return nil, fmt.Errorf("%w: %d samples per pixel, only single band images are supported", errDecode, n)
+	}
+	// 32 bit samples are read as floats without a SampleFormat, as by Read
+	def := uint64(sampleFormatUint)
+	if l.bitsPerSample == 32 {
+		def = uint64(sampleFormatIEEEFP)
+	}
+	format := field(SampleFormat, def)
+	switch {
+	case l.bitsPerSample == 32 && format == uint64(sampleFormatIEEEFP):
+	case (l.bitsPerSample == 8 || l.bitsPerSample == 16) && format == uint64(sampleFormatUint):
+		if p := field(PhotometricInterpretation, uint64(blackIsZero)); p != uint64(blackIsZero) && p != uint64(whiteIsZero) {
+			return nil, fmt.Errorf("%w: %s %d, only greyscale images are supported", errDecode, PhotometricInterpretation, p)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %d bit samples of %s %d, only 8 or 16 bit unsigned integers or 32 bit floats are supported",
+			errDecode, l.bitsPerSample, SampleFormat, format)
+	}
+	if len(l.offsets) != l.blocks() || len(l.byteCounts) != len(l.offsets) {
+		return nil, fmt.Errorf("%w: got %d offsets and %d byte counts for %d blocks", errDecode, len(l.offsets), len(l.byteCounts), l.blocks())
+	}
+	return l, nil
+}
+
+// decodeGray reads an 8 or 16 bit greyscale TIFF
+func decodeGray(r io.ReadSeeker, tags Tags, header head, l *layout) (image.Image, error) {
+	width, length := int(l.imageWidth), int(l.imageLength)
+	tw, tl := int(l.tileWidth), int(l.tileLength)
+	size := int(l.bitsPerSample / 8)
+	rect := image.Rect(0, 0, width, length)
+	var gray *image.Gray
+	var gray16 *image.Gray16
+	if size == 1 {
+		gray = image.NewGray(rect)
+	} else {
+		gray16 = image.NewGray16(rect)
+	}
+	invert := false
+	if p, err := tags.Uint64s(PhotometricInterpretation); err == nil && p[0] == uint64(whiteIsZero) {
+		invert = true
+	}
+
+	// Blocks are checked against the length of the stream before any is
+	// allocated, as any TIFF given to image.Decode reaches this decoder
+	if err := l.checkBlocks(r); err != nil {
+		return nil, fmt.Errorf("%w: %s", errDecode, err)
+	}
+
+	across := (width + tw - 1) / tw
+	for i, offset := range l.offsets {
+		if _, err := r.Seek(int64(offset), io.SeekStart); err != nil {
+			return nil, fmt.Errorf("%w: block %d: %s", errDecode, i, err)
+		}
+		raw := make([]byte, l.byteCounts[i])
+		if _, err := io.ReadFull(r, raw); err != nil {
+			return nil, fmt.Errorf("%w: block %d: %s", errDecode, i, err)
+		}
+		b, err := decompress(raw, l.compression, l.blockSize())
+		if err != nil {
+			return nil, fmt.Errorf("%w: block %d: %s", errDecode, i, err)
+		}
+
+		switch l.predictor {
+		case uint16(NoPredictor):
+		case uint16(HorizontalPredictor):
+			// The differences are taken between the samples of each row, in
+			// the byte order of the file
+			for row := 0; row+tw*size <= len(b); row += tw * size {
+				for k := row + size; k < row+tw*size; k += size {
+					if size == 1 {
+						b[k] += b[k-1]
+					} else {
+						header.byteOrder.PutUint16(b[k:], header.byteOrder.Uint16(b[k:])+header.byteOrder.Uint16(b[k-2:]))
+					}
+				}
+			}
+		default:
+			return nil, fmt.Errorf("%w: unsupported %s", errDecode, PredictorScheme(l.predictor))
+		}
+
+		x0, y0 := (i%across)*tw, (i/across)*tl
+		for y := 0; y < tl && y0+y < length; y++ {
+			for x := 0; x < tw && x0+x < width; x++ {
+				k := (y*tw + x) * size
+				if k+size > len(b) {
+					return nil, fmt.Errorf("%w: block %d is too short", errDecode, i)
+				}
+				if size == 1 {
+					v := b[k]
+					if invert {
+						v = math.MaxUint8 - v
+					}
+					gray.SetGray(x0+x, y0+y, color.Gray{Y: v})
+				} else {
+					v := header.byteOrder.Uint16(b[k:])
+					if invert {
+						v = math.MaxUint16 - v
+					}
+					gray16.SetGray16(x0+x, y0+y, color.Gray16{Y: v})
+				}
+			}
+		}
+	}
+	if gray != nil {
+		return gray, nil
+	}
+	return gray16, nil
+}
+
+// readSeeker returns r if it can seek, or reads it into memory
+func readSeeker(r io.Reader) (io.ReadSeeker, error) {
+	if rs, ok := r.(io.ReadSeeker); ok {
+		return rs, nil
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
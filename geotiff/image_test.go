@@ -0,0 +1,252 @@
+package geotiff
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+	"os"
+	"runtime"
+	"testing"
+)
+
+// grayTIFF returns an uncompressed little endian WGS 84 GeoTIFF of 8 or 16
+// bit unsigned integer samples in a single strip, where each pixel holds
+// x + 10 * y and tags override the defaults
+func grayTIFF(t *testing.T, width int, length int, bits int, tags Tags) []byte {
+	t.Helper()
+	wgs84, _ := CRSFromEPSG(4326)
+	all := wgs84.geoKeyTags()
+	all[ImageWidth] = shortTag(uint16(width))
+	all[ImageLength] = shortTag(uint16(length))
+	all[BitsPerSample] = shortTag(uint16(bits))
+	all[Compression] = shortTag(uint16(Uncompressed))
+	all[PhotometricInterpretation] = shortTag(uint16(blackIsZero))
+	all[SamplesPerPixel] = shortTag(1)
+	all[RowsPerStrip] = shortTag(uint16(length))
+	all[SampleFormat] = shortTag(sampleFormatUint)
+	all[ModelPixelScale] = doubleTag(0.1, 0.1, 0)
+	all[ModelTiepoint] = doubleTag(0, 0, 0, 135, -20, 0)
+	all[StripByteCounts] = longTag(uint32(bits / 8 * width * length))
+	all[StripOffsets] = longTag(0)
+	for k, v := range tags {
+		all[k] = v
+	}
+
+	// The data follows the IFD, whose size does not depend on the offset
+	all[StripOffsets] = longTag(headerSize + ifdSize(all))
+	file := encodeHeader(binary.LittleEndian, headerSize)
+	file = append(file, encodeIFD(all, binary.LittleEndian, headerSize, 0)...)
+	for i := 0; i < width*length; i++ {
+		v := i%width + 10*(i/width)
+		if bits == 8 {
+			file = append(file, uint8(v))
+		} else {
+			file = binary.LittleEndian.AppendUint16(file, uint16(v))
+		}
+	}
+	return file
+}
+
+func Test_Image_Happy(t *testing.T) {
+	// rampGeoTIFF holds x + 10 * y, from 0 to 99 less the nodata corner
+	g := rampGeoTIFF(t, 10, 10)
+	g.SetNoData(99)
+	m := NewImage(g)
+
+	if b := m.Bounds(); b != image.Rect(0, 0, 10, 10) {
+		t.Errorf("got bounds %v want 10x10", b)
+	}
+	if v := m.FloatAt(3, 2); v != 23 {
+		t.Errorf("got %v want 23", v)
+	}
+	for _, p := range []image.Point{{9, 9}, {-1, 0}, {10, 0}} {
+		if v := m.FloatAt(p.X, p.Y); !math.IsNaN(float64(v)) {
+			t.Errorf("%v: got %v want NaN", p, v)
+		}
+	}
+	if cc, err := m.GeoTIFF.Bounds(); err != nil || cc.UpperLeft != (Point{Lon: 135, Lat: -20}) {
+		t.Errorf("got georeferenced bounds %v, %v", cc, err)
+	}
+
+	t.Run("colours", func(t *testing.T) {
+		tests := []struct {
+			p    image.Point
+			want color.NRGBA
+		}{
+			{image.Point{0, 0}, color.NRGBA{0, 0, 0, 255}},
+			{image.Point{8, 9}, color.NRGBA{255, 255, 255, 255}},
+			{image.Point{9, 9}, color.NRGBA{}},
+		}
+		for _, tt := range tests {
+			if got := color.NRGBAModel.Convert(m.At(tt.p.X, tt.p.Y)); got != tt.want {
+				t.Errorf("%v: got %v want %v", tt.p, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("draw", func(t *testing.T) {
+		gray := image.NewGray16(m.Bounds())
+		draw.Draw(gray, gray.Bounds(), m, image.Point{}, draw.Src)
+		// Halfway through the range of 0 to 98
+		if got, want := gray.Gray16At(9, 4).Y, uint16(math.Round(49.0/98*0xffff)); got != want {
+			t.Errorf("got %v want %v", got, want)
+		}
+	})
+
+	t.Run("model", func(t *testing.T) {
+		model := m.ColorModel()
+		tests := []struct {
+			c    color.Color
+			want float32
+		}{
+			{color.Gray{255}, 98},
+			{color.Gray16{0}, 0},
+			{color.Gray16{0x8000}, float32(0x8000) / 0xffff * 98},
+			{Float{V: 7, Min: -1, Max: 1}, 7},
+		}
+		for _, tt := range tests {
+			f := model.Convert(tt.c).(Float)
+			if !checkToTolerance(float64(f.V), float64(tt.want), 1e-4) || f.Min != 0 || f.Max != 98 {
+				t.Errorf("%v: got %+v want %v from 0 to 98", tt.c, f, tt.want)
+			}
+		}
+		if f := model.Convert(color.NRGBA{}).(Float); !math.IsNaN(float64(f.V)) {
+			t.Errorf("got %v for transparent want NaN", f.V)
+		}
+	})
+}
+
+func Test_Decode_Happy(t *testing.T) {
+	f, err := os.Open(testfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// A buffered reader cannot seek, so is read into memory
+	img, format, err := image.Decode(bufio.NewReader(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "geotiff" {
+		t.Errorf("got format %q want geotiff", format)
+	}
+	m, ok := img.(*Image)
+	if !ok {
+		t.Fatalf("got a %T want an *Image", img)
+	}
+	want := readTestFile(t, testfile)
+	if v, _ := want.loc(20, 30); m.FloatAt(20, 30) != v {
+		t.Errorf("got %v want %v", m.FloatAt(20, 30), v)
+	}
+	if crs, err := m.CRS(); err != nil || crs.EPSG != 4326 {
+		t.Errorf("got crs %v, %v want EPSG:4326", crs, err)
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	cfg, format, err := image.DecodeConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "geotiff" || cfg.Width != 180 || cfg.Height != 191 {
+		t.Errorf("got %s %dx%d want geotiff 180x191", format, cfg.Width, cfg.Height)
+	}
+}
+
+func Test_Decode_Gray(t *testing.T) {
+	// grayTIFF holds x + 10 * y
+	tests := []struct {
+		name string
+		file []byte
+		want color.Color
+	}{
+		{"8 bit", grayTIFF(t, 10, 10, 8, nil), color.Gray{23}},
+		{"16 bit", grayTIFF(t, 10, 10, 16, nil), color.Gray16{23}},
+		{"white is zero", grayTIFF(t, 10, 10, 8, Tags{PhotometricInterpretation: shortTag(uint16(whiteIsZero))}), color.Gray{255 - 23}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, format, err := image.Decode(bytes.NewReader(tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format != "geotiff" {
+				t.Errorf("got format %q want geotiff", format)
+			}
+			if b := img.Bounds(); b != image.Rect(0, 0, 10, 10) {
+				t.Errorf("got bounds %v want 10x10", b)
+			}
+			if got := img.ColorModel().Convert(img.At(3, 2)); got != tt.want {
+				t.Errorf("got %v want %v", got, tt.want)
+			}
+			if img.ColorModel() != color.GrayModel && img.ColorModel() != color.Gray16Model {
+				t.Errorf("got a %T want a greyscale image", img)
+			}
+
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.ColorModel != img.ColorModel() || cfg.Width != 10 || cfg.Height != 10 {
+				t.Errorf("got config %+v want the model and size of the image", cfg)
+			}
+		})
+	}
+}
+
+func Test_Decode_Sad(t *testing.T) {
+	f, err := os.Open(testfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// The header without the rest of the file
+	if _, err := Decode(io.LimitReader(f, 16)); err == nil {
+		t.Error("expected an error")
+	}
+
+	// TIFFs which are not greyscale integers or floats are refused clearly
+	tests := []struct {
+		name string
+		file []byte
+	}{
+		{"signed", grayTIFF(t, 10, 10, 16, Tags{SampleFormat: shortTag(sampleFormatInt)})},
+		{"rgb", grayTIFF(t, 10, 10, 8, Tags{SamplesPerPixel: shortTag(3)})},
+		{"palette", grayTIFF(t, 10, 10, 8, Tags{PhotometricInterpretation: shortTag(3)})},
+		{"1 bit", grayTIFF(t, 10, 10, 8, Tags{BitsPerSample: shortTag(1)})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := image.Decode(bytes.NewReader(tt.file))
+			if !errors.Is(err, errDecode) {
+				t.Errorf("got %v want %v", err, errDecode)
+			}
+			if _, _, err := image.DecodeConfig(bytes.NewReader(tt.file)); !errors.Is(err, errDecode) {
+				t.Errorf("config: got %v want %v", err, errDecode)
+			}
+		})
+	}
+
+	t.Run("byte count", func(t *testing.T) {
+		// A 4GB strip in a file of a few hundred bytes is refused before it
+		// is allocated
+		file := grayTIFF(t, 10, 10, 8, Tags{StripByteCounts: longTag(math.MaxUint32)})
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		if _, _, err := image.Decode(bytes.NewReader(file)); !errors.Is(err, errDecode) {
+			t.Errorf("got %v want %v", err, errDecode)
+		}
+		runtime.ReadMemStats(&after)
+		if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+			t.Errorf("allocated %d bytes want less than 1MB", n)
+		}
+	})
+}
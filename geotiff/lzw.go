@@ -0,0 +1,191 @@
+package geotiff
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The TIFF variant of LZW compression
+//
+// From the Tiff 6.0 Specification (p.57)
+//
+// Codes are packed most significant bit first, starting at 9 bits wide and
+// growing to a maximum of 12 bits. Code 256 clears the string table and code
+// 257 marks the end of the information. Unlike GIF, the code width grows one
+// code early, which is the behaviour implemented by libtiff and expected by
+// every TIFF reader.
+const (
+	lzwClear    = 256
+	lzwEOI      = 257
+	lzwFirst    = 258
+	lzwMinWidth = 9
+	lzwMaxWidth = 12
+	lzwMaxCode  = 1<<lzwMaxWidth - 1
+)
+
+var errLZW = errors.New("invalid LZW data")
+
+// lzwBitWriter packs codes most significant bit first
+type lzwBitWriter struct {
+	out   []byte
+	bits  uint32
+	nBits uint
+}
+
+func (w *lzwBitWriter) write(code int, width int) {
+	w.bits |= uint32(code) << (32 - uint(width) - w.nBits)
+	w.nBits += uint(width)
+	for w.nBits >= 8 {
+		w.out = append(w.out, byte(w.bits>>24))
+		w.bits <<= 8
+		w.nBits -= 8
+	}
+}
+
+func (w *lzwBitWriter) flush() []byte {
+	if w.nBits > 0 {
+		w.out = append(w.out, byte(w.bits>>24))
+	}
+	return w.out
+}
+
+// lzwEncode compresses b with TIFF LZW
+func lzwEncode(b []byte) []byte {
+	w := lzwBitWriter{out: make([]byte, 0, len(b)/2)}
+	width := lzwMinWidth
+	w.write(lzwClear, width)
+	if len(b) == 0 {
+		w.write(lzwEOI, width)
+		return w.flush()
+	}
+
+	table := make(map[uint32]int, lzwMaxCode)
+	next := lzwFirst
+	prefix := int(b[0])
+
+	// grow widens the codes once the next code no longer fits, or clears the
+	// table once it is full
+	grow := func() {
+		if next == lzwMaxCode-1 {
+			w.write(lzwClear, width)
+			for k := range table {
+				delete(table, k)
+			}
+			next = lzwFirst
+			width = lzwMinWidth
+		} else if next > 1<<width-1 {
+			width++
+		}
+	}
+
+	for _, c := range b[1:] {
+		key := uint32(prefix)<<8 | uint32(c)
+		if code, ok := table[key]; ok {
+			prefix = code
+			continue
+		}
+		w.write(prefix, width)
+		table[key] = next
+		next++
+		prefix = int(c)
+		grow()
+	}
+	w.write(prefix, width)
+	next++
+	grow()
+	w.write(lzwEOI, width)
+	return w.flush()
+}
+
+// lzwDecode decompresses TIFF LZW data, which may be at most size bytes long
+func lzwDecode(src []byte, size int) ([]byte, error) {
+	var (
+		prefix [lzwMaxCode + 1]uint16
+		suffix [lzwMaxCode + 1]byte
+		first  [lzwMaxCode + 1]byte
+		length [lzwMaxCode + 1]int
+	)
+	for i := 0; i < 256; i++ {
+		suffix[i] = byte(i)
+		first[i] = byte(i)
+		length[i] = 1
+	}
+
+	capacity := 2 * len(src)
+	if capacity > size {
+		capacity = size
+	}
+	out := make([]byte, 0, capacity)
+	width := lzwMinWidth
+	next := lzwFirst
+	last := -1
+
+	var bits uint32
+	var nBits uint
+	pos := 0
+	for {
+		for nBits < uint(width) {
+			if pos >= len(src) {
+				// Some writers omit the end of information code
+				return out, nil
+			}
+			bits |= uint32(src[pos]) << (24 - nBits)
+			nBits += 8
+			pos++
+		}
+		code := int(bits >> (32 - uint(width)))
+		bits <<= uint(width)
+		nBits -= uint(width)
+
+		switch {
+		case code == lzwEOI:
+			return out, nil
+		case code == lzwClear:
+			width = lzwMinWidth
+			next = lzwFirst
+			last = -1
+			continue
+		case last == -1:
+			if code > 255 {
+				return nil, errLZW
+			}
+			if len(out) == size {
+				return nil, fmt.Errorf("%w: block is longer than %d bytes", errLZW, size)
+			}
+			out = append(out, byte(code))
+			last = code
+			continue
+		case code > next || next > lzwMaxCode:
+			return nil, errLZW
+		}
+
+		// Add the new string, the previous string followed by the first byte
+		// of the current string (or of the previous string, if the current
+		// code is the one being added)
+		c := first[last]
+		if code < next {
+			c = first[code]
+		}
+		prefix[next] = uint16(last)
+		suffix[next] = c
+		first[next] = first[last]
+		length[next] = length[last] + 1
+		next++
+
+		// Write out the string for the current code from back to front
+		n := length[code]
+		if len(out)+n > size {
+			return nil, fmt.Errorf("%w: block is longer than %d bytes", errLZW, size)
+		}
+		out = append(out, make([]byte, n)...)
+		for i, c := len(out)-1, code; i >= len(out)-n; i-- {
+			out[i] = suffix[c]
+			c = int(prefix[c])
+		}
+		last = code
+
+		if next >= 1<<width-1 && width < lzwMaxWidth {
+			width++
+		}
+	}
+}
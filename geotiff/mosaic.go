@@ -0,0 +1,212 @@
+package geotiff
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var errMosaic = errors.New("unable to mosaic GeoTIFFs")
+
+// Overlap selects the value of a mosaic where images overlap
+type Overlap int
+
+const (
+	OverlapFirst Overlap = iota // value of the first image with data
+	OverlapLast                 // value of the last image with data
+	OverlapMin                  // minimum of the images with data
+	OverlapMax                  // maximum of the images with data
+	OverlapMean                 // mean of the images with data
+)
+
+var overlapToLabel = map[Overlap]string{
+	OverlapFirst: "first",
+	OverlapLast:  "last",
+	OverlapMin:   "min",
+	OverlapMax:   "max",
+	OverlapMean:  "mean",
+}
+
+func (o Overlap) String() string {
+	v, ok := overlapToLabel[o]
+	if !ok {
+		return fmt.Sprintf("unrecognized overlap %d", int(o))
+	}
+	return v
+}
+
+// MosaicOptions configures Mosaic
+type MosaicOptions struct {
+	// Overlap selects the value where images overlap.
+	//
+	// Defaults to OverlapFirst
+	Overlap Overlap
+
+	// Resampling is used for images whose pixel grid does not align with the
+	// mosaic, one of Nearest, Bilinear, Cubic or Lanczos.
+	//
+	// Defaults to Nearest
+	Resampling Resampling
+
+	// PixelScaleX and PixelScaleY are the resolution of the mosaic.
+	//
+	// Defaults to the resolution of the first image
+	PixelScaleX float64
+	PixelScaleY float64
+
+	// NoData is the value of pixels without data in any image.
+	//
+	// Defaults to the nodata value of the first image, or NaN if it has none
+	NoData *float64
+}
+
+// Mosaic composites images sharing a coordinate reference system into one
+// image covering the union of their extents
+//
+// The mosaic is aligned to the pixel grid of the first image, and takes its
+// tags. Images which do not align with the grid are resampled onto it. Each
+// image's nodata pixels are ignored, so images can fill the gaps in others.
+//
+// opts may be nil, in which case the defaults are used.
+func Mosaic(images []*GeoTIFF, opts *MosaicOptions) (*GeoTIFF, error) {
+	if opts == nil {
+		opts = &MosaicOptions{}
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("%w: no images", errMosaic)
+	}
+	if _, ok := overlapToLabel[opts.Overlap]; !ok {
+		return nil, fmt.Errorf("%w: %s", errMosaic, opts.Overlap)
+	}
+	if !opts.Resampling.interpolates() {
+		return nil, fmt.Errorf("%w: %s resampling is not supported, use nearest, bilinear, cubic or lanczos", errMosaic, opts.Resampling)
+	}
+
+	first := images[0]
+	crs, err := first.CRS()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errMosaic, err)
+	}
+	bounds := make([]*CornerCoordinates, len(images))
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for i, g := range images {
+		c, err := g.CRS()
+		if err != nil {
+			return nil, fmt.Errorf("%w: image %d: %s", errMosaic, i, err)
+		}
+		if !c.Equal(crs) {
+			return nil, fmt.Errorf("%w: image %d is in %s, not %s", errMosaic, i, c, crs)
+		}
+		b, err := g.Bounds()
+		if err != nil {
+			return nil, fmt.Errorf("%w: image %d: %s", errMosaic, i, err)
+		}
+		bounds[i] = b
+		minX, maxX = math.Min(minX, b.UpperLeft.Lon), math.Max(maxX, b.LowerRight.Lon)
+		minY, maxY = math.Min(minY, b.LowerRight.Lat), math.Max(maxY, b.UpperLeft.Lat)
+	}
+
+	scaleX, scaleY := first.PixelScaleX, first.PixelScaleY
+	if opts.PixelScaleX > 0 && opts.PixelScaleY > 0 {
+		scaleX, scaleY = opts.PixelScaleX, opts.PixelScaleY
+	}
+
+	// The grid is extended out from the upper left corner of the first image
+	// to cover the union of the extents. The small tolerance keeps edges
+	// which lie on the grid from taking in an extra pixel to rounding error
+	const tolerance = 1e-6
+	origin := bounds[0].UpperLeft
+	x0 := math.Floor((minX-origin.Lon)/scaleX + tolerance)
+	y0 := math.Floor((origin.Lat-maxY)/scaleY + tolerance)
+	upperLeft := Point{Lon: origin.Lon + x0*scaleX, Lat: origin.Lat - y0*scaleY}
+	width := int(math.Ceil((maxX-upperLeft.Lon)/scaleX - tolerance))
+	length := int(math.Ceil((upperLeft.Lat-minY)/scaleY - tolerance))
+	if width < 1 || length < 1 || width > math.MaxUint16 || length > math.MaxUint16 {
+		return nil, fmt.Errorf("%w: size %dx%d is outside 1 to %d pixels", errMosaic, width, length, math.MaxUint16)
+	}
+
+	out := first.noData()
+	outValue, _ := first.NoData()
+	if opts.NoData != nil {
+		outValue = *opts.NoData
+		out = noData{value: float32(outValue), set: true}
+	}
+
+	values := make([]float64, width*length)
+	counts := make([]int, width*length)
+	for n, g := range images {
+		b := bounds[n]
+		rx, ry := scaleX/g.PixelScaleX, scaleY/g.PixelScaleY
+		offsetX := (upperLeft.Lon - b.UpperLeft.Lon) / g.PixelScaleX
+		offsetY := (b.UpperLeft.Lat - upperLeft.Lat) / g.PixelScaleY
+		method := opts.Resampling
+		if aligned(rx) && aligned(ry) && aligned(offsetX) && aligned(offsetY) {
+			method = Nearest
+		}
+
+		// Only the output pixels covering the image are visited
+		i0 := clamp(int(math.Floor((b.UpperLeft.Lon-upperLeft.Lon)/scaleX)), 0, width)
+		i1 := clamp(int(math.Ceil((b.LowerRight.Lon-upperLeft.Lon)/scaleX)), 0, width)
+		j0 := clamp(int(math.Floor((upperLeft.Lat-b.UpperLeft.Lat)/scaleY)), 0, length)
+		j1 := clamp(int(math.Ceil((upperLeft.Lat-b.LowerRight.Lat)/scaleY)), 0, length)
+
+		raster := g.raster()
+		nd := g.noData()
+		sx, sy := math.Max(rx, 1), math.Max(ry, 1)
+		for j := j0; j < j1; j++ {
+			y := offsetY + (float64(j)+0.5)*ry
+			for i := i0; i < i1; i++ {
+				x := offsetX + (float64(i)+0.5)*rx
+				v := sample(raster, int(g.imageWidth), int(g.imageLength), x, y, sx, sy, method, nd)
+				if nd.is(v) {
+					continue
+				}
+				k := j*width + i
+				values[k] = overlap(values[k], float64(v), counts[k], opts.Overlap)
+				counts[k]++
+			}
+		}
+	}
+
+	raster := make([]float32, width*length)
+	for k, c := range counts {
+		switch {
+		case c == 0:
+			raster[k] = out.fill()
+		case opts.Overlap == OverlapMean:
+			raster[k] = float32(values[k] / float64(c))
+		default:
+			raster[k] = float32(values[k])
+		}
+	}
+	m := first.derive(raster, width, length, upperLeft, scaleX, scaleY)
+	if out.set {
+		m.SetNoData(outValue)
+	}
+	return m, nil
+}
+
+// aligned reports if v is a whole number, allowing for rounding error
+func aligned(v float64) bool {
+	return math.Abs(v-math.Round(v)) < 1e-6
+}
+
+// overlap combines the value of a pixel from an image with the value
+// accumulated from count earlier images
+func overlap(acc float64, v float64, count int, rule Overlap) float64 {
+	if count == 0 {
+		return v
+	}
+	switch rule {
+	case OverlapLast:
+		return v
+	case OverlapMin:
+		return math.Min(acc, v)
+	case OverlapMax:
+		return math.Max(acc, v)
+	case OverlapMean:
+		return acc + v
+	}
+	return acc
+}
@@ -0,0 +1,140 @@
+package geotiff
+
+import (
+	"math"
+	"testing"
+)
+
+func checkPixels(t *testing.T, g *GeoTIFF, want map[[2]int]float32) {
+	t.Helper()
+	for p, w := range want {
+		v, err := g.loc(p[0], p[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != w && !(math.IsNaN(float64(v)) && math.IsNaN(float64(w))) {
+			t.Errorf("pixel %d, %d: got %v want %v", p[0], p[1], v, w)
+		}
+	}
+}
+
+func Test_Mosaic_Happy(t *testing.T) {
+	nan := float32(math.NaN())
+
+	t.Run("adjacent tiles", func(t *testing.T) {
+		a := constGeoTIFF(t, 135, -20, 0.1, 10, 10, 1)
+		b := constGeoTIFF(t, 136, -20, 0.1, 10, 10, 2)
+		got, err := Mosaic([]*GeoTIFF{a, b}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.imageWidth != 20 || got.imageLength != 10 {
+			t.Fatalf("got size %dx%d want 20x10", got.imageWidth, got.imageLength)
+		}
+		checkPixels(t, got, map[[2]int]float32{{0, 0}: 1, {9, 9}: 1, {10, 0}: 2, {19, 9}: 2})
+		bounds, _ := got.Bounds()
+		if !bounds.UpperLeft.Equals(Point{Lon: 135, Lat: -20}) || !checkToTolerance(bounds.LowerRight.Lon, 137, 1e-9) {
+			t.Errorf("got bounds\n%s", bounds)
+		}
+	})
+
+	t.Run("overlap", func(t *testing.T) {
+		a := constGeoTIFF(t, 135, -20, 0.1, 10, 10, 1)
+		b := constGeoTIFF(t, 135.5, -20.5, 0.1, 10, 10, 3)
+		tests := []struct {
+			overlap Overlap
+			want    float32
+		}{
+			{OverlapFirst, 1},
+			{OverlapLast, 3},
+			{OverlapMin, 1},
+			{OverlapMax, 3},
+			{OverlapMean, 2},
+		}
+		for _, tt := range tests {
+			t.Run(tt.overlap.String(), func(t *testing.T) {
+				got, err := Mosaic([]*GeoTIFF{a, b}, &MosaicOptions{Overlap: tt.overlap})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.imageWidth != 15 || got.imageLength != 15 {
+					t.Fatalf("got size %dx%d want 15x15", got.imageWidth, got.imageLength)
+				}
+				checkPixels(t, got, map[[2]int]float32{
+					{7, 7}: tt.want, {2, 2}: 1, {12, 12}: 3, {14, 0}: nan, {0, 14}: nan,
+				})
+			})
+		}
+	})
+
+	t.Run("nodata", func(t *testing.T) {
+		a := constGeoTIFF(t, 135, -20, 0.1, 10, 10, 1)
+		b := constGeoTIFF(t, 135.5, -20.5, 0.1, 10, 10, 3)
+		b.SetNoData(-1)
+		raster := b.raster()
+		raster[2*10+2] = -1
+		b.data = tile(raster, 10, 10, 16, 16)
+
+		nd := -9999.0
+		got, err := Mosaic([]*GeoTIFF{a, b}, &MosaicOptions{Overlap: OverlapLast, NoData: &nd})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The nodata pixel of the last image does not replace the first
+		checkPixels(t, got, map[[2]int]float32{{7, 7}: 1, {8, 7}: 3, {14, 0}: -9999})
+		if v, ok := got.NoData(); !ok || v != nd {
+			t.Errorf("got nodata %v, %v want %v", v, ok, nd)
+		}
+	})
+
+	t.Run("misaligned", func(t *testing.T) {
+		// The second image is offset by half a pixel, and has twice the
+		// resolution of the first
+		a := constGeoTIFF(t, 135, -20, 0.1, 10, 10, 1)
+		b := constGeoTIFF(t, 135.55, -20, 0.05, 20, 20, 5)
+		got, err := Mosaic([]*GeoTIFF{a, b}, &MosaicOptions{Overlap: OverlapLast, Resampling: Bilinear})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.imageWidth != 16 || got.imageLength != 10 {
+			t.Fatalf("got size %dx%d want 16x10", got.imageWidth, got.imageLength)
+		}
+		// Pixel 5 is half covered by the second image, and its centre falls
+		// just outside it
+		checkPixels(t, got, map[[2]int]float32{{4, 0}: 1, {5, 0}: 1, {6, 0}: 5, {15, 9}: 5})
+	})
+
+	t.Run("resolution", func(t *testing.T) {
+		a := constGeoTIFF(t, 135, -20, 0.1, 10, 10, 1)
+		got, err := Mosaic([]*GeoTIFF{a}, &MosaicOptions{PixelScaleX: 0.2, PixelScaleY: 0.25})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.imageWidth != 5 || got.imageLength != 4 || got.PixelScaleX != 0.2 || got.PixelScaleY != 0.25 {
+			t.Errorf("got size %dx%d scale %v, %v", got.imageWidth, got.imageLength, got.PixelScaleX, got.PixelScaleY)
+		}
+	})
+}
+
+func Test_Mosaic_Sad(t *testing.T) {
+	a := constGeoTIFF(t, 135, -20, 0.1, 10, 10, 1)
+	mga := demGeoTIFF(t, 28355, 500000, 7000000, 100, 10, 10, func(i, j int) float32 { return 1 })
+
+	tests := []struct {
+		name   string
+		images []*GeoTIFF
+		opts   *MosaicOptions
+	}{
+		{"no images", nil, nil},
+		{"different CRS", []*GeoTIFF{a, mga}, nil},
+		{"unknown overlap", []*GeoTIFF{a}, &MosaicOptions{Overlap: Overlap(99)}},
+		{"aggregating resampling", []*GeoTIFF{a}, &MosaicOptions{Resampling: Average}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Mosaic(tt.images, tt.opts); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
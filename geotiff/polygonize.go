@@ -0,0 +1,163 @@
+package geotiff
+
+import (
+	"fmt"
+	"math"
+)
+
+// PolygonizeOptions configures Polygonize
+type PolygonizeOptions struct {
+	// EightConnected joins pixels which touch diagonally into the same
+	// region, rather than only pixels which share a side
+	EightConnected bool
+
+	// IncludeNoData also polygonizes the regions of nodata pixels, which are
+	// otherwise left out. NaN pixels are always left out.
+	IncludeNoData bool
+
+	// Attribute is the name of the feature property holding the value of a
+	// region.
+	//
+	// Defaults to "value"
+	Attribute string
+}
+
+// Polygonize traces the connected regions of pixels with the same value
+// into GeoJSON features in the coordinate reference system of the image,
+// analogous to gdal_polygonize
+//
+// Each region is a Polygon feature holding the pixel value, with holes where
+// it surrounds other regions. Eight connected regions whose pixels only
+// touch diagonally are MultiPolygon features, as a polygon's rings can only
+// touch at a point. Regions are in the order of their first pixel, scanning
+// from the upper left. opts may be nil.
+func (g *GeoTIFF) Polygonize(opts *PolygonizeOptions) (*FeatureCollection, error) {
+	if opts == nil {
+		opts = &PolygonizeOptions{}
+	}
+	attribute := opts.Attribute
+	if attribute == "" {
+		attribute = "value"
+	}
+	bounds, err := g.Bounds()
+	if err != nil {
+		return nil, fmt.Errorf("unable to polygonize GeoTIFF: %w", err)
+	}
+
+	raster := g.raster()
+	width, length := int(g.imageWidth), int(g.imageLength)
+	nd := g.noData()
+	skip := func(v float32) bool {
+		return math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) || (!opts.IncludeNoData && nd.is(v))
+	}
+
+	neighbours := [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	if opts.EightConnected {
+		neighbours = append(neighbours, [2]int{1, 1}, [2]int{-1, 1}, [2]int{1, -1}, [2]int{-1, -1})
+	}
+
+	// Regions are labelled with a flood fill, where 0 is unlabelled
+	labels := make([]int, len(raster))
+	var features []Feature
+	var stack, region []int
+	for start, v := range raster {
+		if labels[start] != 0 || skip(v) {
+			continue
+		}
+		label := len(features) + 1
+		labels[start] = label
+		stack, region = append(stack[:0], start), region[:0]
+		for len(stack) > 0 {
+			k := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			region = append(region, k)
+			i, j := k%width, k/width
+			for _, n := range neighbours {
+				x, y := i+n[0], j+n[1]
+				if x < 0 || x >= width || y < 0 || y >= length {
+					continue
+				}
+				if m := y*width + x; labels[m] == 0 && raster[m] == v {
+					labels[m] = label
+					stack = append(stack, m)
+				}
+			}
+		}
+
+		geometry := g.regionGeometry(region, labels, label, bounds)
+		features = append(features, NewFeature(geometry, map[string]interface{}{attribute: float64(v)}))
+	}
+	return NewFeatureCollection(features), nil
+}
+
+// regionGeometry returns the outline of a labelled region in the coordinate
+// reference system of the image, as a Polygon or, if its pixels only touch
+// diagonally, a MultiPolygon
+func (g *GeoTIFF) regionGeometry(region []int, labels []int, label int, bounds *CornerCoordinates) *Geometry {
+	rings := traceRegion(region, labels, label, int(g.imageWidth), int(g.imageLength))
+	for _, ring := range rings {
+		for k, p := range ring {
+			ring[k] = Position{
+				bounds.UpperLeft.Lon + p[0]*g.PixelScaleX,
+				bounds.UpperLeft.Lat - p[1]*g.PixelScaleY,
+			}
+		}
+	}
+	polygons := nestRings(rings)
+	if len(polygons) == 1 {
+		return &Geometry{Type: GeometryPolygon, Coordinates: polygons[0]}
+	}
+	return &Geometry{Type: GeometryMultiPolygon, Coordinates: polygons}
+}
+
+// traceRegion returns the open rings outlining a labelled region, in pixel
+// corner coordinates, counterclockwise around the region in world
+// coordinates
+func traceRegion(region []int, labels []int, label int, width int, length int) [][]Position {
+	in := func(i int, j int) bool {
+		return i >= 0 && i < width && j >= 0 && j < length && labels[j*width+i] == label
+	}
+
+	// Each side of a pixel on the edge of the region is an edge of its
+	// outline, with the region on the left in world coordinates, which is
+	// on the right with rows running down
+	type vertex struct{ i, j int }
+	next := make(map[vertex][]vertex)
+	edge := func(a vertex, b vertex) {
+		next[a] = append(next[a], b)
+	}
+	for _, k := range region {
+		i, j := k%width, k/width
+		if !in(i-1, j) {
+			edge(vertex{i, j}, vertex{i, j + 1})
+		}
+		if !in(i, j+1) {
+			edge(vertex{i, j + 1}, vertex{i + 1, j + 1})
+		}
+		if !in(i+1, j) {
+			edge(vertex{i + 1, j + 1}, vertex{i + 1, j})
+		}
+		if !in(i, j-1) {
+			edge(vertex{i + 1, j}, vertex{i, j})
+		}
+	}
+
+	var rings [][]Position
+	for _, k := range region {
+		start := vertex{k % width, k / width}
+		for len(next[start]) > 0 {
+			var ring []Position
+			for cur := start; len(next[cur]) > 0; {
+				ring = append(ring, Position{float64(cur.i), float64(cur.j)})
+				n := next[cur][0]
+				next[cur] = next[cur][1:]
+				cur = n
+				if cur == start {
+					break
+				}
+			}
+			rings = append(rings, ring)
+		}
+	}
+	return rings
+}
@@ -0,0 +1,132 @@
+package geotiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+// classGeoTIFF returns a WGS 84 image of 0.1 degree pixels, with an upper
+// left corner at 135, -20, holding rows of values
+func classGeoTIFF(t *testing.T, rows [][]float32) *GeoTIFF {
+	return demGeoTIFF(t, 4326, 135, -20, 0.1, len(rows[0]), len(rows), func(i, j int) float32 { return rows[j][i] })
+}
+
+func Test_Polygonize_Happy(t *testing.T) {
+	t.Run("region with a hole", func(t *testing.T) {
+		g := classGeoTIFF(t, [][]float32{
+			{1, 1, 1, 1},
+			{1, 2, 2, 1},
+			{1, 2, 2, 1},
+			{1, 1, 1, 1},
+		})
+		fc, err := g.Polygonize(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fc.Features) != 2 {
+			t.Fatalf("got %d features want 2", len(fc.Features))
+		}
+		outer := fc.Features[0].Geometry.Coordinates.([][]Position)
+		inner := fc.Features[1].Geometry.Coordinates.([][]Position)
+		if len(outer) != 2 || len(inner) != 1 {
+			t.Fatalf("got %d and %d rings want 2 and 1", len(outer), len(inner))
+		}
+		if !checkToTolerance(ringArea(outer[0]), 0.16, 1e-9) || !checkToTolerance(ringArea(outer[1]), -0.04, 1e-9) {
+			t.Errorf("got exterior area %v and hole area %v want 0.16 and -0.04", ringArea(outer[0]), ringArea(outer[1]))
+		}
+		// The block's corners are on the pixel corners in world coordinates
+		want := [][]Position{{{135.1, -20.1}, {135.1, -20.3}, {135.3, -20.3}, {135.3, -20.1}, {135.1, -20.1}}}
+		if len(inner[0]) != 5 {
+			t.Fatalf("got %v want %v", inner, want)
+		}
+		for k, p := range inner[0] {
+			if !checkToTolerance(p[0], want[0][k][0], 1e-9) || !checkToTolerance(p[1], want[0][k][1], 1e-9) {
+				t.Errorf("vertex %d: got %v want %v", k, p, want[0][k])
+			}
+		}
+		values := []interface{}{fc.Features[0].Properties["value"], fc.Features[1].Properties["value"]}
+		if !reflect.DeepEqual(values, []interface{}{1.0, 2.0}) {
+			t.Errorf("got values %v want 1, 2", values)
+		}
+	})
+
+	diagonal := [][]float32{
+		{0, 0, 0, 0},
+		{0, 1, 0, 0},
+		{0, 0, 1, 0},
+		{0, 0, 0, 0},
+	}
+
+	t.Run("connectivity", func(t *testing.T) {
+		g := classGeoTIFF(t, diagonal)
+		four, err := g.Polygonize(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(four.Features) != 3 {
+			t.Errorf("four connected: got %d features want 3", len(four.Features))
+		}
+		eight, err := g.Polygonize(&PolygonizeOptions{EightConnected: true, Attribute: "class"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(eight.Features) != 2 {
+			t.Fatalf("eight connected: got %d features want 2", len(eight.Features))
+		}
+		background, ones := eight.Features[0].Geometry, eight.Features[1].Geometry
+		if background.Type != GeometryPolygon || len(background.Coordinates.([][]Position)) != 3 {
+			t.Errorf("got background %v want a polygon with two holes", background)
+		}
+		if ones.Type != GeometryMultiPolygon || len(ones.Coordinates.([][][]Position)) != 2 {
+			t.Errorf("got %v want a multipolygon of the two pixels", ones)
+		}
+		if v := eight.Features[1].Properties["class"]; v != 1.0 {
+			t.Errorf("got class %v want 1", v)
+		}
+	})
+
+	t.Run("nodata", func(t *testing.T) {
+		g := classGeoTIFF(t, diagonal)
+		g.SetNoData(0)
+		masked, err := g.Polygonize(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(masked.Features) != 2 || masked.Features[0].Properties["value"] != 1.0 {
+			t.Errorf("got %d features want the two pixels of 1", len(masked.Features))
+		}
+		all, err := g.Polygonize(&PolygonizeOptions{IncludeNoData: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all.Features) != 3 {
+			t.Errorf("got %d features want 3", len(all.Features))
+		}
+	})
+
+	t.Run("test file", func(t *testing.T) {
+		// Every pixel is in exactly one region, so the areas sum to the image
+		g := readTestFile(t, testfile)
+		g, err := g.Resample(g.PixelScaleX*4, g.PixelScaleY*4, Nearest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fc, err := g.Polygonize(&PolygonizeOptions{EightConnected: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var total float64
+		for _, f := range fc.Features {
+			polygons, _ := f.Geometry.polygons()
+			for _, p := range polygons {
+				for _, r := range p {
+					total += ringArea(r)
+				}
+			}
+		}
+		want := float64(g.imageWidth) * float64(g.imageLength) * g.PixelScaleX * g.PixelScaleY
+		if !checkToTolerance(total, want, 1e-6) {
+			t.Errorf("got total area %v want %v", total, want)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package geotiff
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var errProfile = errors.New("unable to compute profile")
+
+// maxProfilePoints limits the number of points a profile is densified to
+const maxProfilePoints = 1000000
+
+// ProfileOptions configures Profile
+type ProfileOptions struct {
+	// Spacing is the greatest distance between samples in metres.
+	//
+	// Defaults to the pixel size of the image
+	Spacing float64
+
+	// Resampling is one of Nearest, Bilinear, Cubic or Lanczos.
+	//
+	// Defaults to Nearest
+	Resampling Resampling
+}
+
+// ProfilePoint is a sample of an elevation profile
+type ProfilePoint struct {
+	Point     Point   // WGS 84 longitude and latitude
+	Distance  float64 // distance along the path in metres
+	Elevation float64 // elevation, NaN outside the image or on nodata
+}
+
+// ElevationProfile is the elevation along a path
+type ElevationProfile struct {
+	Points        []ProfilePoint
+	Length        float64 // great circle length of the path in metres
+	SurfaceLength float64 // length of the path over the terrain in metres
+	Ascent        float64 // total climb along the path
+	Descent       float64 // total drop along the path, as a positive value
+}
+
+// Profile samples the image along a path of WGS 84 longitudes and
+// latitudes
+//
+// Each segment of the path follows the great circle between its ends, and
+// is divided into equal steps no longer than the spacing, with the
+// distances measured as by Point.Distance. Steps where either end has no
+// elevation add their distance to the surface length, but do not add to
+// the ascent or descent. opts may be nil.
+func (g *GeoTIFF) Profile(path []Point, opts *ProfileOptions) (*ElevationProfile, error) {
+	if opts == nil {
+		opts = &ProfileOptions{}
+	}
+	if len(path) < 2 {
+		return nil, fmt.Errorf("%w: %d points, at least 2 are required", errProfile, len(path))
+	}
+	if !opts.Resampling.interpolates() {
+		return nil, fmt.Errorf("%w: %s resampling is not supported, use nearest, bilinear, cubic or lanczos", errProfile, opts.Resampling)
+	}
+	if opts.Spacing < 0 {
+		return nil, fmt.Errorf("%w: negative spacing %v", errProfile, opts.Spacing)
+	}
+	crs, err := g.CRS()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errProfile, err)
+	}
+	wgs84, err := CRSFromEPSG(epsgWGS84)
+	if err != nil {
+		return nil, err
+	}
+	bounds, err := g.Bounds()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errProfile, err)
+	}
+
+	spacing := opts.Spacing
+	if spacing == 0 {
+		spacing = math.Min(g.PixelScaleX, g.PixelScaleY)
+		if crs.Geographic() {
+			spacing *= earthRadiusInMetres * math.Pi / 180
+		}
+	}
+
+	var points []Point
+	for k := 0; k+1 < len(path); k++ {
+		a, b := path[k], path[k+1]
+		steps := math.Ceil(a.Distance(b) / spacing)
+		if float64(len(points))+steps >= maxProfilePoints {
+			return nil, fmt.Errorf("%w: spacing %v gives more than %d points", errProfile, spacing, maxProfilePoints)
+		}
+		n := int(math.Max(steps, 1))
+		for s := 0; s < n; s++ {
+			points = append(points, intermediate(a, b, float64(s)/float64(n)))
+		}
+	}
+	points = append(points, path[len(path)-1])
+
+	raster := g.raster()
+	width, length := int(g.imageWidth), int(g.imageLength)
+	nd := g.noData()
+	p := &ElevationProfile{Points: make([]ProfilePoint, len(points))}
+	for k, pt := range points {
+		q, err := Transform(pt, wgs84, crs)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errProfile, err)
+		}
+		x := (q.Lon - bounds.UpperLeft.Lon) / g.PixelScaleX
+		y := (bounds.UpperLeft.Lat - q.Lat) / g.PixelScaleY
+		elevation := math.NaN()
+		if v := sample(raster, width, length, x, y, 1, 1, opts.Resampling, nd); !nd.is(v) {
+			elevation = float64(v)
+		}
+		p.Points[k] = ProfilePoint{Point: pt, Elevation: elevation}
+
+		if k == 0 {
+			continue
+		}
+		prev := &p.Points[k-1]
+		d := prev.Point.Distance(pt)
+		p.Points[k].Distance = prev.Distance + d
+		dz := elevation - prev.Elevation
+		if math.IsNaN(dz) {
+			p.SurfaceLength += d
+			continue
+		}
+		p.SurfaceLength += math.Hypot(d, dz)
+		if dz > 0 {
+			p.Ascent += dz
+		} else {
+			p.Descent -= dz
+		}
+	}
+	p.Length = p.Points[len(p.Points)-1].Distance
+	return p, nil
+}
+
+// intermediate returns the point a fraction f of the way along the great
+// circle from a to b
+//
+// See http://www.edwilliams.org/avform147.htm#Intermediate
+func intermediate(a Point, b Point, f float64) Point {
+	d2R := math.Pi / 180
+	delta := a.Distance(b) / earthRadiusInMetres
+	if f == 0 || delta == 0 {
+		return a
+	}
+	lat1, lon1 := a.Lat*d2R, a.Lon*d2R
+	lat2, lon2 := b.Lat*d2R, b.Lon*d2R
+	wa := math.Sin((1-f)*delta) / math.Sin(delta)
+	wb := math.Sin(f*delta) / math.Sin(delta)
+	x := wa*math.Cos(lat1)*math.Cos(lon1) + wb*math.Cos(lat2)*math.Cos(lon2)
+	y := wa*math.Cos(lat1)*math.Sin(lon1) + wb*math.Cos(lat2)*math.Sin(lon2)
+	z := wa*math.Sin(lat1) + wb*math.Sin(lat2)
+	return Point{
+		Lon: math.Atan2(y, x) / d2R,
+		Lat: math.Atan2(z, math.Hypot(x, y)) / d2R,
+	}
+}
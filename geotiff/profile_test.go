@@ -0,0 +1,120 @@
+package geotiff
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_Profile_Happy(t *testing.T) {
+	// rampGeoTIFF holds x + 10 * y in 0.1 degree pixels from 135, -20
+	g := rampGeoTIFF(t, 10, 10)
+
+	t.Run("along a row", func(t *testing.T) {
+		a, b := Point{Lon: 135.05, Lat: -20.05}, Point{Lon: 135.95, Lat: -20.05}
+		p, err := g.Profile([]Point{a, b}, &ProfileOptions{Spacing: 1000, Resampling: Bilinear})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := int(math.Ceil(a.Distance(b)/1000)) + 1
+		if len(p.Points) != want {
+			t.Fatalf("got %d points want %d", len(p.Points), want)
+		}
+		first, last := p.Points[0], p.Points[len(p.Points)-1]
+		if first.Distance != 0 || first.Point != a || last.Point != b {
+			t.Errorf("got ends %+v and %+v", first, last)
+		}
+		if !checkToTolerance(p.Length, a.Distance(b), 1e-6) || !checkToTolerance(last.Distance, p.Length, 1e-6) {
+			t.Errorf("got length %v want %v", p.Length, a.Distance(b))
+		}
+		// The great circle bows slightly south of the parallel, so the
+		// values rise and fall a little with the rows
+		if !checkToTolerance(first.Elevation, 0, 1e-3) || !checkToTolerance(last.Elevation, 9, 1e-3) {
+			t.Errorf("got elevations %v to %v want 0 to 9", first.Elevation, last.Elevation)
+		}
+		if !checkToTolerance(p.Ascent-p.Descent, 9, 1e-3) || p.Descent > 0.1 {
+			t.Errorf("got ascent %v descent %v want 9, 0", p.Ascent, p.Descent)
+		}
+		if p.SurfaceLength < p.Length || p.SurfaceLength > p.Length+1 {
+			t.Errorf("got surface length %v for length %v", p.SurfaceLength, p.Length)
+		}
+	})
+
+	t.Run("through vertices", func(t *testing.T) {
+		path := []Point{{Lon: 135.05, Lat: -20.05}, {Lon: 135.55, Lat: -20.05}, {Lon: 135.55, Lat: -20.55}}
+		p, err := g.Profile(path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, pt := range p.Points {
+			if pt.Point == path[1] {
+				found = true
+				if !checkToTolerance(pt.Distance, path[0].Distance(path[1]), 1e-6) || pt.Elevation != 5 {
+					t.Errorf("got %+v at the middle vertex", pt)
+				}
+			}
+		}
+		if !found {
+			t.Error("want the middle vertex in the profile")
+		}
+		// Nearest sampling steps 5 columns then 5 rows of 10
+		if p.Ascent != 55 || p.Descent != 0 {
+			t.Errorf("got ascent %v descent %v want 55, 0", p.Ascent, p.Descent)
+		}
+	})
+
+	t.Run("surface length", func(t *testing.T) {
+		// Rising 10 m every 10 m pixel, so the surface is a 45 degree slope
+		dem := demGeoTIFF(t, 28355, 300000, 6000000, 10, 100, 10, func(i, j int) float32 { return float32(10 * i) })
+		mga, _ := CRSFromEPSG(28355)
+		wgs84, _ := CRSFromEPSG(4326)
+		a, _ := Transform(Point{Lon: 300105, Lat: 5999950}, mga, wgs84)
+		b, _ := Transform(Point{Lon: 300895, Lat: 5999950}, mga, wgs84)
+		p, err := dem.Profile([]Point{a, b}, &ProfileOptions{Spacing: 5, Resampling: Bilinear})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The scale factor of MGA makes a metre on the ground slightly
+		// shorter than a metre of easting this far from the central meridian
+		if got := p.SurfaceLength / p.Length; !checkToTolerance(got, math.Sqrt2, 2e-3) {
+			t.Errorf("got surface length %v / length %v = %v want %v", p.SurfaceLength, p.Length, got, math.Sqrt2)
+		}
+	})
+
+	t.Run("nodata", func(t *testing.T) {
+		g := rampGeoTIFF(t, 10, 10)
+		g.SetNoData(5)
+		p, err := g.Profile([]Point{{Lon: 135.45, Lat: -20.05}, {Lon: 135.65, Lat: -20.05}}, &ProfileOptions{Spacing: 11000})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(p.Points) != 3 || !math.IsNaN(p.Points[1].Elevation) {
+			t.Fatalf("got %+v want nodata in the middle", p.Points)
+		}
+		if p.Ascent != 0 || p.SurfaceLength != p.Length {
+			t.Errorf("got ascent %v surface length %v want 0, %v", p.Ascent, p.SurfaceLength, p.Length)
+		}
+	})
+}
+
+func Test_Profile_Sad(t *testing.T) {
+	g := rampGeoTIFF(t, 10, 10)
+	path := []Point{{Lon: 135.05, Lat: -20.05}, {Lon: 135.95, Lat: -20.05}}
+	tests := []struct {
+		name string
+		path []Point
+		opts *ProfileOptions
+	}{
+		{"one point", path[:1], nil},
+		{"negative spacing", path, &ProfileOptions{Spacing: -1}},
+		{"aggregating resampling", path, &ProfileOptions{Resampling: Average}},
+		{"too many points", path, &ProfileOptions{Spacing: 1e-3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := g.Profile(tt.path, tt.opts); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
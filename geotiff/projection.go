@@ -0,0 +1,309 @@
+package geotiff
+
+import (
+	"math"
+)
+
+// ellipsoid is the figure of the earth used by a coordinate reference system
+type ellipsoid struct {
+	a    float64 // semi-major axis in metres
+	invF float64 // inverse flattening
+}
+
+var (
+	wgs84Ellipsoid = ellipsoid{a: 6378137, invF: 298.257223563}
+	grs80Ellipsoid = ellipsoid{a: 6378137, invF: 298.257222101}
+)
+
+// e returns the first eccentricity of the ellipsoid
+func (el ellipsoid) e() float64 {
+	f := 1 / el.invF
+	return math.Sqrt(f * (2 - f))
+}
+
+// projection converts between geographic coordinates, longitude and
+// latitude in radians, and projected coordinates in metres
+type projection interface {
+	forward(lon float64, lat float64) (float64, float64)
+	inverse(x float64, y float64) (float64, float64)
+}
+
+// wrapLongitude returns a longitude in radians in the range [-π, π]
+func wrapLongitude(lon float64) float64 {
+	if lon < -math.Pi || lon > math.Pi {
+		lon = math.Remainder(lon, 2*math.Pi)
+	}
+	return lon
+}
+
+// webMercator is the spherical Mercator projection used by web maps
+//
+// From the EPSG Guidance Note 7-2 (1.3.3.2)
+//
+// Popular Visualisation Pseudo Mercator uses the spherical formulas of the
+// Mercator projection with the semi-major axis of the ellipsoid as the radius.
+type webMercator struct {
+	r float64
+}
+
+// webMercatorMaxLat is the latitude in radians at which the projection is
+// square, beyond which web map tiles are not defined
+var webMercatorMaxLat = 2*math.Atan(math.Exp(math.Pi)) - math.Pi/2
+
+func (p webMercator) forward(lon float64, lat float64) (float64, float64) {
+	lat = math.Max(-webMercatorMaxLat, math.Min(webMercatorMaxLat, lat))
+	return p.r * wrapLongitude(lon), p.r * math.Log(math.Tan(math.Pi/4+lat/2))
+}
+
+func (p webMercator) inverse(x float64, y float64) (float64, float64) {
+	return x / p.r, 2*math.Atan(math.Exp(y/p.r)) - math.Pi/2
+}
+
+// transverseMercator is the ellipsoidal Transverse Mercator projection used
+// by UTM and the Map Grid of Australia
+//
+// The projection is computed with the Krüger series to sixth order in n,
+// accurate to well under a millimetre within 3,900 km of the central meridian.
+//
+// See C. F. F. Karney (2011), Transverse Mercator with an accuracy of a few
+// nanometers, https://doi.org/10.1007/s00190-011-0445-3
+type transverseMercator struct {
+	lon0          float64 // central meridian
+	k0            float64 // scale factor on the central meridian
+	falseEasting  float64
+	falseNorthing float64
+	e             float64
+	scale         float64 // k0 multiplied by the rectifying radius
+	xi0           float64 // ξ of the latitude of origin
+	alpha         [6]float64
+	beta          [6]float64
+}
+
+func newTransverseMercator(el ellipsoid, lat0 float64, lon0 float64, k0 float64, fe float64, fn float64) *transverseMercator {
+	f := 1 / el.invF
+	n := f / (2 - f)
+	n2, n3 := n*n, n*n*n
+	n4, n5, n6 := n3*n, n3*n2, n3*n3
+	p := &transverseMercator{
+		lon0:          lon0,
+		k0:            k0,
+		falseEasting:  fe,
+		falseNorthing: fn,
+		e:             el.e(),
+		scale:         k0 * el.a / (1 + n) * (1 + n2/4 + n4/64 + n6/256),
+		alpha: [6]float64{
+			n/2 - 2*n2/3 + 5*n3/16 + 41*n4/180 - 127*n5/288 + 7891*n6/37800,
+			13*n2/48 - 3*n3/5 + 557*n4/1440 + 281*n5/630 - 1983433*n6/1935360,
+			61*n3/240 - 103*n4/140 + 15061*n5/26880 + 167603*n6/181440,
+			49561*n4/161280 - 179*n5/168 + 6601661*n6/7257600,
+			34729*n5/80640 - 3418889*n6/1995840,
+			212378941 * n6 / 319334400,
+		},
+		beta: [6]float64{
+			n/2 - 2*n2/3 + 37*n3/96 - n4/360 - 81*n5/512 + 96199*n6/604800,
+			n2/48 + n3/15 - 437*n4/1440 + 46*n5/105 - 1118711*n6/3870720,
+			17*n3/480 - 37*n4/840 - 209*n5/4480 + 5569*n6/90720,
+			4397*n4/161280 - 11*n5/504 - 830251*n6/7257600,
+			4583*n5/161280 - 108847*n6/3991680,
+			20648693 * n6 / 638668800,
+		},
+	}
+	p.xi0, _ = p.gauss(0, lat0)
+	return p
+}
+
+// gauss returns the Gauss-Krüger coordinates ξ, η of a point relative to
+// the central meridian
+func (p *transverseMercator) gauss(lon float64, lat float64) (float64, float64) {
+	sinLat := math.Sin(lat)
+	t := math.Sinh(math.Atanh(sinLat) - p.e*math.Atanh(p.e*sinLat))
+	xiP := math.Atan2(t, math.Cos(lon))
+	etaP := math.Atanh(math.Sin(lon) / math.Sqrt(1+t*t))
+	xi, eta := xiP, etaP
+	for j, a := range p.alpha {
+		k := 2 * float64(j+1)
+		xi += a * math.Sin(k*xiP) * math.Cosh(k*etaP)
+		eta += a * math.Cos(k*xiP) * math.Sinh(k*etaP)
+	}
+	return xi, eta
+}
+
+func (p *transverseMercator) forward(lon float64, lat float64) (float64, float64) {
+	xi, eta := p.gauss(wrapLongitude(lon-p.lon0), lat)
+	return p.falseEasting + p.scale*eta, p.falseNorthing + p.scale*(xi-p.xi0)
+}
+
+func (p *transverseMercator) inverse(x float64, y float64) (float64, float64) {
+	xi := (y-p.falseNorthing)/p.scale + p.xi0
+	eta := (x - p.falseEasting) / p.scale
+	xiP, etaP := xi, eta
+	for j, b := range p.beta {
+		k := 2 * float64(j+1)
+		xiP -= b * math.Sin(k*xi) * math.Cosh(k*eta)
+		etaP -= b * math.Cos(k*xi) * math.Sinh(k*eta)
+	}
+	tauP := math.Sin(xiP) / math.Sqrt(math.Sinh(etaP)*math.Sinh(etaP)+math.Cos(xiP)*math.Cos(xiP))
+	lon := math.Atan2(math.Sinh(etaP), math.Cos(xiP))
+
+	// Newton's method for the conformal latitude, following Karney (2011)
+	e2 := p.e * p.e
+	tau := tauP
+	for i := 0; i < 10; i++ {
+		sigma := math.Sinh(p.e * math.Atanh(p.e*tau/math.Sqrt(1+tau*tau)))
+		tauI := tau*math.Sqrt(1+sigma*sigma) - sigma*math.Sqrt(1+tau*tau)
+		delta := (tauP - tauI) / math.Sqrt(1+tauI*tauI) *
+			(1 + (1-e2)*tau*tau) / ((1 - e2) * math.Sqrt(1+tau*tau))
+		tau += delta
+		if math.Abs(delta) < 1e-14 {
+			break
+		}
+	}
+	return wrapLongitude(lon + p.lon0), math.Atan(tau)
+}
+
+// lambertConformalConic is the ellipsoidal Lambert Conformal Conic
+// projection with one or two standard parallels
+//
+// From J. P. Snyder (1987), Map Projections: A Working Manual (p.107)
+type lambertConformalConic struct {
+	a             float64
+	e             float64
+	lon0          float64
+	falseEasting  float64
+	falseNorthing float64
+	n             float64 // cone constant
+	f             float64 // a * F * k0
+	rho0          float64
+}
+
+// newLambertConformalConic returns the projection for standard parallels
+// lat1 and lat2, which are equal for the one standard parallel variant
+// where k0 scales the parallel
+func newLambertConformalConic(el ellipsoid, lat0 float64, lon0 float64, lat1 float64, lat2 float64, k0 float64, fe float64, fn float64) *lambertConformalConic {
+	p := &lambertConformalConic{a: el.a, e: el.e(), lon0: lon0, falseEasting: fe, falseNorthing: fn}
+	m1, m2 := p.m(lat1), p.m(lat2)
+	t0, t1, t2 := p.t(lat0), p.t(lat1), p.t(lat2)
+	if lat1 == lat2 {
+		p.n = math.Sin(lat1)
+	} else {
+		p.n = (math.Log(m1) - math.Log(m2)) / (math.Log(t1) - math.Log(t2))
+	}
+	p.f = el.a * k0 * m1 / (p.n * math.Pow(t1, p.n))
+	p.rho0 = p.f * math.Pow(t0, p.n)
+	return p
+}
+
+func (p *lambertConformalConic) m(lat float64) float64 {
+	s := p.e * math.Sin(lat)
+	return math.Cos(lat) / math.Sqrt(1-s*s)
+}
+
+func (p *lambertConformalConic) t(lat float64) float64 {
+	s := p.e * math.Sin(lat)
+	return math.Tan(math.Pi/4-lat/2) / math.Pow((1-s)/(1+s), p.e/2)
+}
+
+func (p *lambertConformalConic) forward(lon float64, lat float64) (float64, float64) {
+	var rho float64
+	if math.Abs(math.Abs(lat)-math.Pi/2) > 1e-12 {
+		rho = p.f * math.Pow(p.t(lat), p.n)
+	}
+	theta := p.n * wrapLongitude(lon-p.lon0)
+	return p.falseEasting + rho*math.Sin(theta), p.falseNorthing + p.rho0 - rho*math.Cos(theta)
+}
+
+func (p *lambertConformalConic) inverse(x float64, y float64) (float64, float64) {
+	x -= p.falseEasting
+	y = p.rho0 - (y - p.falseNorthing)
+	sign := math.Copysign(1, p.n)
+	rho := sign * math.Hypot(x, y)
+	theta := math.Atan2(sign*x, sign*y)
+	if rho == 0 {
+		return p.lon0, sign * math.Pi / 2
+	}
+	t := math.Pow(rho/p.f, 1/p.n)
+	lat := math.Pi/2 - 2*math.Atan(t)
+	for i := 0; i < 15; i++ {
+		s := p.e * math.Sin(lat)
+		next := math.Pi/2 - 2*math.Atan(t*math.Pow((1-s)/(1+s), p.e/2))
+		if math.Abs(next-lat) < 1e-14 {
+			lat = next
+			break
+		}
+		lat = next
+	}
+	return wrapLongitude(theta/p.n + p.lon0), lat
+}
+
+// albersEqualArea is the ellipsoidal Albers Equal Area Conic projection
+//
+// From J. P. Snyder (1987), Map Projections: A Working Manual (p.101)
+type albersEqualArea struct {
+	a             float64
+	e             float64
+	lon0          float64
+	falseEasting  float64
+	falseNorthing float64
+	n             float64
+	c             float64
+	rho0          float64
+}
+
+func newAlbersEqualArea(el ellipsoid, lat0 float64, lon0 float64, lat1 float64, lat2 float64, fe float64, fn float64) *albersEqualArea {
+	p := &albersEqualArea{a: el.a, e: el.e(), lon0: lon0, falseEasting: fe, falseNorthing: fn}
+	m1, m2 := p.m(lat1), p.m(lat2)
+	q0, q1, q2 := p.q(lat0), p.q(lat1), p.q(lat2)
+	if lat1 == lat2 {
+		p.n = math.Sin(lat1)
+	} else {
+		p.n = (m1*m1 - m2*m2) / (q2 - q1)
+	}
+	p.c = m1*m1 + p.n*q1
+	p.rho0 = p.a * math.Sqrt(p.c-p.n*q0) / p.n
+	return p
+}
+
+func (p *albersEqualArea) m(lat float64) float64 {
+	s := p.e * math.Sin(lat)
+	return math.Cos(lat) / math.Sqrt(1-s*s)
+}
+
+func (p *albersEqualArea) q(lat float64) float64 {
+	sinLat := math.Sin(lat)
+	s := p.e * sinLat
+	return (1 - p.e*p.e) * (sinLat/(1-s*s) - 1/(2*p.e)*math.Log((1-s)/(1+s)))
+}
+
+func (p *albersEqualArea) forward(lon float64, lat float64) (float64, float64) {
+	rho := p.a * math.Sqrt(p.c-p.n*p.q(lat)) / p.n
+	theta := p.n * wrapLongitude(lon-p.lon0)
+	return p.falseEasting + rho*math.Sin(theta), p.falseNorthing + p.rho0 - rho*math.Cos(theta)
+}
+
+func (p *albersEqualArea) inverse(x float64, y float64) (float64, float64) {
+	x -= p.falseEasting
+	y = p.rho0 - (y - p.falseNorthing)
+	sign := math.Copysign(1, p.n)
+	rho := math.Hypot(x, y)
+	theta := math.Atan2(sign*x, sign*y)
+	q := (p.c - rho*rho*p.n*p.n/(p.a*p.a)) / p.n
+
+	e2 := p.e * p.e
+	// q at the poles, beyond which the iteration does not converge
+	qPole := 1 - (1-e2)/(2*p.e)*math.Log((1-p.e)/(1+p.e))
+	if math.Abs(math.Abs(q)-qPole) < 1e-12 {
+		return wrapLongitude(theta/p.n + p.lon0), math.Copysign(math.Pi/2, q)
+	}
+	lat := math.Asin(math.Max(-1, math.Min(1, q/2)))
+	for i := 0; i < 15; i++ {
+		sinLat := math.Sin(lat)
+		s := p.e * sinLat
+		delta := (1 - s*s) * (1 - s*s) / (2 * math.Cos(lat)) *
+			(q/(1-e2) - sinLat/(1-s*s) + 1/(2*p.e)*math.Log((1-s)/(1+s)))
+		lat += delta
+		if math.Abs(delta) < 1e-14 {
+			break
+		}
+	}
+	return wrapLongitude(theta/p.n + p.lon0), lat
+}
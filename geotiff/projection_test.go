@@ -0,0 +1,108 @@
+package geotiff
+
+import (
+	"math"
+	"testing"
+)
+
+func dms(d float64, m float64, s float64) float64 {
+	return math.Copysign(math.Abs(d)+m/60+s/3600, d)
+}
+
+func Test_Projection_Happy(t *testing.T) {
+	d2R := func(deg float64) float64 { return deg * math.Pi / 180 }
+	// Snyder's worked examples use the Clarke 1866 ellipsoid
+	clarke1866 := ellipsoid{a: 6378206.4, invF: 294.9786982}
+
+	tests := []struct {
+		name      string
+		proj      projection
+		lon       float64
+		lat       float64
+		x         float64
+		y         float64
+		tolerance float64 // in metres, the inverse is checked to the equivalent in degrees
+	}{
+		{
+			// GDA94 Technical Manual, Flinders Peak in MGA zone 55
+			name:      "transverse mercator",
+			proj:      newTransverseMercator(grs80Ellipsoid, 0, d2R(147), 0.9996, 500000, 10000000),
+			lon:       dms(144, 25, 29.5244),
+			lat:       dms(-37, 57, 3.7203),
+			x:         273741.297,
+			y:         5796489.777,
+			tolerance: 1e-3,
+		},
+		{
+			name:      "transverse mercator origin",
+			proj:      newTransverseMercator(wgs84Ellipsoid, 0, d2R(9), 0.9996, 500000, 0),
+			lon:       9,
+			lat:       0,
+			x:         500000,
+			y:         0,
+			tolerance: 1e-9,
+		},
+		{
+			name:      "web mercator",
+			proj:      webMercator{r: wgs84Ellipsoid.a},
+			lon:       135,
+			lat:       -20,
+			x:         15028131.257,
+			y:         -2273030.927,
+			tolerance: 1e-3,
+		},
+		{
+			// Snyder (1987), numerical example p.292
+			name:      "albers equal area",
+			proj:      newAlbersEqualArea(clarke1866, d2R(23), d2R(-96), d2R(29.5), d2R(45.5), 0, 0),
+			lon:       -75,
+			lat:       35,
+			x:         1885472.7,
+			y:         1535925.0,
+			tolerance: 0.1,
+		},
+		{
+			// Snyder (1987), numerical example p.296
+			name:      "lambert conformal conic",
+			proj:      newLambertConformalConic(clarke1866, d2R(23), d2R(-96), d2R(33), d2R(45), 1, 0, 0),
+			lon:       -75,
+			lat:       35,
+			x:         1894410.9,
+			y:         1564649.5,
+			tolerance: 0.1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x, y := tt.proj.forward(d2R(tt.lon), d2R(tt.lat))
+			if !checkToTolerance(x, tt.x, tt.tolerance) || !checkToTolerance(y, tt.y, tt.tolerance) {
+				t.Errorf("got %.4f, %.4f want %.4f, %.4f", x, y, tt.x, tt.y)
+			}
+			// one degree is at most 111 km
+			degrees := math.Max(tt.tolerance, 1e-3) / 1e5
+			lon, lat := tt.proj.inverse(tt.x, tt.y)
+			if !checkToTolerance(lon*180/math.Pi, tt.lon, degrees) || !checkToTolerance(lat*180/math.Pi, tt.lat, degrees) {
+				t.Errorf("got inverse %.9f, %.9f want %.9f, %.9f", lon*180/math.Pi, lat*180/math.Pi, tt.lon, tt.lat)
+			}
+		})
+	}
+}
+
+func Test_Projection_RoundTrip(t *testing.T) {
+	for _, code := range []int{3857, 28350, 28355, 7856, 32755, 3577, 9473, 3112, 7845} {
+		crs, err := CRSFromEPSG(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, lon := range []float64{113, 125.5, 134, 147.25, 153.6} {
+			for _, lat := range []float64{-43.5, -35, -25.3, -10.7} {
+				lonR, latR := lon*math.Pi/180, lat*math.Pi/180
+				x, y := crs.proj.forward(lonR, latR)
+				gotLon, gotLat := crs.proj.inverse(x, y)
+				if !checkToTolerance(gotLon, lonR, 1e-11) || !checkToTolerance(gotLat, latR, 1e-11) {
+					t.Errorf("%s: %v, %v round tripped to %v, %v", crs, lon, lat, gotLon*180/math.Pi, gotLat*180/math.Pi)
+				}
+			}
+		}
+	}
+}
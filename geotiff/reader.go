@@ -115,6 +115,37 @@ func (ifd *iFDEntry) value(r io.ReadSeeker, byteOrder binary.ByteOrder) (*tagDat
 		if err := binary.Read(r, byteOrder, t.doubleData); err != nil {
 			return nil, err
 		}
+	case SBYTE:
+		t.sbyteData = make([]int8, ifd.Count)
+		if err := binary.Read(r, byteOrder, t.sbyteData); err != nil {
+			return nil, err
+		}
+	case UNDEFINED:
+		t.undefinedData = make([]uint8, ifd.Count)
+		if err := binary.Read(r, byteOrder, t.undefinedData); err != nil {
+			return nil, err
+		}
+	case SSHORT:
+		t.sshortData = make([]int16, ifd.Count)
+		if err := binary.Read(r, byteOrder, t.sshortData); err != nil {
+			return nil, err
+		}
+	case SLONG:
+		t.slongData = make([]int32, ifd.Count)
+		if err := binary.Read(r, byteOrder, t.slongData); err != nil {
+			return nil, err
+		}
+	case RATIONAL:
+		// Each rational is a numerator followed by a denominator
+		t.rationalData = make([]uint32, 2*ifd.Count)
+		if err := binary.Read(r, byteOrder, t.rationalData); err != nil {
+			return nil, err
+		}
+	case SRATIONAL:
+		t.srationalData = make([]int32, 2*ifd.Count)
+		if err := binary.Read(r, byteOrder, t.srationalData); err != nil {
+			return nil, err
+		}
 	}
 	return &t, nil
 }
@@ -164,17 +195,25 @@ func readHeader(r io.Reader) (head, error) {
 // is supposed to act similar to a union
 // where only one data field is used at any one time
 type tagData struct {
-	fType      fieldType
-	length     uint32
-	byteData   []uint8
-	asciiData  string
-	shortData  []uint16
-	longData   []uint32
-	floatData  []float32
-	doubleData []float64
+	fType         fieldType
+	length        uint32
+	byteData      []uint8
+	asciiData     string
+	shortData     []uint16
+	longData      []uint32
+	floatData     []float32
+	doubleData    []float64
+	sbyteData     []int8
+	undefinedData []uint8
+	sshortData    []int16
+	slongData     []int32
+	rationalData  []uint32 // numerator and denominator pairs
+	srationalData []int32  // numerator and denominator pairs
 }
 
 // Tags holds the tag files
+//
+// Tag values are retrieved with the typed accessors, e.g. Tags.Uint64s
 type Tags map[Tag]tagData
 
 // String converts types to strings
@@ -193,6 +232,18 @@ func (t tagData) String() string {
 		dataStr = fmt.Sprintf("%v", t.byteData)
 	case ASCII:
 		dataStr = fmt.Sprintf("%v", t.asciiData)
+	case SBYTE:
+		dataStr = fmt.Sprintf("%v", t.sbyteData)
+	case UNDEFINED:
+		dataStr = fmt.Sprintf("%v", t.undefinedData)
+	case SSHORT:
+		dataStr = fmt.Sprintf("%v", t.sshortData)
+	case SLONG:
+		dataStr = fmt.Sprintf("%v", t.slongData)
+	case RATIONAL:
+		dataStr = fmt.Sprintf("%v", t.rationalData)
+	case SRATIONAL:
+		dataStr = fmt.Sprintf("%v", t.srationalData)
 	}
 	return t.fType.String() + " " + fmt.Sprintf("%d", t.length) + " " + dataStr
 }
@@ -211,18 +262,47 @@ func (t tagData) value() (fieldType, []interface{}) {
 		return t.fType, []interface{}{t.byteData}
 	case ASCII:
 		return t.fType, []interface{}{t.asciiData}
+	case SBYTE:
+		return t.fType, []interface{}{t.sbyteData}
+	case UNDEFINED:
+		return t.fType, []interface{}{t.undefinedData}
+	case SSHORT:
+		return t.fType, []interface{}{t.sshortData}
+	case SLONG:
+		return t.fType, []interface{}{t.slongData}
+	case RATIONAL:
+		return t.fType, []interface{}{t.rationalData}
+	case SRATIONAL:
+		return t.fType, []interface{}{t.srationalData}
 	}
 	return NONE, nil
 }
 
-// readTags reads the tags of a GeoTIFF file
+// ifd is a single image file directory of a TIFF file
+type ifd struct {
+	offset uint32 // offset of the IFD from the start of the file
+	tags   Tags
+}
+
+// readTags reads the tags of the first image of a GeoTIFF file
+//
+// Any further IFDs, such as overviews, are not included
 func readTags(r io.ReadSeeker) (Tags, head, error) {
-	tags := make(Tags)
+	ifds, h, err := readIFDs(r)
+	if err != nil {
+		return make(Tags), h, err
+	}
+	return ifds[0].tags, h, nil
+}
+
+// readIFDs reads the tags of every IFD in a GeoTIFF file
+func readIFDs(r io.ReadSeeker) ([]ifd, head, error) {
+	var ifds []ifd
 
 	// read the header tag to extract the IFD Byte offset
 	h, err := readHeader(r)
 	if err != nil {
-		return tags, h, fmt.Errorf("failed to read tiff header: %w", err)
+		return ifds, h, fmt.Errorf("failed to read tiff header: %w", err)
 	}
 
 	// Get the first IFD entry via the IFD Byte offset recorded in the header
@@ -239,10 +319,25 @@ func readTags(r io.ReadSeeker) (Tags, head, error) {
 	// 0.
 	iFDOffset := h.iFDByteOffset
 
+	// The file size is used to reject values which lie beyond the end of the
+	// file, rather than allocating space for them
+	fileSize, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return ifds, h, fmt.Errorf("error: unable to find file size: %w", err)
+	}
+
+	visited := make(map[uint32]bool)
 	for iFDOffset != 0 {
+		if visited[iFDOffset] {
+			return ifds, h, fmt.Errorf("error: IFD at %d is referenced twice", iFDOffset)
+		}
+		visited[iFDOffset] = true
+		tags := make(Tags)
+		ifds = append(ifds, ifd{offset: iFDOffset, tags: tags})
+
 		// Jump to the IFD Byte Offset
 		if _, err := r.Seek(int64(iFDOffset), io.SeekStart); err != nil {
-			return tags, h, fmt.Errorf("error: unable to seek to start of IFD at %d", iFDOffset)
+			return ifds, h, fmt.Errorf("error: unable to seek to start of IFD at %d", iFDOffset)
 		}
 
 		// Per the TIFF 6.0 Specification (p.14)
@@ -251,18 +346,18 @@ func readTags(r io.ReadSeeker) (Tags, head, error) {
 		// first two bytes of each IFD
 		var numDirectoryEntries uint16
 		if err := binary.Read(r, h.byteOrder, &numDirectoryEntries); err != nil {
-			return tags, h, errors.New("error: unable to read directory entry")
+			return ifds, h, errors.New("error: unable to read directory entry")
 		}
 		var nextDirOffset int64
 		for i := uint16(0); i < numDirectoryEntries; i++ {
 
 			var iFDEntry iFDEntry
 			if err := binary.Read(r, h.byteOrder, &iFDEntry); err != nil {
-				return tags, h, err
+				return ifds, h, err
 			}
 
 			if iFDEntry.FType.bytes() == 0 {
-				return tags, h, fmt.Errorf("error: unrecognized tag %d", iFDEntry.Tag)
+				return ifds, h, fmt.Errorf("error: unrecognized tag %d", iFDEntry.Tag)
 			}
 
 			// Per  the TIFF 6.0 Specification
@@ -278,83 +373,225 @@ func readTags(r io.ReadSeeker) (Tags, head, error) {
 				iFDEntry.ValueOffset = uint32(currentOffset) - fourByte
 			}
 
+			if int64(iFDEntry.ValueOffset)+int64(iFDEntry.Count)*int64(iFDEntry.FType.bytes()) > fileSize {
+				return ifds, h, fmt.Errorf("error: tag %s value at %d extends beyond the end of the file",
+					iFDEntry.Tag, iFDEntry.ValueOffset)
+			}
+
 			nextDirOffset, _ = r.Seek(0, io.SeekCurrent)
 
 			// Read the tags
 			tagName := iFDEntry.Tag
 			tagvalue, err := iFDEntry.value(r, h.byteOrder)
 			if err != nil {
-				return tags, h, err
+				return ifds, h, err
 			}
 			tags[tagName] = *tagvalue
 
 			// Jump to the next directory entry
 			if _, err := r.Seek(nextDirOffset, io.SeekStart); err != nil {
-				return tags, h, fmt.Errorf("err: could not jump to next directory: %w", err)
+				return ifds, h, fmt.Errorf("err: could not jump to next directory: %w", err)
 			}
 		}
 
 		if err = binary.Read(r, h.byteOrder, &iFDOffset); err != nil {
-			return tags, h, fmt.Errorf("err: could not jump to next file: %w", err)
+			return ifds, h, fmt.Errorf("err: could not jump to next file: %w", err)
 		}
 	}
-	return tags, h, nil
+	if len(ifds) == 0 {
+		return ifds, h, errors.New("error: no IFD in file")
+	}
+	return ifds, h, nil
 }
 
 var errGeoTIFFData = errors.New("could not read GeoTIFF data")
 
-// readData reads the data from a  tiled GeoTIFF file
-// into a 1D 32bit float array
+// layout describes how the image data is divided into blocks
+//
+// Strips are treated as tiles which span the full width of the image, with
+// a length of RowsPerStrip.
+type layout struct {
+	imageWidth    uint16
+	imageLength   uint16
+	tileWidth     uint16
+	tileLength    uint16
+	bitsPerSample uint16
+	compression   uint16
+	predictor     uint16
+	offsets       []uint64
+	byteCounts    []uint64
+}
+
+// readLayout reads the image layout from the tags, for the 32 bit samples
+// this package reads
+func readLayout(tags Tags) (*layout, error) {
+	l, err := parseLayout(tags)
+	if err != nil {
+		return nil, err
+	}
+	if l.bitsPerSample != 32 {
+		return nil, fmt.Errorf("%w, unsupported %s %d", errGeoTIFFData, BitsPerSample, l.bitsPerSample)
+	}
+	if v, err := tags.Uint64s(SampleFormat); err == nil && len(v) > 0 && v[0] != uint64(sampleFormatIEEEFP) {
+		return nil, fmt.Errorf("%w, unsupported %s %d, only floating point samples are supported", errGeoTIFFData, SampleFormat, v[0])
+	}
+	if v, err := tags.Uint64s(SamplesPerPixel); err == nil && len(v) > 0 && v[0] != 1 {
+		return nil, fmt.Errorf("%w, unsupported %s %d, only single band images are supported", errGeoTIFFData, SamplesPerPixel, v[0])
+	}
+	if len(l.offsets) != len(l.byteCounts) {
+		return nil, fmt.Errorf("%w, mismatched block offsets and byte counts", errGeoTIFFData)
+	}
+	return l, nil
+}
+
+// parseLayout reads the image layout from the tags, for samples of any size
+func parseLayout(tags Tags) (*layout, error) {
+	field := func(t Tag, def uint64) (uint64, error) {
+		v, err := tags.Uint64s(t)
+		if errors.Is(err, ErrTagNotFound) && def != 0 {
+			return def, nil
+		}
+		if err != nil || len(v) == 0 {
+			return 0, fmt.Errorf("%w, could not retrieve %s", errGeoTIFFData, t)
+		}
+		return v[0], nil
+	}
+
+	l := layout{}
+	var err error
+	values := []struct {
+		tag Tag
+		def uint64
+		dst *uint16
+	}{
+		{ImageWidth, 0, &l.imageWidth},
+		{ImageLength, 0, &l.imageLength},
+		{BitsPerSample, 1, &l.bitsPerSample},
+		{Compression, compressionNone, &l.compression},
+		{Predictor, predictorNone, &l.predictor},
+	}
+	for _, v := range values {
+		n, err := field(v.tag, v.def)
+		if err != nil {
+			return nil, err
+		}
+		if n > math.MaxUint16 {
+			return nil, fmt.Errorf("%w, unsupported %s %d", errGeoTIFFData, v.tag, n)
+		}
+		*v.dst = uint16(n)
+	}
+
+	var tileWidth, tileLength uint64
+	if _, ok := tags[TileWidth]; ok {
+		if tileWidth, err = field(TileWidth, 0); err != nil {
+			return nil, err
+		}
+		if tileLength, err = field(TileLength, 0); err != nil {
+			return nil, err
+		}
+		if l.offsets, err = tags.Uint64s(TileOffsets); err != nil {
+			return nil, fmt.Errorf("%w, could not retrieve %s", errGeoTIFFData, TileOffsets)
+		}
+		if l.byteCounts, err = tags.Uint64s(TileByteCounts); err != nil {
+			return nil, fmt.Errorf("%w, could not retrieve %s", errGeoTIFFData, TileByteCounts)
+		}
+	} else {
+		// Per the TIFF 6.0 Specification (p.39)
+		//
+		// RowsPerStrip defaults to 2**32-1, which is effectively infinity.
+		// That is, the entire image is one strip.
+		tileWidth = uint64(l.imageWidth)
+		if tileLength, err = field(RowsPerStrip, math.MaxUint32); err != nil {
+			return nil, err
+		}
+		if l.offsets, err = tags.Uint64s(StripOffsets); err != nil {
+			return nil, fmt.Errorf("%w, could not retrieve %s", errGeoTIFFData, StripOffsets)
+		}
+		if l.byteCounts, err = tags.Uint64s(StripByteCounts); err != nil {
+			return nil, fmt.Errorf("%w, could not retrieve %s", errGeoTIFFData, StripByteCounts)
+		}
+		if tileLength > uint64(l.imageLength) {
+			tileLength = uint64(l.imageLength)
+		}
+	}
+	if tileWidth == 0 || tileLength == 0 || tileWidth > math.MaxUint16 || tileLength > math.MaxUint16 {
+		return nil, fmt.Errorf("%w, unsupported block size %dx%d", errGeoTIFFData, tileWidth, tileLength)
+	}
+	l.tileWidth = uint16(tileWidth)
+	l.tileLength = uint16(tileLength)
+
+	if l.imageWidth == 0 || l.imageLength == 0 {
+		return nil, fmt.Errorf("%w, empty image", errGeoTIFFData)
+	}
+	return &l, nil
+}
+
+// blocks returns the number of tiles or strips in the image
+//
+// From the Tiff 6.0 Specification (p. 67)
+func (l *layout) blocks() int {
+	tilesAcross := (int(l.imageWidth) + int(l.tileWidth) - 1) / int(l.tileWidth)
+	tilesDown := (int(l.imageLength) + int(l.tileLength) - 1) / int(l.tileLength)
+	return tilesAcross * tilesDown
+}
+
+// blockSize returns the number of bytes in each decompressed tile or strip
+func (l *layout) blockSize() int {
+	return int(l.tileWidth) * int(l.tileLength) * int(l.bitsPerSample) / 8
+}
+
+// checkBlocks checks that every block lies within a stream, before any block
+// is read
+func (l *layout) checkBlocks(r io.Seeker) error {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	for i, offset := range l.offsets {
+		if offset > uint64(size) || l.byteCounts[i] > uint64(size)-offset {
+			return fmt.Errorf("block %d of %d bytes at offset %d ends beyond the %d bytes of the file", i, l.byteCounts[i], offset, size)
+		}
+	}
+	return nil
+}
+
+// readData reads the data from a tiled or stripped GeoTIFF file
+// into 1D 32bit float arrays, one for each tile
+//
+// Strips are padded to RowsPerStrip rows, so that every block holds
+// tileWidth * tileLength values.
 func readData(r io.ReadSeeker, tags Tags, header head) ([][]float32, error) {
-	fields := [...]Tag{BitsPerSample, ImageLength, ImageWidth, TileWidth, TileLength, TileByteCounts, TileOffsets}
-	shortData := make(map[Tag][]uint16, 0)
-	longData := make(map[Tag][]uint32, 0)
-	for _, f := range fields {
-		v, ok := tags[f]
-		if !ok {
-			return nil, fmt.Errorf("%w, could not retrieve %s", errGeoTIFFData, f)
-		}
-		ftype, elem := v.value()
-		switch ftype {
-		case SHORT:
-			shortData[f] = elem[0].([]uint16)
-		case LONG:
-			longData[f] = elem[0].([]uint32)
-		default:
-			return nil, fmt.Errorf("%w, incorrect data type for %s - %s", errGeoTIFFData, f, ftype)
-
-		}
-	}
-
-	imageWidth := shortData[ImageWidth][0]
-	imageLength := shortData[ImageLength][0]
-	tileWidth := shortData[TileWidth][0]
-	tileLength := shortData[TileLength][0]
-	bitsPerSample := shortData[BitsPerSample][0]
-	offsets := longData[TileOffsets]
-	byteCounts := longData[TileByteCounts]
-
-	// From the Tiff 6.0 Specification (p. 67)
-	tilesAcross := (imageWidth + tileWidth - 1) / tileWidth
-	tilesDown := (imageLength + tileLength - 1) / tileLength
-	tilesPerImage := tilesAcross * tilesDown
+	l, err := readLayout(tags)
+	if err != nil {
+		return nil, err
+	}
 
-	if int(tilesPerImage) != len(offsets) {
+	tilesPerImage := l.blocks()
+	if tilesPerImage != len(l.offsets) {
 		return nil, errors.New("invalid number of offsets for tiles")
 	}
 
+	if err := l.checkBlocks(r); err != nil {
+		return nil, fmt.Errorf("%w: %s", errGeoTIFFData, err)
+	}
+
 	data := make([][]float32, 0, tilesPerImage)
-	for i, offset := range offsets {
-		numPixels := uint32(byteCounts[i]) / (uint32(bitsPerSample) / eightByte)
-		tileData := make([]float32, numPixels)
+	for i, offset := range l.offsets {
 		if _, err := r.Seek(int64(offset), io.SeekStart); err != nil {
 			return nil, fmt.Errorf("%w: could not find offset: got %s", errGeoTIFFData, err)
 		}
-
-		if err := binary.Read(r, header.byteOrder, &tileData); err != nil {
+		raw := make([]byte, l.byteCounts[i])
+		if _, err := io.ReadFull(r, raw); err != nil {
 			return nil, fmt.Errorf("%w: could not read bytes: got %s", errGeoTIFFData, err)
 		}
 
+		tileData, err := decodeBlock(raw, int(l.tileWidth), l.blockSize(), l.compression, l.predictor, header.byteOrder)
+		if err != nil {
+			return nil, fmt.Errorf("%w: block %d: %s", errGeoTIFFData, i, err)
+		}
+		if n := int(l.tileWidth) * int(l.tileLength); len(tileData) < n {
+			tileData = append(tileData, make([]float32, n-len(tileData))...)
+		}
 		data = append(data, tileData)
 	}
 	return data, nil
@@ -374,6 +611,10 @@ type GeoTIFF struct {
 
 // AtCoord returns the value closest to the requested latitude and longitude value
 //
+// The coordinates are in the coordinate reference system of the image, which
+// for projected images is the easting and northing. Use AtLonLat to query a
+// projected image with WGS 84 coordinates.
+//
 // If the value does not exist (i.e., the location requested) falls in between
 // multiple grid points.
 //
@@ -549,6 +790,9 @@ func (g *GeoTIFF) Bounds() (*CornerCoordinates, error) {
 }
 
 // Point contains X, Y longitude and latitude points
+//
+// For projected coordinate reference systems Lon holds the easting and Lat
+// the northing.
 type Point struct {
 	Lon float64
 	Lat float64
@@ -613,26 +857,10 @@ func Read(r io.ReadSeeker) (*GeoTIFF, error) {
 		return nil, err
 	}
 
-	fields := [...]Tag{ImageLength, ImageWidth, TileWidth, TileLength}
-	shortData := make(map[Tag][]uint16, 0)
-	for _, f := range fields {
-		v, ok := gTags[f]
-		if !ok {
-			return nil, fmt.Errorf("%w, could not retrieve %s", errGeoTIFFData, f)
-		}
-		ftype, elem := v.value()
-		switch ftype {
-		case SHORT:
-			shortData[f] = elem[0].([]uint16)
-		default:
-			return nil, fmt.Errorf("%w, incorrect data type for %s - %s", errGeoTIFFData, f, ftype)
-
-		}
+	l, err := readLayout(gTags)
+	if err != nil {
+		return nil, err
 	}
-	imageWidth := shortData[ImageWidth][0]
-	imageLength := shortData[ImageLength][0]
-	tileWidth := shortData[TileWidth][0]
-	tileLength := shortData[TileLength][0]
 
 	pixelScale := gTags[ModelPixelScale]
 	pixelScaleLen := 3
@@ -651,10 +879,10 @@ func Read(r io.ReadSeeker) (*GeoTIFF, error) {
 	return &GeoTIFF{
 		tags:        gTags,
 		data:        gData,
-		imageWidth:  imageWidth,
-		imageLength: imageLength,
-		tileWidth:   tileWidth,
-		tileLength:  tileLength,
+		imageWidth:  l.imageWidth,
+		imageLength: l.imageLength,
+		tileWidth:   l.tileWidth,
+		tileLength:  l.tileLength,
 		PixelScaleX: pixelScaleValues[0],
 		PixelScaleY: pixelScaleValues[1],
 	}, nil
@@ -670,16 +898,16 @@ func New(data [][]float32, iWidth uint16, iLength uint16, tWidth uint16, tLength
 		return nil, errors.New("pixel scale tags should be > 0")
 	}
 
-	tilesAcross := (iWidth + tWidth - 1) / tWidth
-	tilesDown := (iLength + tLength - 1) / tLength
+	tilesAcross := (int(iWidth) + int(tWidth) - 1) / int(tWidth)
+	tilesDown := (int(iLength) + int(tLength) - 1) / int(tLength)
 	tilesPerImage := tilesAcross * tilesDown
 
-	if int(tilesPerImage) != len(data) {
+	if tilesPerImage != len(data) {
 		return nil, errors.New("invalid number of tiles for data")
 	}
 
 	for _, d := range data {
-		if len(d) != int(tWidth*tLength) {
+		if len(d) != int(tWidth)*int(tLength) {
 			return nil, errors.New("invalid amount of tile data passed")
 		}
 	}
@@ -696,54 +924,76 @@ func New(data [][]float32, iWidth uint16, iLength uint16, tWidth uint16, tLength
 	return g, nil
 }
 
-// Contains the geotiff statistics
-type GeoTIFFStats struct {
-	Min    float32 // Min value in the image
-	Max    float32 // Max value in the image
-	Mean   float32 // Mean value in the image
-	StdDev float32 // Standard deviation of the image
-}
-
-func (gs GeoTIFFStats) String() string {
-	// Only print to 3 decimal places
-	return fmt.Sprintf("Minimum=%.3f, Maximum=%.3f, Mean=%.3f, StdDev=%.3f", gs.Min, gs.Max, gs.Mean, gs.StdDev)
-}
-
-// Stats returns the statistics of the geotiff
-// including the min, max, mean and standard deviation.
-func (g *GeoTIFF) Stats() GeoTIFFStats {
-
-	var minVal float32 = math.MaxFloat32
-	var maxVal float32 = math.SmallestNonzeroFloat32
-	var sum float32
-	var mean float32
-	var sumQ float32
-	var stdDev float32
-	var nonzero float32 = 0
-
-	for i := 0; i < len(g.data); i++ {
-		for j := 0; j < len(g.data[i]); j++ {
-			d := g.data[i][j]
-			if d != 0 {
-				nonzero++
-				sum += d
-				sumQ += d * d
-				if d < minVal {
-					minVal = d
-				}
-				if d > maxVal {
-					maxVal = d
-				}
+// raster returns the image values as a single row major array of
+// imageWidth * imageLength values, without the tile padding
+func (g *GeoTIFF) raster() []float32 {
+	width, length := int(g.imageWidth), int(g.imageLength)
+	tWidth, tLength := int(g.tileWidth), int(g.tileLength)
+	tilesAcross := (width + tWidth - 1) / tWidth
+	out := make([]float32, width*length)
+	for i, d := range g.data {
+		x0 := (i % tilesAcross) * tWidth
+		y0 := (i / tilesAcross) * tLength
+		w := tWidth
+		if x0+w > width {
+			w = width - x0
+		}
+		for j := 0; j < tLength && y0+j < length; j++ {
+			copy(out[(y0+j)*width+x0:(y0+j)*width+x0+w], d[j*tWidth:j*tWidth+w])
+		}
+	}
+	return out
+}
+
+// tile splits a row major raster into tiles of tWidth * tLength values,
+// padding the tiles on the right and bottom edges with zeros
+func tile(raster []float32, width int, length int, tWidth int, tLength int) [][]float32 {
+	tilesAcross := (width + tWidth - 1) / tWidth
+	tilesDown := (length + tLength - 1) / tLength
+	data := make([][]float32, 0, tilesAcross*tilesDown)
+	for ty := 0; ty < tilesDown; ty++ {
+		for tx := 0; tx < tilesAcross; tx++ {
+			d := make([]float32, tWidth*tLength)
+			x0, y0 := tx*tWidth, ty*tLength
+			w := tWidth
+			if x0+w > width {
+				w = width - x0
+			}
+			for j := 0; j < tLength && y0+j < length; j++ {
+				copy(d[j*tWidth:j*tWidth+w], raster[(y0+j)*width+x0:(y0+j)*width+x0+w])
 			}
+			data = append(data, d)
 		}
 	}
-	mean = sum / nonzero
-	stdDev = float32(math.Sqrt(float64(sumQ/nonzero - mean*mean)))
+	return data
+}
+
+// derive returns a new image holding a row major raster, with the tags of
+// the image other than those describing its layout, the upper left corner at
+// upperLeft and a new pixel size
+func (g *GeoTIFF) derive(raster []float32, width int, length int, upperLeft Point, pixelScaleX float64, pixelScaleY float64) *GeoTIFF {
+	tags := make(Tags, len(g.tags))
+	for k, v := range g.tags {
+		tags[k] = v
+	}
+	for _, k := range structuralTags {
+		delete(tags, k)
+	}
+	tags[ModelTiepoint] = doubleTag(0, 0, 0, upperLeft.Lon, upperLeft.Lat, 0)
+	tags[ModelPixelScale] = doubleTag(pixelScaleX, pixelScaleY, 0)
 
-	return GeoTIFFStats{
-		Min:    minVal,
-		Max:    maxVal,
-		Mean:   mean,
-		StdDev: stdDev,
+	tWidth, tLength := int(g.tileWidth), int(g.tileLength)
+	if tWidth%16 != 0 || tLength%16 != 0 {
+		tWidth, tLength = defaultTileSize, defaultTileSize
+	}
+	return &GeoTIFF{
+		tags:        tags,
+		data:        tile(raster, width, length, tWidth, tLength),
+		imageWidth:  uint16(width),
+		imageLength: uint16(length),
+		tileWidth:   uint16(tWidth),
+		tileLength:  uint16(tLength),
+		PixelScaleX: pixelScaleX,
+		PixelScaleY: pixelScaleY,
 	}
 }
@@ -0,0 +1,381 @@
+package geotiff
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"sort"
+)
+
+var errRender = errors.New("unable to render GeoTIFF")
+
+// Palette is a named colour ramp, running from low to high values
+type Palette int
+
+const (
+	Greys   Palette = iota // black to white
+	Viridis                // perceptually uniform purple, blue, green and yellow
+	Inferno                // perceptually uniform black, purple, orange and yellow
+	Terrain                // blue, green, yellow, brown and white, for elevation
+)
+
+var paletteToLabel = map[Palette]string{
+	Greys:   "greys",
+	Viridis: "viridis",
+	Inferno: "inferno",
+	Terrain: "terrain",
+}
+
+func (p Palette) String() string {
+	v, ok := paletteToLabel[p]
+	if !ok {
+		return fmt.Sprintf("unrecognized palette %d", int(p))
+	}
+	return v
+}
+
+// paletteStops are the colours of each palette at positions from 0 to 1
+var paletteStops = map[Palette][]ColorStop{
+	Greys: {
+		{0, color.NRGBA{0, 0, 0, 255}},
+		{1, color.NRGBA{255, 255, 255, 255}},
+	},
+	Viridis: {
+		{0, color.NRGBA{0x44, 0x01, 0x54, 255}},
+		{0.25, color.NRGBA{0x3b, 0x52, 0x8b, 255}},
+		{0.5, color.NRGBA{0x21, 0x91, 0x8c, 255}},
+		{0.75, color.NRGBA{0x5e, 0xc9, 0x62, 255}},
+		{1, color.NRGBA{0xfd, 0xe7, 0x25, 255}},
+	},
+	Inferno: {
+		{0, color.NRGBA{0x00, 0x00, 0x04, 255}},
+		{0.2, color.NRGBA{0x42, 0x0a, 0x68, 255}},
+		{0.4, color.NRGBA{0x93, 0x26, 0x67, 255}},
+		{0.6, color.NRGBA{0xdd, 0x51, 0x3a, 255}},
+		{0.8, color.NRGBA{0xfc, 0xa5, 0x0a, 255}},
+		{1, color.NRGBA{0xfc, 0xff, 0xa4, 255}},
+	},
+	Terrain: {
+		{0, color.NRGBA{0x33, 0x33, 0x99, 255}},
+		{0.15, color.NRGBA{0x00, 0x99, 0xff, 255}},
+		{0.25, color.NRGBA{0x00, 0xcc, 0x66, 255}},
+		{0.5, color.NRGBA{0xff, 0xff, 0x99, 255}},
+		{0.75, color.NRGBA{0x80, 0x5c, 0x54, 255}},
+		{1, color.NRGBA{0xff, 0xff, 0xff, 255}},
+	},
+}
+
+// Stretch selects how the values of an image are spread over a palette
+type Stretch int
+
+const (
+	// StretchLinear spreads the values between Min and Max evenly over the
+	// palette
+	StretchLinear Stretch = iota
+
+	// StretchPercentile spreads the values between two percentiles evenly
+	// over the palette, so a few outlying values do not wash out the rest
+	StretchPercentile
+
+	// StretchEqualize spreads the values so each colour of the palette
+	// covers about the same number of pixels, bringing out detail where
+	// values are crowded together. Values are placed by a histogram of 1024
+	// bins, so to within the values sharing a bin.
+	StretchEqualize
+)
+
+var stretchToLabel = map[Stretch]string{
+	StretchLinear:     "linear",
+	StretchPercentile: "percentile",
+	StretchEqualize:   "equalize",
+}
+
+func (s Stretch) String() string {
+	v, ok := stretchToLabel[s]
+	if !ok {
+		return fmt.Sprintf("unrecognized stretch %d", int(s))
+	}
+	return v
+}
+
+// ColorStop is the colour of a value in a colour ramp
+type ColorStop struct {
+	Value float64
+	Color color.NRGBA
+}
+
+// RenderOptions configures Render and WritePNG
+type RenderOptions struct {
+	// Palette is the colour ramp the stretched values are drawn with.
+	//
+	// Defaults to Greys
+	Palette Palette
+
+	// Stops are a colour ramp of values of the image, in increasing order,
+	// taking precedence over Palette and Stretch, in the manner of gdaldem
+	// color-relief. Values between stops are interpolated, and values beyond
+	// the first or last stop take its colour.
+	Stops []ColorStop
+
+	// Nearest draws values with the colour of the nearest stop rather than
+	// interpolating between stops
+	Nearest bool
+
+	// Stretch is how the values are spread over the palette.
+	//
+	// Defaults to StretchLinear
+	Stretch Stretch
+
+	// Min and Max are the range of StretchLinear.
+	//
+	// Default to the minimum and maximum valid values of the image
+	Min *float64
+	Max *float64
+
+	// LowPercentile and HighPercentile are the range of StretchPercentile.
+	//
+	// Default to 2 and 98
+	LowPercentile  *float64
+	HighPercentile *float64
+
+	// NoDataColor is the colour of nodata, NaN and infinite pixels.
+	//
+	// Defaults to transparent
+	NoDataColor color.NRGBA
+}
+
+// Render draws an image with a colour ramp
+//
+// The values of the image are stretched over the range of a palette, or
+// coloured directly by explicit stops. opts may be nil, in which case the
+// range of the values is drawn in shades of grey.
+func (g *GeoTIFF) Render(opts *RenderOptions) (*image.NRGBA, error) {
+	if opts == nil {
+		opts = &RenderOptions{}
+	}
+	colour, err := colourRamp(opts, imageSet{g})
+	if err != nil {
+		return nil, err
+	}
+	return paint(g.raster(), int(g.imageWidth), int(g.imageLength), g.noData(), colour, opts.NoDataColor), nil
+}
+
+// paint colours the pixels of a raster, with nodata, NaN and infinite pixels
+// in the nodata colour
+func paint(raster []float32, width int, length int, nd noData, colour func(v float64) color.NRGBA, noDataColor color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, length))
+	for k, v := range raster {
+		c := noDataColor
+		if !nd.is(v) && !math.IsInf(float64(v), 0) {
+			c = colour(float64(v))
+		}
+		img.SetNRGBA(k%width, k/width, c)
+	}
+	return img
+}
+
+// WritePNG renders an image with a colour ramp and encodes it as a PNG, see
+// Render
+func WritePNG(w io.Writer, g *GeoTIFF, opts *RenderOptions) error {
+	img, err := g.Render(opts)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(w, img); err != nil {
+		return fmt.Errorf("%w: %s", errRender, err)
+	}
+	return nil
+}
+
+// stretchBins is the number of histogram bins used to equalize values, and
+// to estimate percentiles across several images
+const stretchBins = 1024
+
+// imageSet is the images whose valid values a palette is stretched over
+type imageSet []*GeoTIFF
+
+// stats returns the statistics of the images together
+func (s imageSet) stats() GeoTIFFStats {
+	stats := emptyStats()
+	for _, g := range s {
+		stats.Merge(g.Stats())
+	}
+	return stats
+}
+
+// histogram returns the histogram of the images together, in equal width
+// bins over the range of their values
+func (s imageSet) histogram(stats GeoTIFFStats) (*Histogram, error) {
+	opts := &HistogramOptions{Bins: stretchBins, Min: &stats.Min, Max: &stats.Max}
+	if stats.Min == stats.Max {
+		lo, hi := stats.Min-0.5, stats.Max+0.5
+		opts.Min, opts.Max = &lo, &hi
+	}
+	edges, err := opts.equalWidthEdges(nil)
+	if err != nil {
+		return nil, err
+	}
+	var h *Histogram
+	for _, g := range s {
+		gh, err := g.Histogram(&HistogramOptions{Edges: edges})
+		if err != nil {
+			return nil, err
+		}
+		if h == nil {
+			h = gh
+			continue
+		}
+		for i, c := range gh.Counts {
+			h.Counts[i] += c
+		}
+		h.NoData += gh.NoData
+	}
+	return h, nil
+}
+
+// percentiles returns the p-th percentiles of the images together, exactly
+// for a single image and estimated from their histogram for several
+func (s imageSet) percentiles(stats GeoTIFFStats, p ...float64) ([]float64, error) {
+	if len(s) == 1 {
+		return s[0].Percentiles(p...)
+	}
+	h, err := s.histogram(stats)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]float64, len(p))
+	for i, v := range p {
+		if out[i], err = h.Percentile(v); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// colourRamp returns the colour of values, stretched over the valid values
+// of the images being drawn
+//
+// The linear stretch needs only the statistics of the images, the
+// percentile stretch their percentiles and the equalize stretch their
+// histogram.
+func colourRamp(opts *RenderOptions, images imageSet) (func(v float64) color.NRGBA, error) {
+	if opts.Stops != nil {
+		if len(opts.Stops) == 0 {
+			return nil, fmt.Errorf("%w: no colour stops", errRender)
+		}
+		for i := 1; i < len(opts.Stops); i++ {
+			if !(opts.Stops[i].Value > opts.Stops[i-1].Value) {
+				return nil, fmt.Errorf("%w: colour stops are not increasing at %d", errRender, i)
+			}
+		}
+		return func(v float64) color.NRGBA { return rampColour(opts.Stops, v, opts.Nearest) }, nil
+	}
+
+	stops, ok := paletteStops[opts.Palette]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errRender, opts.Palette)
+	}
+	palette := func(t float64) color.NRGBA { return rampColour(stops, t, opts.Nearest) }
+	if _, ok := stretchToLabel[opts.Stretch]; !ok {
+		return nil, fmt.Errorf("%w: %s", errRender, opts.Stretch)
+	}
+
+	// An image without valid values is drawn entirely as nodata, so needs
+	// no stretch
+	stats := images.stats()
+	if stats.Count == 0 {
+		return palette, nil
+	}
+
+	var lo, hi float64
+	switch opts.Stretch {
+	case StretchLinear:
+		lo, hi = stats.Min, stats.Max
+		if opts.Min != nil {
+			lo = *opts.Min
+		}
+		if opts.Max != nil {
+			hi = *opts.Max
+		}
+	case StretchPercentile:
+		low, high := 2.0, 98.0
+		if opts.LowPercentile != nil {
+			low = *opts.LowPercentile
+		}
+		if opts.HighPercentile != nil {
+			high = *opts.HighPercentile
+		}
+		if !(low >= 0 && low < high && high <= 100) {
+			return nil, fmt.Errorf("%w: percentiles %v to %v are not increasing from 0 to 100", errRender, low, high)
+		}
+		p, err := images.percentiles(stats, low, high)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errRender, err)
+		}
+		lo, hi = p[0], p[1]
+	case StretchEqualize:
+		if stats.Min == stats.Max {
+			return func(v float64) color.NRGBA { return palette(0.5) }, nil
+		}
+		h, err := images.histogram(stats)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errRender, err)
+		}
+		// Each value is placed by the fraction of the values below it, with
+		// the values of each bin spread evenly across it
+		below := make([]int, len(h.Counts)+1)
+		for i, c := range h.Counts {
+			below[i+1] = below[i] + c
+		}
+		total := float64(below[len(h.Counts)])
+		return func(v float64) color.NRGBA {
+			i := sort.Search(len(h.Edges), func(i int) bool { return h.Edges[i] > v }) - 1
+			i = clamp(i, 0, len(h.Counts)-1)
+			f := math.Max(0, math.Min(1, (v-h.Edges[i])/(h.Edges[i+1]-h.Edges[i])))
+			return palette((float64(below[i]) + f*float64(h.Counts[i])) / total)
+		}, nil
+	}
+
+	if !(lo <= hi) {
+		return nil, fmt.Errorf("%w: minimum %v is above maximum %v", errRender, lo, hi)
+	}
+	return func(v float64) color.NRGBA {
+		if hi == lo {
+			return palette(0.5)
+		}
+		return palette((v - lo) / (hi - lo))
+	}, nil
+}
+
+// rampColour returns the colour of v in a ramp of increasing stops,
+// interpolating between the stops either side of it unless nearest is set
+func rampColour(stops []ColorStop, v float64, nearest bool) color.NRGBA {
+	i := sort.Search(len(stops), func(i int) bool { return stops[i].Value > v })
+	switch {
+	case i == 0:
+		return stops[0].Color
+	case i == len(stops):
+		return stops[len(stops)-1].Color
+	}
+	a, b := stops[i-1], stops[i]
+	t := (v - a.Value) / (b.Value - a.Value)
+	if nearest {
+		if t < 0.5 {
+			return a.Color
+		}
+		return b.Color
+	}
+	mix := func(x uint8, y uint8) uint8 {
+		return uint8(math.Round(float64(x) + t*(float64(y)-float64(x))))
+	}
+	return color.NRGBA{
+		R: mix(a.Color.R, b.Color.R),
+		G: mix(a.Color.G, b.Color.G),
+		B: mix(a.Color.B, b.Color.B),
+		A: mix(a.Color.A, b.Color.A),
+	}
+}
@@ -0,0 +1,148 @@
+package geotiff
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"math"
+	"testing"
+)
+
+func Test_Render_Happy(t *testing.T) {
+	black, white := color.NRGBA{0, 0, 0, 255}, color.NRGBA{255, 255, 255, 255}
+	red, blue := color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}
+	grey := func(v uint8) color.NRGBA { return color.NRGBA{v, v, v, 255} }
+	zero, nine := 0.0, 9.0
+	ten, ninety := 10.0, 90.0
+
+	// rampGeoTIFF holds x + 10 * y, from 0 to 99
+	tests := []struct {
+		name string
+		opts *RenderOptions
+		want map[[2]int]color.NRGBA
+	}{
+		{"defaults", nil, map[[2]int]color.NRGBA{{0, 0}: black, {9, 9}: white, {3, 0}: grey(8)}},
+		{"range", &RenderOptions{Min: &zero, Max: &nine}, map[[2]int]color.NRGBA{{9, 0}: white, {0, 1}: white, {3, 0}: grey(85)}},
+		{"percentile", &RenderOptions{Stretch: StretchPercentile, LowPercentile: &ten, HighPercentile: &ninety}, map[[2]int]color.NRGBA{
+			{9, 0}: black, {5, 9}: white, {9, 4}: grey(126),
+		}},
+		{"palette", &RenderOptions{Palette: Viridis}, map[[2]int]color.NRGBA{
+			{0, 0}: {0x44, 0x01, 0x54, 255}, {9, 9}: {0xfd, 0xe7, 0x25, 255},
+		}},
+		{"stops", &RenderOptions{Stops: []ColorStop{{10, red}, {60, blue}}}, map[[2]int]color.NRGBA{
+			{0, 0}: red, {5, 3}: {128, 0, 128, 255}, {9, 9}: blue,
+		}},
+		{"nearest stops", &RenderOptions{Stops: []ColorStop{{10, red}, {60, blue}}, Nearest: true}, map[[2]int]color.NRGBA{
+			{4, 3}: red, {5, 3}: blue,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := rampGeoTIFF(t, 10, 10).Render(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b := img.Bounds(); b.Dx() != 10 || b.Dy() != 10 {
+				t.Fatalf("got bounds %v want 10x10", b)
+			}
+			for p, want := range tt.want {
+				if got := img.NRGBAAt(p[0], p[1]); got != want {
+					t.Errorf("pixel %d, %d: got %v want %v", p[0], p[1], got, want)
+				}
+			}
+		})
+	}
+
+	t.Run("equalize", func(t *testing.T) {
+		// Most values are crowded at the low end, but are drawn evenly
+		// across the greys, by the fraction of the values below them to
+		// within the share of a single value
+		g := demGeoTIFF(t, 4326, 135, -20, 0.1, 10, 10, func(i, j int) float32 { return float32(math.Pow(float64(i+10*j), 3)) })
+		img, err := g.Render(&RenderOptions{Stretch: StretchEqualize})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range []int{10, 50, 90} {
+			got := float64(img.NRGBAAt(k%10, k/10).R)
+			if want := 255 * float64(k) / 100; math.Abs(got-want) > 255.0/100 {
+				t.Errorf("pixel %d: got %v want %v", k, got, want)
+			}
+		}
+	})
+
+	t.Run("nodata", func(t *testing.T) {
+		g := rampGeoTIFF(t, 10, 10)
+		g.SetNoData(99)
+		img, err := g.Render(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := img.NRGBAAt(9, 9); got != (color.NRGBA{}) {
+			t.Errorf("got %v want transparent", got)
+		}
+		// The nodata value is not part of the range
+		if got := img.NRGBAAt(8, 9); got != white {
+			t.Errorf("got %v want white", got)
+		}
+		img, err = g.Render(&RenderOptions{NoDataColor: red})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := img.NRGBAAt(9, 9); got != red {
+			t.Errorf("got %v want red", got)
+		}
+
+		empty := constGeoTIFF(t, 135, -20, 0.1, 2, 2, 1)
+		empty.SetNoData(1)
+		for _, stretch := range []Stretch{StretchLinear, StretchPercentile, StretchEqualize} {
+			img, err := empty.Render(&RenderOptions{Stretch: stretch})
+			if err != nil {
+				t.Fatalf("%s: %s", stretch, err)
+			}
+			if got := img.NRGBAAt(1, 1); got != (color.NRGBA{}) {
+				t.Errorf("%s: got %v want transparent", stretch, got)
+			}
+		}
+	})
+}
+
+func Test_WritePNG_Happy(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WritePNG(&buf, rampGeoTIFF(t, 10, 5), &RenderOptions{Palette: Terrain}); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 10 || b.Dy() != 5 {
+		t.Errorf("got bounds %v want 10x5", b)
+	}
+	if got := color.NRGBAModel.Convert(img.At(0, 0)); got != (color.NRGBA{0x33, 0x33, 0x99, 255}) {
+		t.Errorf("got %v want the lowest terrain colour", got)
+	}
+}
+
+func Test_Render_Sad(t *testing.T) {
+	g := rampGeoTIFF(t, 10, 10)
+	low, high, beyond, nine, zero := 50.0, 10.0, 200.0, 9.0, 0.0
+	tests := []struct {
+		name string
+		opts *RenderOptions
+	}{
+		{"palette", &RenderOptions{Palette: Palette(9)}},
+		{"stretch", &RenderOptions{Stretch: Stretch(9)}},
+		{"no stops", &RenderOptions{Stops: []ColorStop{}}},
+		{"decreasing stops", &RenderOptions{Stops: []ColorStop{{1, color.NRGBA{}}, {0, color.NRGBA{}}}}},
+		{"percentiles", &RenderOptions{Stretch: StretchPercentile, LowPercentile: &low, HighPercentile: &high}},
+		{"percentile range", &RenderOptions{Stretch: StretchPercentile, HighPercentile: &beyond}},
+		{"range", &RenderOptions{Min: &nine, Max: &zero}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := g.Render(tt.opts); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
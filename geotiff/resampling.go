@@ -0,0 +1,315 @@
+package geotiff
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Resampling selects how pixel values are combined when an image is
+// sampled onto a different pixel grid
+type Resampling int
+
+const (
+	Nearest  Resampling = iota // value of the pixel containing the sample
+	Average                    // mean of the valid pixels covered, weighted by the area covered
+	Mode                       // most frequent of the valid pixels covered
+	Min                        // minimum of the valid pixels covered
+	Max                        // maximum of the valid pixels covered
+	Bilinear                   // bilinear interpolation of the 2x2 nearest pixels
+	Cubic                      // cubic convolution of the 4x4 nearest pixels
+	Lanczos                    // Lanczos windowed sinc of the 6x6 nearest pixels
+	Median                     // median of the valid pixels covered
+	Sum                        // sum of the valid pixels covered, weighted by the area covered
+)
+
+var resamplingToLabel = map[Resampling]string{
+	Nearest:  "nearest",
+	Average:  "average",
+	Mode:     "mode",
+	Min:      "min",
+	Max:      "max",
+	Bilinear: "bilinear",
+	Cubic:    "cubic",
+	Lanczos:  "lanczos",
+	Median:   "median",
+	Sum:      "sum",
+}
+
+func (r Resampling) String() string {
+	v, ok := resamplingToLabel[r]
+	if !ok {
+		return fmt.Sprintf("unrecognized resampling %d", int(r))
+	}
+	return v
+}
+
+// interpolates reports if the method samples the image at a point, rather
+// than combining all the pixels covered
+func (r Resampling) interpolates() bool {
+	return r == Nearest || r == Bilinear || r == Cubic || r == Lanczos
+}
+
+// resample resizes a row major raster of width * length values to
+// outWidth * outLength values, where each output pixel covers rx * ry input
+// pixels starting from the upper left corner of the image.
+//
+// Interpolating methods sample the input at the centre of each output pixel,
+// with the kernel stretched to cover the input pixels when reducing the
+// image. Aggregating methods combine the input pixels each output pixel
+// covers.
+//
+// Pixels which are nodata are ignored, and output pixels without any valid
+// input pixels, or which lie beyond the input image, are set to nodata.
+func resample(raster []float32, width int, length int, outWidth int, outLength int, rx float64, ry float64, method Resampling, nd noData) ([]float32, error) {
+	if _, ok := resamplingToLabel[method]; !ok {
+		return nil, fmt.Errorf("%s", method)
+	}
+	out := make([]float32, outWidth*outLength)
+	if method.interpolates() {
+		sx, sy := math.Max(rx, 1), math.Max(ry, 1)
+		for j := 0; j < outLength; j++ {
+			y := (float64(j) + 0.5) * ry
+			for i := 0; i < outWidth; i++ {
+				x := (float64(i) + 0.5) * rx
+				out[j*outWidth+i] = sample(raster, width, length, x, y, sx, sy, method, nd)
+			}
+		}
+		return out, nil
+	}
+
+	n := (int(math.Ceil(rx)) + 1) * (int(math.Ceil(ry)) + 1)
+	values := make([]float32, 0, n)
+	weights := make([]float64, 0, n)
+	for j := 0; j < outLength; j++ {
+		y0, y1 := float64(j)*ry, math.Min(float64(j+1)*ry, float64(length))
+		for i := 0; i < outWidth; i++ {
+			x0, x1 := float64(i)*rx, math.Min(float64(i+1)*rx, float64(width))
+			values, weights = values[:0], weights[:0]
+			for y := int(y0); float64(y) < y1; y++ {
+				wy := math.Min(float64(y+1), y1) - math.Max(float64(y), y0)
+				for x := int(x0); float64(x) < x1; x++ {
+					wx := math.Min(float64(x+1), x1) - math.Max(float64(x), x0)
+					if v := raster[y*width+x]; !nd.is(v) && wx*wy > 1e-9 {
+						values = append(values, v)
+						weights = append(weights, wx*wy)
+					}
+				}
+			}
+			if len(values) == 0 {
+				out[j*outWidth+i] = nd.fill()
+				continue
+			}
+			out[j*outWidth+i] = combine(values, weights, method)
+		}
+	}
+	return out, nil
+}
+
+// combine reduces a non empty set of values, each covering a fraction of
+// the output pixel given by its weight, with an aggregating method
+func combine(values []float32, weights []float64, method Resampling) float32 {
+	switch method {
+	case Average, Sum:
+		var sum, total float64
+		for i, v := range values {
+			sum += weights[i] * float64(v)
+			total += weights[i]
+		}
+		if method == Sum {
+			return float32(sum)
+		}
+		return float32(sum / total)
+	case Min:
+		m := values[0]
+		for _, v := range values[1:] {
+			if v < m {
+				m = v
+			}
+		}
+		return m
+	case Max:
+		m := values[0]
+		for _, v := range values[1:] {
+			if v > m {
+				m = v
+			}
+		}
+		return m
+	case Median:
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+		mid := len(values) / 2
+		if len(values)%2 == 0 {
+			return float32((float64(values[mid-1]) + float64(values[mid])) / 2)
+		}
+		return values[mid]
+	}
+
+	// Mode, where each value counts by the area it covers. Ties are broken
+	// by the smallest value so the result does not depend on the order of
+	// the values
+	counts := make(map[float32]float64, len(values))
+	for i, v := range values {
+		counts[v] += weights[i]
+	}
+	var mode float32
+	best := -1.0
+	for v, c := range counts {
+		if c > best+1e-9 || (math.Abs(c-best) <= 1e-9 && v < mode) {
+			mode, best = v, c
+		}
+	}
+	return mode
+}
+
+// kernel is a separable interpolation kernel, which is zero beyond radius
+type kernel struct {
+	radius float64
+	weight func(d float64) float64
+}
+
+// kernel returns the interpolation kernel of the method
+func (r Resampling) kernel() kernel {
+	switch r {
+	case Cubic:
+		return kernel{radius: 2, weight: cubic}
+	case Lanczos:
+		return kernel{radius: 3, weight: lanczos}
+	}
+	return kernel{radius: 1, weight: func(d float64) float64 { return 1 - math.Abs(d) }}
+}
+
+// cubic is the cubic convolution kernel with a = -0.5
+//
+// See R. Keys (1981), Cubic convolution interpolation for digital image
+// processing, https://doi.org/10.1109/TASSP.1981.1163711
+func cubic(d float64) float64 {
+	const a = -0.5
+	d = math.Abs(d)
+	switch {
+	case d <= 1:
+		return (a+2)*d*d*d - (a+3)*d*d + 1
+	case d < 2:
+		return a*d*d*d - 5*a*d*d + 8*a*d - 4*a
+	}
+	return 0
+}
+
+// lanczos is the Lanczos kernel with a = 3, a sinc function windowed by a
+// wider sinc function
+func lanczos(d float64) float64 {
+	const a = 3
+	if d == 0 {
+		return 1
+	}
+	if math.Abs(d) >= a {
+		return 0
+	}
+	pd := math.Pi * d
+	return a * math.Sin(pd) * math.Sin(pd/a) / (pd * pd)
+}
+
+// sample returns the value of a row major raster at a point, where x and y
+// are in pixels from the upper left corner of the image, so the centre of
+// the first pixel is at 0.5, 0.5.
+//
+// The interpolation kernel is stretched by sx and sy, which are greater than
+// one when the image is being reduced, so that every pixel covered
+// contributes to the result.
+//
+// Points outside the image are nodata. Interpolation ignores neighbouring
+// pixels which are nodata, weighting the remaining pixels to compensate, and
+// falls back to the nearest pixel if none of them are valid.
+func sample(raster []float32, width int, length int, x float64, y float64, sx float64, sy float64, method Resampling, nd noData) float32 {
+	if !(x >= 0 && x < float64(width) && y >= 0 && y < float64(length)) {
+		return nd.fill()
+	}
+	nearest := raster[int(y)*width+int(x)]
+	if method == Nearest || nd.is(nearest) {
+		return nearest
+	}
+
+	k := method.kernel()
+	x0, wx := kernelWeights(k, x-0.5, sx)
+	y0, wy := kernelWeights(k, y-0.5, sy)
+	var sum, weights float64
+	for j, wj := range wy {
+		// Pixels beyond the edges take the value of the edge pixel
+		yy := clamp(y0+j, 0, length-1)
+		for i, wi := range wx {
+			if wi*wj == 0 {
+				continue
+			}
+			v := raster[yy*width+clamp(x0+i, 0, width-1)]
+			if nd.is(v) {
+				continue
+			}
+			sum += wi * wj * float64(v)
+			weights += wi * wj
+		}
+	}
+	if math.Abs(weights) < 1e-6 {
+		return nearest
+	}
+	return float32(sum / weights)
+}
+
+// kernelWeights returns the first pixel within reach of a kernel centred at
+// c, in pixel centre coordinates, and the weights of it and the following
+// pixels
+func kernelWeights(k kernel, c float64, s float64) (int, []float64) {
+	first := int(math.Ceil(c - k.radius*s))
+	last := int(math.Floor(c + k.radius*s))
+	weights := make([]float64, 0, last-first+1)
+	for p := first; p <= last; p++ {
+		weights = append(weights, k.weight((float64(p)-c)/s))
+	}
+	return first, weights
+}
+
+// clamp limits v to the range [lo, hi]
+func clamp(v int, lo int, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+var errResample = errors.New("unable to resample GeoTIFF")
+
+// Resample returns the image with a new pixel size, keeping its upper left
+// corner in place
+//
+// The image is enlarged or reduced to cover the same extent, rounded out to
+// a whole number of pixels. Interpolating methods (Nearest, Bilinear, Cubic
+// and Lanczos) suit continuous data and aggregating methods (Average, Min,
+// Max, Mode, Median and Sum) suit reducing the image, for example Mode for
+// categorical data and Average for elevation.
+func (g *GeoTIFF) Resample(pixelScaleX float64, pixelScaleY float64, method Resampling) (*GeoTIFF, error) {
+	if !(pixelScaleX > 0 && pixelScaleY > 0) {
+		return nil, fmt.Errorf("%w: pixel scale %v, %v must be greater than zero", errResample, pixelScaleX, pixelScaleY)
+	}
+	bounds, err := g.Bounds()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errResample, err)
+	}
+	rx, ry := pixelScaleX/g.PixelScaleX, pixelScaleY/g.PixelScaleY
+
+	// The small tolerance keeps extents which are a whole number of pixels
+	// from gaining a pixel to rounding error
+	width := int(math.Ceil(float64(g.imageWidth)/rx - 1e-6))
+	length := int(math.Ceil(float64(g.imageLength)/ry - 1e-6))
+	if width < 1 || length < 1 || width > math.MaxUint16 || length > math.MaxUint16 {
+		return nil, fmt.Errorf("%w: size %dx%d is outside 1 to %d pixels", errResample, width, length, math.MaxUint16)
+	}
+
+	out, err := resample(g.raster(), int(g.imageWidth), int(g.imageLength), width, length, rx, ry, method, g.noData())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errResample, err)
+	}
+	return g.derive(out, width, length, bounds.UpperLeft, pixelScaleX, pixelScaleY), nil
+}
@@ -0,0 +1,157 @@
+package geotiff
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func Test_Resample_Happy(t *testing.T) {
+	// rampGeoTIFF holds x + 10 * y in 0.1 degree pixels
+	g := rampGeoTIFF(t, 10, 10)
+
+	tests := []struct {
+		name   string
+		scale  float64
+		method Resampling
+		size   uint16
+		x, y   int
+		want   float64
+	}{
+		{"average", 0.2, Average, 5, 0, 0, 5.5},
+		{"sum", 0.2, Sum, 5, 0, 0, 22},
+		{"min", 0.2, Min, 5, 1, 1, 22},
+		{"max", 0.2, Max, 5, 1, 1, 33},
+		{"median", 0.2, Median, 5, 1, 1, 27.5},
+		{"mode", 0.2, Mode, 5, 0, 0, 0},
+		// Pixels covering part of a source pixel are weighted by the area covered
+		{"area weighted average", 0.15, Average, 7, 0, 0, 11.0 / 3},
+		{"area weighted sum", 0.15, Sum, 7, 0, 0, 0.5*1 + 0.5*10 + 0.25*11},
+		// The last pixel extends beyond the image and only covers its last column
+		{"partial pixel", 0.3, Average, 4, 3, 0, 9 + 10},
+		{"nearest up", 0.05, Nearest, 20, 3, 5, 21},
+		{"nearest down", 0.2, Nearest, 5, 2, 1, 35},
+		{"bilinear down", 0.2, Bilinear, 5, 2, 2, 49.5},
+		{"cubic down", 0.2, Cubic, 5, 2, 2, 49.5},
+		{"lanczos down", 0.2, Lanczos, 5, 2, 2, 49.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := g.Resample(tt.scale, tt.scale, tt.method)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.imageWidth != tt.size || got.imageLength != tt.size {
+				t.Fatalf("got size %dx%d want %dx%d", got.imageWidth, got.imageLength, tt.size, tt.size)
+			}
+			if got.PixelScaleX != tt.scale || got.PixelScaleY != tt.scale {
+				t.Errorf("got pixel scale %v, %v want %v", got.PixelScaleX, got.PixelScaleY, tt.scale)
+			}
+			v, _ := got.loc(tt.x, tt.y)
+			if !checkToTolerance(float64(v), tt.want, 1e-4) {
+				t.Errorf("pixel %d, %d: got %v want %v", tt.x, tt.y, v, tt.want)
+			}
+		})
+	}
+
+	t.Run("mode of categories", func(t *testing.T) {
+		// Each 3x3 block holds five 2s in a checkerboard, two 1s and two 3s
+		c := demGeoTIFF(t, 4326, 135, -20, 1, 9, 9, func(i, j int) float32 {
+			x, y := i%3, j%3
+			switch {
+			case (x+y)%2 == 0:
+				return 2
+			case x == 0 || y == 0:
+				return 1
+			default:
+				return 3
+			}
+		})
+		got, err := c.Resample(3, 3, Mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range got.raster() {
+			if v != 2 {
+				t.Errorf("got %v want 2", v)
+			}
+		}
+	})
+
+	t.Run("nodata", func(t *testing.T) {
+		g := rampGeoTIFF(t, 10, 10)
+		g.SetNoData(0)
+		for _, method := range []Resampling{Average, Bilinear, Lanczos} {
+			got, err := g.Resample(0.2, 0.2, method)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The upper left block is {0, 1, 10, 11} with 0 as nodata
+			v, _ := got.loc(0, 0)
+			if v < 1 || v > 11 {
+				t.Errorf("%s: got %v, expected nodata to be ignored", method, v)
+			}
+		}
+
+		g.SetNoData(55)
+		raster := g.raster()
+		for _, i := range []int{44, 45, 54} {
+			raster[i] = 55
+		}
+		g.data = tile(raster, 10, 10, 16, 16)
+		got, err := g.Resample(0.2, 0.2, Max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, _ := got.loc(2, 2); v != 55 {
+			t.Errorf("got %v want nodata 55", v)
+		}
+	})
+
+	t.Run("georeference", func(t *testing.T) {
+		got, err := g.Resample(0.3, 0.25, Average)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := Write(&buf, got, nil); err != nil {
+			t.Fatal(err)
+		}
+		back, err := Read(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		bounds, _ := back.Bounds()
+		want := Point{Lon: 135 + 4*0.3, Lat: -20 - 4*0.25}
+		if !bounds.UpperLeft.Equals(Point{Lon: 135, Lat: -20}) ||
+			!checkToTolerance(bounds.LowerRight.Lon, want.Lon, 1e-9) ||
+			!checkToTolerance(bounds.LowerRight.Lat, want.Lat, 1e-9) {
+			t.Errorf("got bounds\n%s", bounds)
+		}
+		if scale, _ := back.Tags().Float64s(ModelPixelScale); scale[0] != 0.3 || scale[1] != 0.25 {
+			t.Errorf("got %s %v", ModelPixelScale, scale)
+		}
+	})
+}
+
+func Test_Resample_Sad(t *testing.T) {
+	g := rampGeoTIFF(t, 10, 10)
+	tests := []struct {
+		name   string
+		scaleX float64
+		scaleY float64
+		method Resampling
+	}{
+		{"zero scale", 0, 0.1, Nearest},
+		{"NaN scale", math.NaN(), 0.1, Nearest},
+		{"too large", 1e-6, 1e-6, Nearest},
+		{"unknown method", 0.2, 0.2, Resampling(99)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := g.Resample(tt.scaleX, tt.scaleY, tt.method); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
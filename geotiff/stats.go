@@ -0,0 +1,124 @@
+package geotiff
+
+import (
+	"fmt"
+	"math"
+)
+
+// Contains the geotiff statistics
+//
+// Statistics are accumulated in a single pass with float64 precision, using
+// Welford's algorithm for the variance, so they can be built up value by
+// value with Add or tile by tile with Merge. Min, Max, Mean and StdDev are
+// NaN if there are no valid values.
+type GeoTIFFStats struct {
+	Min    float64 // Min value in the image
+	Max    float64 // Max value in the image
+	Mean   float64 // Mean value in the image
+	StdDev float64 // Population standard deviation of the image
+	Count  int     // Number of valid values
+	NoData int     // Number of nodata, NaN or infinite values
+
+	// m2 is the sum of squared differences from the mean
+	m2 float64
+}
+
+func (gs GeoTIFFStats) String() string {
+	// Only print to 3 decimal places
+	return fmt.Sprintf("Minimum=%.3f, Maximum=%.3f, Mean=%.3f, StdDev=%.3f, Count=%d, NoData=%d",
+		gs.Min, gs.Max, gs.Mean, gs.StdDev, gs.Count, gs.NoData)
+}
+
+// emptyStats returns the statistics of no values
+func emptyStats() GeoTIFFStats {
+	nan := math.NaN()
+	return GeoTIFFStats{Min: nan, Max: nan, Mean: nan, StdDev: nan}
+}
+
+// Add includes a value in the statistics, counting NaN and infinite values
+// as nodata
+func (gs *GeoTIFFStats) Add(v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		gs.NoData++
+		return
+	}
+	if gs.Count == 0 {
+		gs.Min, gs.Max, gs.Mean, gs.m2 = v, v, 0, 0
+	}
+	gs.Count++
+	gs.Min, gs.Max = math.Min(gs.Min, v), math.Max(gs.Max, v)
+	delta := v - gs.Mean
+	gs.Mean += delta / float64(gs.Count)
+	gs.m2 += delta * (v - gs.Mean)
+	gs.StdDev = math.Sqrt(gs.m2 / float64(gs.Count))
+}
+
+// Merge combines the statistics of another set of values, such as another
+// tile or file, into the statistics
+//
+// See T. Chan, G. Golub and R. LeVeque (1979), Updating Formulae and a
+// Pairwise Algorithm for Computing Sample Variances
+func (gs *GeoTIFFStats) Merge(o GeoTIFFStats) {
+	noData := gs.NoData + o.NoData
+	switch {
+	case o.Count == 0:
+	case gs.Count == 0:
+		*gs = o
+	default:
+		n := gs.Count + o.Count
+		delta := o.Mean - gs.Mean
+		gs.Mean += delta * float64(o.Count) / float64(n)
+		gs.m2 += o.m2 + delta*delta*float64(gs.Count)*float64(o.Count)/float64(n)
+		gs.Min, gs.Max = math.Min(gs.Min, o.Min), math.Max(gs.Max, o.Max)
+		gs.Count = n
+		gs.StdDev = math.Sqrt(gs.m2 / float64(n))
+	}
+	if gs.Count == 0 {
+		*gs = emptyStats()
+	}
+	gs.NoData = noData
+}
+
+// TileStats returns the statistics of the pixels in tile i, excluding the
+// padding of tiles on the right and bottom edges and nodata values
+func (g *GeoTIFF) TileStats(i int) (GeoTIFFStats, error) {
+	if i < 0 || i >= len(g.data) {
+		return GeoTIFFStats{}, fmt.Errorf("tile %d is outside 0 to %d", i, len(g.data)-1)
+	}
+	width, length := int(g.imageWidth), int(g.imageLength)
+	tWidth, tLength := int(g.tileWidth), int(g.tileLength)
+	tilesAcross := (width + tWidth - 1) / tWidth
+	x0 := (i % tilesAcross) * tWidth
+	y0 := (i / tilesAcross) * tLength
+
+	nd := g.noData()
+	d := g.data[i]
+	s := emptyStats()
+	for y := 0; y < tLength && y0+y < length; y++ {
+		for x := 0; x < tWidth && x0+x < width; x++ {
+			v := d[y*tWidth+x]
+			if nd.is(v) {
+				s.NoData++
+				continue
+			}
+			s.Add(float64(v))
+		}
+	}
+	return s, nil
+}
+
+// Stats returns the statistics of the geotiff
+// including the min, max, mean and standard deviation of the valid pixels,
+// and the number of valid and nodata pixels.
+//
+// Only the GDALNoData value, NaN and infinite values are nodata. Zero is a
+// valid value, where earlier versions skipped every zero pixel, so images
+// with zero filled borders should set the nodata value with SetNoData(0).
+func (g *GeoTIFF) Stats() GeoTIFFStats {
+	s := emptyStats()
+	for i := range g.data {
+		t, _ := g.TileStats(i)
+		s.Merge(t)
+	}
+	return s
+}
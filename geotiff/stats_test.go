@@ -0,0 +1,109 @@
+package geotiff
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_GeoTIFFStats_Happy(t *testing.T) {
+	t.Run("negative values", func(t *testing.T) {
+		g := constGeoTIFF(t, 135, -20, 0.1, 20, 20, -5)
+		g.data[0][0] = -7
+		got := g.Stats()
+		if got.Min != -7 || got.Max != -5 || got.Count != 400 || got.NoData != 0 {
+			t.Errorf("got %s", got)
+		}
+	})
+
+	t.Run("nodata, NaN and infinite values", func(t *testing.T) {
+		// rampGeoTIFF holds each of 0 to 99 once
+		g := rampGeoTIFF(t, 10, 10)
+		g.SetNoData(0)
+		g.data[0][1] = float32(math.NaN())
+		g.data[0][2] = float32(math.Inf(-1))
+		got := g.Stats()
+		if got.Count != 97 || got.NoData != 3 || got.Min != 3 || got.Max != 99 {
+			t.Errorf("got %s", got)
+		}
+		if want := float64(99*100/2-3) / 97; !checkToTolerance(got.Mean, want, 1e-9) {
+			t.Errorf("got mean %v want %v", got.Mean, want)
+		}
+	})
+
+	t.Run("zero filled border", func(t *testing.T) {
+		// A 2 valued image within a border of zeros one pixel wide
+		g := demGeoTIFF(t, 4326, 135, -20, 0.1, 10, 10, func(i, j int) float32 {
+			if i == 0 || j == 0 || i == 9 || j == 9 {
+				return 0
+			}
+			return 2
+		})
+		got := g.Stats()
+		if got.Count != 100 || got.NoData != 0 || got.Min != 0 || got.Mean != 1.28 {
+			t.Errorf("zeros are valid: got %s", got)
+		}
+		g.SetNoData(0)
+		got = g.Stats()
+		if got.Count != 64 || got.NoData != 36 || got.Min != 2 || got.Mean != 2 {
+			t.Errorf("zeros are nodata: got %s", got)
+		}
+	})
+
+	t.Run("empty image", func(t *testing.T) {
+		g := constGeoTIFF(t, 135, -20, 0.1, 4, 4, float32(math.NaN()))
+		got := g.Stats()
+		if got.Count != 0 || got.NoData != 16 || !math.IsNaN(got.Mean) || !math.IsNaN(got.StdDev) {
+			t.Errorf("got %s", got)
+		}
+	})
+
+	t.Run("large values", func(t *testing.T) {
+		// Summing squares in float32 loses the variance entirely
+		g := rampGeoTIFF(t, 100, 100)
+		for _, d := range g.data {
+			for i := range d {
+				d[i] = 100000 + float32(i%2)
+			}
+		}
+		got := g.Stats()
+		if !checkToTolerance(got.Mean, 100000.5, 1e-9) || !checkToTolerance(got.StdDev, 0.5, 1e-9) {
+			t.Errorf("got %s", got)
+		}
+	})
+
+	t.Run("merged tiles match the whole image", func(t *testing.T) {
+		g := rampGeoTIFF(t, 40, 40)
+		want := emptyStats()
+		for _, v := range g.raster() {
+			want.Add(float64(v))
+		}
+		got := g.Stats()
+		if got.Count != want.Count || got.Min != want.Min || got.Max != want.Max ||
+			!checkToTolerance(got.Mean, want.Mean, 1e-9) || !checkToTolerance(got.StdDev, want.StdDev, 1e-9) {
+			t.Errorf("got %s want %s", got, want)
+		}
+		if len(g.data) != 9 {
+			t.Fatalf("got %d tiles want 9", len(g.data))
+		}
+	})
+
+	t.Run("merged files", func(t *testing.T) {
+		a := constGeoTIFF(t, 135, -20, 0.1, 4, 4, 1)
+		b := constGeoTIFF(t, 135, -20, 0.1, 4, 4, 3)
+		got := a.Stats()
+		got.Merge(b.Stats())
+		got.Merge(constGeoTIFF(t, 135, -20, 0.1, 2, 2, float32(math.NaN())).Stats())
+		if got.Count != 32 || got.NoData != 4 || got.Mean != 2 || got.StdDev != 1 {
+			t.Errorf("got %s", got)
+		}
+	})
+}
+
+func Test_GeoTIFFStats_Sad(t *testing.T) {
+	g := rampGeoTIFF(t, 10, 10)
+	for _, i := range []int{-1, len(g.data)} {
+		if _, err := g.TileStats(i); err == nil {
+			t.Errorf("tile %d: expected an error", i)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package geotiff
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// StreamOptions configures StreamNetwork
+type StreamOptions struct {
+	// Threshold is the flow accumulation, in pixels, at which a stream
+	// begins
+	Threshold float64
+}
+
+// StreamNetwork extracts the streams of an elevation image as GeoJSON
+// LineStrings in the coordinate reference system of the image
+//
+// Pixels with a D8 flow accumulation of at least the threshold are streams.
+// The network is split into links between sources, junctions and outlets,
+// each a LineString through the pixel centres with the properties
+// "strahler" and "shreve", the Strahler and Shreve orders of the link, and
+// "length", its length in metres as measured by Point.Distance. Each link
+// ends at the first pixel of the link downstream of it, so the lines meet
+// at junctions. Links come after all the links upstream of them.
+//
+// Water is routed as for Watersheds, so the image is usually filled first
+// with FillSinks.
+func (g *GeoTIFF) StreamNetwork(opts *StreamOptions) (*FeatureCollection, error) {
+	if opts == nil || !(opts.Threshold > 0) || math.IsInf(opts.Threshold, 0) {
+		return nil, fmt.Errorf("%w: a positive threshold is required", errHydrology)
+	}
+	crs, err := g.CRS()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errHydrology, err)
+	}
+	wgs84, err := CRSFromEPSG(epsgWGS84)
+	if err != nil {
+		return nil, err
+	}
+	bounds, err := g.Bounds()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errHydrology, err)
+	}
+	d, err := g.drainage(D8)
+	if err != nil {
+		return nil, err
+	}
+	acc := d.accumulation()
+	stream := func(k int) bool {
+		return k >= 0 && d.valid[k] && acc[k] >= opts.Threshold
+	}
+
+	// Accumulation grows downstream, so the pixels downstream of a stream
+	// are streams too. Links begin at sources, with no streams flowing in,
+	// and at junctions, with several.
+	inflow := make([]int, len(acc))
+	for k, to := range d.to {
+		if stream(k) && to[0] >= 0 {
+			inflow[to[0]]++
+		}
+	}
+	var starts []int
+	for k := range acc {
+		if stream(k) && inflow[k] != 1 {
+			starts = append(starts, k)
+		}
+	}
+	sort.SliceStable(starts, func(a, b int) bool { return acc[starts[a]] < acc[starts[b]] })
+
+	type order struct{ strahler, shreve int }
+	// The orders of the links flowing into each junction
+	upstream := make(map[int][]order)
+	var features []Feature
+	for _, s := range starts {
+		o := order{1, 1}
+		if in := upstream[s]; len(in) > 0 {
+			o = order{0, 0}
+			count := 0
+			for _, u := range in {
+				if u.strahler > o.strahler {
+					o.strahler, count = u.strahler, 0
+				}
+				if u.strahler == o.strahler {
+					count++
+				}
+				o.shreve += u.shreve
+			}
+			if count > 1 {
+				o.strahler++
+			}
+		}
+
+		pixels := []int{s}
+		for k := s; d.to[k][0] >= 0; {
+			k = d.to[k][0]
+			pixels = append(pixels, k)
+			if inflow[k] != 1 {
+				upstream[k] = append(upstream[k], o)
+				break
+			}
+		}
+		if len(pixels) < 2 {
+			continue
+		}
+
+		line := make([]Position, len(pixels))
+		var length float64
+		var prev Point
+		for n, k := range pixels {
+			p := Point{
+				Lon: bounds.UpperLeft.Lon + (float64(k%d.width)+0.5)*g.PixelScaleX,
+				Lat: bounds.UpperLeft.Lat - (float64(k/d.width)+0.5)*g.PixelScaleY,
+			}
+			line[n] = p.position()
+			q, err := Transform(p, crs, wgs84)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", errHydrology, err)
+			}
+			if n > 0 {
+				length += prev.Distance(q)
+			}
+			prev = q
+		}
+		features = append(features, NewFeature(&Geometry{Type: GeometryLineString, Coordinates: line}, map[string]interface{}{
+			"strahler": float64(o.strahler),
+			"shreve":   float64(o.shreve),
+			"length":   length,
+		}))
+	}
+	return NewFeatureCollection(features), nil
+}
@@ -0,0 +1,84 @@
+package geotiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+// channels returns an image in MGA zone 55 of 10 m pixels where only the
+// channels have data. Tributaries from the north and west meet at 3, 3 and
+// flow south to an outlet at 3, 6, joined at 3, 5 by a tributary from the
+// east. The tributaries are steep so they drain along the channels rather
+// than across the corners of the junctions.
+func channels(t *testing.T) *GeoTIFF {
+	z := map[[2]int]float32{
+		{3, 6}: 0, {3, 5}: 1, {3, 4}: 2, {3, 3}: 3,
+		{3, 2}: 13, {3, 1}: 23, {3, 0}: 33,
+		{2, 3}: 13, {1, 3}: 23, {0, 3}: 33,
+		{4, 5}: 11, {5, 5}: 21, {6, 5}: 31,
+	}
+	g := demGeoTIFF(t, 28355, 300000, 6000000, 10, 7, 7, func(i, j int) float32 {
+		if v, ok := z[[2]int{i, j}]; ok {
+			return v
+		}
+		return -9999
+	})
+	g.SetNoData(-9999)
+	return g
+}
+
+func Test_StreamNetwork_Happy(t *testing.T) {
+	type link struct {
+		pixels   [][2]int
+		strahler float64
+		shreve   float64
+	}
+	tests := []struct {
+		threshold float64
+		want      []link
+	}{
+		{1, []link{
+			{[][2]int{{3, 0}, {3, 1}, {3, 2}, {3, 3}}, 1, 1},
+			{[][2]int{{0, 3}, {1, 3}, {2, 3}, {3, 3}}, 1, 1},
+			{[][2]int{{6, 5}, {5, 5}, {4, 5}, {3, 5}}, 1, 1},
+			{[][2]int{{3, 3}, {3, 4}, {3, 5}}, 2, 2},
+			{[][2]int{{3, 5}, {3, 6}}, 2, 3},
+		}},
+		// Only the channel below the first junction drains 4 pixels
+		{4, []link{
+			{[][2]int{{3, 3}, {3, 4}, {3, 5}, {3, 6}}, 1, 1},
+		}},
+		{100, nil},
+	}
+	for _, tt := range tests {
+		fc, err := channels(t).StreamNetwork(&StreamOptions{Threshold: tt.threshold})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []link
+		for _, f := range fc.Features {
+			var pixels [][2]int
+			for _, p := range f.Geometry.Coordinates.([]Position) {
+				pixels = append(pixels, [2]int{int((p[0] - 300000) / 10), int((6000000 - p[1]) / 10)})
+			}
+			got = append(got, link{pixels, f.Properties["strahler"].(float64), f.Properties["shreve"].(float64)})
+
+			// A metre of easting in MGA is close to a metre on the ground
+			if length, want := f.Properties["length"].(float64), 10*float64(len(pixels)-1); !checkToTolerance(length, want, 0.01*want) {
+				t.Errorf("threshold %v: got length %v want %v", tt.threshold, length, want)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("threshold %v: got %v want %v", tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func Test_StreamNetwork_Sad(t *testing.T) {
+	g := channels(t)
+	for _, opts := range []*StreamOptions{nil, {}, {Threshold: -1}} {
+		if _, err := g.StreamNetwork(opts); err == nil {
+			t.Errorf("threshold %v: expected an error", opts)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package geotiff
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrTagNotFound is returned when a requested tag is not present
+var ErrTagNotFound = errors.New("tag not found")
+
+// ErrTagType is returned when a tag's values cannot be converted to the
+// requested type
+var ErrTagType = errors.New("incompatible tag type")
+
+// Keys returns the tags in ascending order
+//
+// Per the TIFF 6.0 Specification (p.15)
+//
+// The entries in an IFD must be sorted in ascending order by Tag.
+//
+// Iterating over the returned keys therefore visits the tags in the same
+// order they are stored in the file.
+func (t Tags) Keys() []Tag {
+	keys := make([]Tag, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// Len returns the number of values stored in a tag
+func (t Tags) Len(tag Tag) (int, error) {
+	v, ok := t[tag]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrTagNotFound, tag)
+	}
+	return int(v.length), nil
+}
+
+// Uint64s returns the values of an integer tag as unsigned integers
+//
+// Signed values are accepted if they are non negative, and floating
+// point values are accepted if they hold non negative whole numbers.
+func (t Tags) Uint64s(tag Tag) ([]uint64, error) {
+	v, ok := t[tag]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTagNotFound, tag)
+	}
+	if v.fType == ASCII || v.fType == RATIONAL || v.fType == SRATIONAL {
+		return nil, fmt.Errorf("%w: %s is %s, not an integer", ErrTagType, tag, v.fType)
+	}
+	values, err := t.Float64s(tag)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]uint64, 0, len(values))
+	for _, f := range values {
+		if f < 0 || f != math.Trunc(f) || f > math.MaxUint64 {
+			return nil, fmt.Errorf("%w: %s value %v is not an unsigned integer", ErrTagType, tag, f)
+		}
+		out = append(out, uint64(f))
+	}
+	return out, nil
+}
+
+// Int64s returns the values of an integer tag as signed integers
+//
+// Floating point values are accepted if they hold whole numbers.
+func (t Tags) Int64s(tag Tag) ([]int64, error) {
+	v, ok := t[tag]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTagNotFound, tag)
+	}
+	if v.fType == ASCII || v.fType == RATIONAL || v.fType == SRATIONAL {
+		return nil, fmt.Errorf("%w: %s is %s, not an integer", ErrTagType, tag, v.fType)
+	}
+	values, err := t.Float64s(tag)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]int64, 0, len(values))
+	for _, f := range values {
+		if f != math.Trunc(f) || f < math.MinInt64 || f > math.MaxInt64 {
+			return nil, fmt.Errorf("%w: %s value %v is not an integer", ErrTagType, tag, f)
+		}
+		out = append(out, int64(f))
+	}
+	return out, nil
+}
+
+// Float64s returns the values of any numeric tag as floating point values
+//
+// Rational values are returned as the numerator divided by the denominator.
+func (t Tags) Float64s(tag Tag) ([]float64, error) {
+	v, ok := t[tag]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTagNotFound, tag)
+	}
+	out := make([]float64, 0, v.length)
+	switch v.fType {
+	case BYTE:
+		for _, d := range v.byteData {
+			out = append(out, float64(d))
+		}
+	case UNDEFINED:
+		for _, d := range v.undefinedData {
+			out = append(out, float64(d))
+		}
+	case SBYTE:
+		for _, d := range v.sbyteData {
+			out = append(out, float64(d))
+		}
+	case SHORT:
+		for _, d := range v.shortData {
+			out = append(out, float64(d))
+		}
+	case SSHORT:
+		for _, d := range v.sshortData {
+			out = append(out, float64(d))
+		}
+	case LONG:
+		for _, d := range v.longData {
+			out = append(out, float64(d))
+		}
+	case SLONG:
+		for _, d := range v.slongData {
+			out = append(out, float64(d))
+		}
+	case FLOAT:
+		for _, d := range v.floatData {
+			out = append(out, float64(d))
+		}
+	case DOUBLE:
+		out = append(out, v.doubleData...)
+	case RATIONAL:
+		for i := 0; i+1 < len(v.rationalData); i += 2 {
+			if v.rationalData[i+1] == 0 {
+				return nil, fmt.Errorf("%w: %s has a zero denominator", ErrTagType, tag)
+			}
+			out = append(out, float64(v.rationalData[i])/float64(v.rationalData[i+1]))
+		}
+	case SRATIONAL:
+		for i := 0; i+1 < len(v.srationalData); i += 2 {
+			if v.srationalData[i+1] == 0 {
+				return nil, fmt.Errorf("%w: %s has a zero denominator", ErrTagType, tag)
+			}
+			out = append(out, float64(v.srationalData[i])/float64(v.srationalData[i+1]))
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s is %s, not numeric", ErrTagType, tag, v.fType)
+	}
+	return out, nil
+}
+
+// ASCII returns the value of an ASCII tag
+//
+// The trailing NUL terminator required by the TIFF specification is removed.
+func (t Tags) ASCII(tag Tag) (string, error) {
+	v, ok := t[tag]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrTagNotFound, tag)
+	}
+	if v.fType != ASCII {
+		return "", fmt.Errorf("%w: %s is %s, not %s", ErrTagType, tag, v.fType, ASCII)
+	}
+	return strings.TrimRight(v.asciiData, "\x00"), nil
+}
+
+// Bytes returns the raw values of an 8-bit tag
+//
+// BYTE, SBYTE, UNDEFINED and ASCII tags are supported, ASCII tags are
+// returned including their NUL terminator.
+func (t Tags) Bytes(tag Tag) ([]byte, error) {
+	v, ok := t[tag]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTagNotFound, tag)
+	}
+	var out []byte
+	switch v.fType {
+	case BYTE:
+		out = append(out, v.byteData...)
+	case UNDEFINED:
+		out = append(out, v.undefinedData...)
+	case ASCII:
+		out = append(out, v.asciiData...)
+	case SBYTE:
+		out = make([]byte, 0, len(v.sbyteData))
+		for _, d := range v.sbyteData {
+			out = append(out, byte(d))
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s is %s, not an 8-bit type", ErrTagType, tag, v.fType)
+	}
+	return out, nil
+}
+
+// Tags returns the TIFF and GeoTIFF tags read from the file
+func (g *GeoTIFF) Tags() Tags {
+	return g.tags
+}
+
+// NoData returns the nodata value of the image, if one is set
+//
+// The value is read from the GDALNoData tag, which holds the value as an
+// ASCII string.
+func (g *GeoTIFF) NoData() (float64, bool) {
+	s, err := g.tags.ASCII(GDALNoData)
+	if err != nil {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// SetNoData sets the nodata value of the image, which is stored in the
+// GDALNoData tag
+func (g *GeoTIFF) SetNoData(v float64) {
+	if g.tags == nil {
+		g.tags = make(Tags)
+	}
+	g.tags[GDALNoData] = asciiTag(strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+// noData is the nodata value used when processing pixels
+type noData struct {
+	value float32
+	set   bool
+}
+
+// noData returns the nodata value of the image
+func (g *GeoTIFF) noData() noData {
+	v, ok := g.NoData()
+	return noData{value: float32(v), set: ok}
+}
+
+// is reports if v is nodata, NaN values are always treated as nodata
+func (n noData) is(v float32) bool {
+	return math.IsNaN(float64(v)) || (n.set && v == n.value)
+}
+
+// fill returns the value used to fill pixels without data, which is the
+// nodata value if one is set and NaN otherwise
+func (n noData) fill() float32 {
+	if n.set {
+		return n.value
+	}
+	return float32(math.NaN())
+}
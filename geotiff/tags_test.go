@@ -0,0 +1,152 @@
+package geotiff
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func Test_TagAccessors_Happy(t *testing.T) {
+	r, err := os.Open(testfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	g, err := Read(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := g.Tags()
+
+	t.Run("keys in tag order", func(t *testing.T) {
+		keys := tags.Keys()
+		if len(keys) != len(tags) {
+			t.Fatalf("got %d keys want %d", len(keys), len(tags))
+		}
+		for i := 1; i < len(keys); i++ {
+			if keys[i-1] >= keys[i] {
+				t.Errorf("keys not sorted: %s before %s", keys[i-1], keys[i])
+			}
+		}
+		if keys[0] != ImageWidth {
+			t.Errorf("got first key %s want %s", keys[0], ImageWidth)
+		}
+	})
+
+	t.Run("uint64s", func(t *testing.T) {
+		got, err := tags.Uint64s(TileOffsets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []uint64{416, 65952, 131488, 197024}
+		if len(got) != len(want) {
+			t.Fatalf("got %v want %v", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("got %v want %v", got, want)
+			}
+		}
+	})
+
+	t.Run("int64s", func(t *testing.T) {
+		got, err := tags.Int64s(ImageWidth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0] != 180 {
+			t.Errorf("got %v want [180]", got)
+		}
+	})
+
+	t.Run("float64s", func(t *testing.T) {
+		got, err := tags.Float64s(ModelTiepoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []float64{0, 0, 0, 135, -20, 0}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("got %v want %v", got, want)
+			}
+		}
+
+		// integer tags are widened
+		bps, err := tags.Float64s(BitsPerSample)
+		if err != nil || bps[0] != 32 {
+			t.Errorf("got %v, %v want [32]", bps, err)
+		}
+	})
+
+	t.Run("ascii", func(t *testing.T) {
+		got, err := tags.ASCII(GeoASCIIParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "GCS_WGS_1984|"; got != want {
+			t.Errorf("got %q want %q", got, want)
+		}
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		got, err := tags.Bytes(GeoASCIIParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 14 || got[13] != 0 {
+			t.Errorf("got %v want NUL terminated bytes", got)
+		}
+	})
+
+	t.Run("len", func(t *testing.T) {
+		got, err := tags.Len(GeoKeyDirectory)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != 32 {
+			t.Errorf("got %d want 32", got)
+		}
+	})
+}
+
+func Test_TagAccessors_Coercion(t *testing.T) {
+	tags := Tags{
+		Tag(1): {fType: SSHORT, length: 2, sshortData: []int16{-1, 2}},
+		Tag(2): {fType: DOUBLE, length: 2, doubleData: []float64{3, 4.5}},
+		Tag(3): {fType: RATIONAL, length: 1, rationalData: []uint32{1, 4}},
+		Tag(4): {fType: UNDEFINED, length: 2, undefinedData: []uint8{7, 8}},
+		Tag(5): {fType: SRATIONAL, length: 1, srationalData: []int32{1, 0}},
+	}
+
+	if got, err := tags.Int64s(Tag(1)); err != nil || got[0] != -1 || got[1] != 2 {
+		t.Errorf("got %v, %v want [-1 2]", got, err)
+	}
+	if _, err := tags.Uint64s(Tag(1)); !errors.Is(err, ErrTagType) {
+		t.Errorf("negative value: got %v want %v", err, ErrTagType)
+	}
+	if _, err := tags.Int64s(Tag(2)); !errors.Is(err, ErrTagType) {
+		t.Errorf("fractional value: got %v want %v", err, ErrTagType)
+	}
+	if got, err := tags.Float64s(Tag(3)); err != nil || got[0] != 0.25 {
+		t.Errorf("got %v, %v want [0.25]", got, err)
+	}
+	if _, err := tags.Uint64s(Tag(3)); !errors.Is(err, ErrTagType) {
+		t.Errorf("rational: got %v want %v", err, ErrTagType)
+	}
+	if got, err := tags.Bytes(Tag(4)); err != nil || got[1] != 8 {
+		t.Errorf("got %v, %v want [7 8]", got, err)
+	}
+	if _, err := tags.Bytes(Tag(2)); !errors.Is(err, ErrTagType) {
+		t.Errorf("bytes of double: got %v want %v", err, ErrTagType)
+	}
+	if _, err := tags.Float64s(Tag(5)); !errors.Is(err, ErrTagType) {
+		t.Errorf("zero denominator: got %v want %v", err, ErrTagType)
+	}
+	if _, err := tags.ASCII(Tag(2)); !errors.Is(err, ErrTagType) {
+		t.Errorf("ascii of double: got %v want %v", err, ErrTagType)
+	}
+	if _, err := tags.Float64s(GDALNoData); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("missing tag: got %v want %v", err, ErrTagNotFound)
+	}
+}
@@ -0,0 +1,263 @@
+package geotiff
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var errTerrain = errors.New("unable to compute terrain derivative")
+
+// Gradient selects how the elevation gradient of a pixel is estimated from
+// its 3x3 neighbourhood
+type Gradient int
+
+const (
+	// Horn weights the eight neighbours, giving a smoother result which
+	// suits rough terrain.
+	//
+	// See B. Horn (1981), Hill shading and the reflectance map
+	Horn Gradient = iota
+
+	// ZevenbergenThorne uses the four direct neighbours, which suits smooth
+	// terrain.
+	//
+	// See L. Zevenbergen and C. Thorne (1987), Quantitative analysis of land
+	// surface topography
+	ZevenbergenThorne
+)
+
+var gradientToLabel = map[Gradient]string{
+	Horn:              "horn",
+	ZevenbergenThorne: "zevenbergen-thorne",
+}
+
+func (gr Gradient) String() string {
+	v, ok := gradientToLabel[gr]
+	if !ok {
+		return fmt.Sprintf("unrecognized gradient %d", int(gr))
+	}
+	return v
+}
+
+// SlopeUnit is the unit slope is measured in
+type SlopeUnit int
+
+const (
+	Degrees SlopeUnit = iota // degrees from horizontal
+	Percent                  // rise over run as a percentage
+)
+
+// TerrainOptions configures Slope, Aspect and Hillshade
+type TerrainOptions struct {
+	// Gradient is Horn or ZevenbergenThorne.
+	//
+	// Defaults to Horn
+	Gradient Gradient
+
+	// ZFactor converts elevations to the units of the horizontal distances,
+	// which are metres for both projected and geographic images.
+	//
+	// Defaults to 1
+	ZFactor float64
+
+	// SlopeUnit is the unit of Slope.
+	//
+	// Defaults to Degrees
+	SlopeUnit SlopeUnit
+
+	// Azimuth is the compass direction of the light source of Hillshade, in
+	// degrees clockwise from north.
+	//
+	// Defaults to 315, the north west
+	Azimuth *float64
+
+	// Altitude is the angle of the light source of Hillshade above the
+	// horizon in degrees.
+	//
+	// Defaults to 45
+	Altitude *float64
+
+	// MultiDirectional combines hillshades lit from the west, north west,
+	// north and south west, weighted by the aspect of each pixel, instead of
+	// lighting from Azimuth
+	MultiDirectional bool
+}
+
+// Slope returns the slope of an elevation image, in degrees or percent
+//
+// For geographic images the horizontal distance between pixels is measured
+// in metres on a sphere, so east west distances shrink with latitude.
+// Pixels beyond the edges of the image take the value of the edge pixel, and
+// nodata neighbours take the value of the centre pixel. opts may be nil.
+func (g *GeoTIFF) Slope(opts *TerrainOptions) (*GeoTIFF, error) {
+	if opts == nil {
+		opts = &TerrainOptions{}
+	}
+	if opts.SlopeUnit != Degrees && opts.SlopeUnit != Percent {
+		return nil, fmt.Errorf("%w: unrecognized slope unit %d", errTerrain, int(opts.SlopeUnit))
+	}
+	return g.terrain(opts, func(dzdx float64, dzdy float64) float64 {
+		rise := math.Hypot(dzdx, dzdy)
+		if opts.SlopeUnit == Percent {
+			return 100 * rise
+		}
+		return math.Atan(rise) * 180 / math.Pi
+	})
+}
+
+// Aspect returns the compass direction an elevation image faces, in degrees
+// clockwise from north
+//
+// Flat pixels, which face no direction, are nodata. See Slope for the
+// treatment of distances and edges. opts may be nil.
+func (g *GeoTIFF) Aspect(opts *TerrainOptions) (*GeoTIFF, error) {
+	if opts == nil {
+		opts = &TerrainOptions{}
+	}
+	return g.terrain(opts, func(dzdx float64, dzdy float64) float64 {
+		if dzdx == 0 && dzdy == 0 {
+			return math.NaN()
+		}
+		return aspect(dzdx, dzdy)
+	})
+}
+
+// Hillshade returns the shaded relief of an elevation image, from 0 for
+// pixels in shadow to 255 for pixels facing the light source
+//
+// See Slope for the treatment of distances and edges. opts may be nil.
+func (g *GeoTIFF) Hillshade(opts *TerrainOptions) (*GeoTIFF, error) {
+	if opts == nil {
+		opts = &TerrainOptions{}
+	}
+	azimuth, altitude := 315.0, 45.0
+	if opts.Azimuth != nil {
+		azimuth = *opts.Azimuth
+	}
+	if opts.Altitude != nil {
+		altitude = *opts.Altitude
+	}
+	if !(altitude >= 0 && altitude <= 90) {
+		return nil, fmt.Errorf("%w: altitude %v is outside 0 to 90 degrees", errTerrain, altitude)
+	}
+
+	d2R := math.Pi / 180
+	zenith := (90 - altitude) * d2R
+	shade := func(slope float64, aspect float64, azimuth float64) float64 {
+		return math.Cos(zenith)*math.Cos(slope) + math.Sin(zenith)*math.Sin(slope)*math.Cos((azimuth-aspect)*d2R)
+	}
+	return g.terrain(opts, func(dzdx float64, dzdy float64) float64 {
+		slope := math.Atan(math.Hypot(dzdx, dzdy))
+		a := aspect(dzdx, dzdy)
+		if !opts.MultiDirectional {
+			return 255 * math.Max(shade(slope, a, azimuth), 0)
+		}
+
+		// Lights at right angles to the aspect pick out the most relief, so
+		// each light is weighted by the square of the sine of the angle
+		// between them. The weights of the four lights sum to two.
+		//
+		// See R. Mark (1992), Multidirectional, oblique-weighted, shaded-relief
+		// image of the Island of Hawaii
+		var sum float64
+		for _, az := range []float64{225, 270, 315, 360} {
+			w := math.Sin((a - az) * d2R)
+			sum += w * w * math.Max(shade(slope, a, az), 0)
+		}
+		return 255 * sum / 2
+	})
+}
+
+// aspect returns the compass direction, in degrees clockwise from north, of
+// the steepest descent of a surface with gradient dzdx to the east and dzdy
+// to the north
+func aspect(dzdx float64, dzdy float64) float64 {
+	a := math.Atan2(-dzdx, -dzdy) * 180 / math.Pi
+	if a < 0 {
+		a += 360
+	}
+	return a
+}
+
+// pixelSize returns the width and length of a pixel at a latitude, in metres
+// on a sphere for geographic images, so the width shrinks with latitude, or
+// in the units of the CRS for projected images
+func (g *GeoTIFF) pixelSize(crs *CRS, lat float64) (float64, float64) {
+	dx, dy := g.PixelScaleX, g.PixelScaleY
+	if crs.Geographic() {
+		metresPerDegree := earthRadiusInMetres * math.Pi / 180
+		dx *= metresPerDegree * math.Cos(lat*math.Pi/180)
+		dy *= metresPerDegree
+	}
+	return dx, dy
+}
+
+// terrain returns an image of the same grid holding f of the elevation
+// gradient of each pixel, in elevation units per metre to the east and north
+func (g *GeoTIFF) terrain(opts *TerrainOptions, f func(dzdx float64, dzdy float64) float64) (*GeoTIFF, error) {
+	if _, ok := gradientToLabel[opts.Gradient]; !ok {
+		return nil, fmt.Errorf("%w: %s", errTerrain, opts.Gradient)
+	}
+	if opts.ZFactor < 0 {
+		return nil, fmt.Errorf("%w: negative z factor %v", errTerrain, opts.ZFactor)
+	}
+	zFactor := opts.ZFactor
+	if zFactor == 0 {
+		zFactor = 1
+	}
+	crs, err := g.CRS()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errTerrain, err)
+	}
+	bounds, err := g.Bounds()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errTerrain, err)
+	}
+
+	raster := g.raster()
+	width, length := int(g.imageWidth), int(g.imageLength)
+	nd := g.noData()
+	out := make([]float32, len(raster))
+	var z [9]float64
+	for j := 0; j < length; j++ {
+		dx, dy := g.pixelSize(crs, bounds.UpperLeft.Lat-(float64(j)+0.5)*g.PixelScaleY)
+		for i := 0; i < width; i++ {
+			centre := raster[j*width+i]
+			if nd.is(centre) {
+				out[j*width+i] = nd.fill()
+				continue
+			}
+
+			// The window is numbered from the upper left, a to i
+			//  0 1 2
+			//  3 4 5
+			//  6 7 8
+			for k := range z {
+				v := raster[clamp(j+k/3-1, 0, length-1)*width+clamp(i+k%3-1, 0, width-1)]
+				if nd.is(v) {
+					v = centre
+				}
+				z[k] = zFactor * float64(v)
+			}
+
+			var dzdx, dzdy float64
+			switch opts.Gradient {
+			case Horn:
+				dzdx = ((z[2] + 2*z[5] + z[8]) - (z[0] + 2*z[3] + z[6])) / (8 * dx)
+				dzdy = ((z[0] + 2*z[1] + z[2]) - (z[6] + 2*z[7] + z[8])) / (8 * dy)
+			case ZevenbergenThorne:
+				dzdx = (z[5] - z[3]) / (2 * dx)
+				dzdy = (z[1] - z[7]) / (2 * dy)
+			}
+
+			v := f(dzdx, dzdy)
+			if math.IsNaN(v) {
+				out[j*width+i] = nd.fill()
+				continue
+			}
+			out[j*width+i] = float32(v)
+		}
+	}
+	return g.derive(out, width, length, bounds.UpperLeft, g.PixelScaleX, g.PixelScaleY), nil
+}
@@ -0,0 +1,134 @@
+package geotiff
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_Terrain_Happy(t *testing.T) {
+	// A plane in MGA zone 55 with 10 m pixels, rising 1 m per pixel to the
+	// east, so facing west with a slope of atan(0.1)
+	east := demGeoTIFF(t, 28355, 300000, 6000000, 10, 8, 8, func(i, j int) float32 { return float32(i) })
+	slope := math.Atan(0.1) * 180 / math.Pi
+	west, altitude := 270.0, 30.0
+
+	for _, gradient := range []Gradient{Horn, ZevenbergenThorne} {
+		t.Run(gradient.String(), func(t *testing.T) {
+			opts := &TerrainOptions{Gradient: gradient}
+			tests := []struct {
+				name    string
+				compute func(*TerrainOptions) (*GeoTIFF, error)
+				opts    TerrainOptions
+				want    float64
+			}{
+				{"slope", east.Slope, *opts, slope},
+				{"percent slope", east.Slope, TerrainOptions{Gradient: gradient, SlopeUnit: Percent}, 10},
+				{"z factor", east.Slope, TerrainOptions{Gradient: gradient, ZFactor: 10, SlopeUnit: Percent}, 100},
+				{"aspect", east.Aspect, *opts, 270},
+				{"hillshade facing the light", east.Hillshade, TerrainOptions{Gradient: gradient, Azimuth: &west, Altitude: &altitude},
+					255 * math.Cos((60-slope)*math.Pi/180)},
+			}
+			for _, tt := range tests {
+				g, err := tt.compute(&tt.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// Away from the edges of the image
+				v, err := g.loc(4, 4)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !checkToTolerance(float64(v), tt.want, 1e-4) {
+					t.Errorf("%s: got %v want %v", tt.name, v, tt.want)
+				}
+			}
+		})
+	}
+
+	t.Run("aspect of each direction", func(t *testing.T) {
+		tests := []struct {
+			name string
+			f    func(i, j int) float32
+			want float32
+		}{
+			{"rising to the south faces north", func(i, j int) float32 { return float32(j) }, 0},
+			{"rising to the west faces east", func(i, j int) float32 { return float32(-i) }, 90},
+			{"rising to the north faces south", func(i, j int) float32 { return float32(-j) }, 180},
+			{"rising to the north east faces south west", func(i, j int) float32 { return float32(i - j) }, 225},
+		}
+		for _, tt := range tests {
+			g := demGeoTIFF(t, 28355, 300000, 6000000, 10, 8, 8, tt.f)
+			a, err := g.Aspect(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkPixels(t, a, map[[2]int]float32{{4, 4}: tt.want})
+		}
+	})
+
+	t.Run("flat", func(t *testing.T) {
+		flat := demGeoTIFF(t, 28355, 300000, 6000000, 10, 8, 8, func(i, j int) float32 { return 100 })
+		a, err := flat.Aspect(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, err := flat.Hillshade(&TerrainOptions{MultiDirectional: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := float32(255 * math.Cos(45*math.Pi/180))
+		checkPixels(t, a, map[[2]int]float32{{4, 4}: float32(math.NaN())})
+		if v, _ := h.loc(4, 4); !checkToTolerance(float64(v), float64(want), 1e-3) {
+			t.Errorf("got multidirectional hillshade %v want %v", v, want)
+		}
+	})
+
+	t.Run("geographic pixels", func(t *testing.T) {
+		// Rising 1 m per 0.001 degree pixel to the east at 60 degrees south,
+		// where a degree of longitude is half as long as at the equator
+		g := demGeoTIFF(t, 4326, 135, -59.996, 0.001, 8, 8, func(i, j int) float32 { return float32(i) })
+		s, err := g.Slope(&TerrainOptions{SlopeUnit: Percent})
+		if err != nil {
+			t.Fatal(err)
+		}
+		lat := -60.0005 // centre of row 4
+		dx := 0.001 * earthRadiusInMetres * math.Pi / 180 * math.Cos(lat*math.Pi/180)
+		if v, _ := s.loc(4, 4); !checkToTolerance(float64(v), 100/dx, 1e-4) {
+			t.Errorf("got slope %v%% want %v%%", v, 100/dx)
+		}
+	})
+
+	t.Run("nodata", func(t *testing.T) {
+		g := demGeoTIFF(t, 28355, 300000, 6000000, 10, 8, 8, func(i, j int) float32 { return float32(i) })
+		g.SetNoData(-9999)
+		g.data[0][4*16+4] = -9999
+		s, err := g.Slope(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPixels(t, s, map[[2]int]float32{{4, 4}: -9999})
+		if v, _ := s.loc(2, 2); !checkToTolerance(float64(v), slope, 1e-4) {
+			t.Errorf("got slope %v away from nodata want %v", v, slope)
+		}
+		if nd, ok := s.NoData(); !ok || nd != -9999 {
+			t.Errorf("got nodata %v, %v want -9999", nd, ok)
+		}
+	})
+}
+
+func Test_Terrain_Sad(t *testing.T) {
+	g := rampGeoTIFF(t, 8, 8)
+	high := 91.0
+	if _, err := g.Slope(&TerrainOptions{Gradient: Gradient(9)}); err == nil {
+		t.Error("expected an error for an unrecognized gradient")
+	}
+	if _, err := g.Slope(&TerrainOptions{SlopeUnit: SlopeUnit(9)}); err == nil {
+		t.Error("expected an error for an unrecognized slope unit")
+	}
+	if _, err := g.Aspect(&TerrainOptions{ZFactor: -1}); err == nil {
+		t.Error("expected an error for a negative z factor")
+	}
+	if _, err := g.Hillshade(&TerrainOptions{Altitude: &high}); err == nil {
+		t.Error("expected an error for an altitude above 90 degrees")
+	}
+}
@@ -0,0 +1,247 @@
+package geotiff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
+	"image/png"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var errTiles = errors.New("unable to serve tiles")
+
+const (
+	// tileSize is the width and length of a tile in pixels
+	tileSize = 256
+
+	// maxTileZoom is the deepest zoom level served, where a pixel is a few
+	// millimetres
+	maxTileZoom = 30
+
+	// defaultTileCache is the number of tiles cached if no size is given
+	defaultTileCache = 1024
+
+	// webMercatorExtent is half the width of the world in Web Mercator
+	// metres, the circumference of the WGS 84 ellipsoid at the equator over 2
+	webMercatorExtent = math.Pi * 6378137
+)
+
+// TileOptions configures NewTileServer
+type TileOptions struct {
+	// Render is the colour ramp the tiles are drawn with. Stretches span
+	// the valid values of all the images together, so the tiles match,
+	// using their merged Stats, and their merged Histogram for percentiles
+	// and equalisation, so the values of the images are never gathered.
+	//
+	// Defaults to shades of grey over the range of the values
+	Render *RenderOptions
+
+	// Resampling is one of Nearest, Bilinear, Cubic or Lanczos.
+	//
+	// Defaults to Nearest
+	Resampling Resampling
+
+	// CacheSize is the number of rendered tiles kept in memory, or a
+	// negative number to disable caching.
+	//
+	// Defaults to 1024
+	CacheSize int
+}
+
+// tileKey identifies a tile
+type tileKey struct {
+	z, x, y int
+}
+
+// TileServer is an http.Handler serving images as XYZ tiles for web maps,
+// such as Leaflet or OpenLayers
+//
+// Tiles are 256x256 PNGs in Web Mercator at /{z}/{x}/{y}.png, with the
+// tile 0/0/0 covering the world and y increasing to the south. The images
+// are reprojected as each tile is drawn, with earlier images drawn over
+// later ones, and the most recently drawn tiles are cached. Each image is
+// read into memory once, when the server is created, and each tile samples
+// only the pixels beneath it.
+type TileServer struct {
+	sources  []*warpSource
+	bounds   []*CornerCoordinates // bounds of each image in Web Mercator, or nil
+	mercator *CRS
+	opts     *TileOptions
+	colour   func(v float64) color.NRGBA
+	noData   color.NRGBA
+
+	mu    sync.Mutex
+	cache map[tileKey][]byte
+	order []tileKey // cached tiles, oldest first
+}
+
+// NewTileServer returns a TileServer for one or more images. opts may be
+// nil.
+func NewTileServer(images []*GeoTIFF, opts *TileOptions) (*TileServer, error) {
+	if opts == nil {
+		opts = &TileOptions{}
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("%w: no images", errTiles)
+	}
+	if !opts.Resampling.interpolates() {
+		return nil, fmt.Errorf("%w: %s resampling is not supported, use nearest, bilinear, cubic or lanczos", errTiles, opts.Resampling)
+	}
+	render := opts.Render
+	if render == nil {
+		render = &RenderOptions{}
+	}
+	mercator, err := CRSFromEPSG(3857)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &TileServer{
+		sources:  make([]*warpSource, len(images)),
+		bounds:   make([]*CornerCoordinates, len(images)),
+		mercator: mercator,
+		opts:     opts,
+		noData:   render.NoDataColor,
+		cache:    make(map[tileKey][]byte),
+	}
+	for i, g := range images {
+		src, err := g.warpSource()
+		if err != nil {
+			return nil, fmt.Errorf("%w: image %d: %s", errTiles, i, err)
+		}
+		s.sources[i] = src
+		// Images reaching the poles have no bounds in Web Mercator, and are
+		// warped for every tile
+		if b, err := g.BoundsIn(mercator); err == nil {
+			s.bounds[i] = b
+		}
+	}
+	if s.colour, err = colourRamp(render, imageSet(images)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ServeHTTP serves the tile named by the path of the request
+func (s *TileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	key, ok := parseTilePath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	tile, err := s.tile(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(tile)))
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		w.Write(tile)
+	}
+}
+
+// parseTilePath returns the tile of a path of the form /{z}/{x}/{y}.png
+func parseTilePath(path string) (tileKey, bool) {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(parts) != 3 || !strings.HasSuffix(parts[2], ".png") {
+		return tileKey{}, false
+	}
+	parts[2] = strings.TrimSuffix(parts[2], ".png")
+	var v [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return tileKey{}, false
+		}
+		v[i] = n
+	}
+	k := tileKey{z: v[0], x: v[1], y: v[2]}
+	if k.z < 0 || k.z > maxTileZoom || k.x < 0 || k.x >= 1<<k.z || k.y < 0 || k.y >= 1<<k.z {
+		return tileKey{}, false
+	}
+	return k, true
+}
+
+// tile returns the PNG of a tile, from the cache if it has been drawn
+func (s *TileServer) tile(k tileKey) ([]byte, error) {
+	s.mu.Lock()
+	b, ok := s.cache[k]
+	s.mu.Unlock()
+	if ok {
+		return b, nil
+	}
+
+	b, err := s.draw(k)
+	if err != nil {
+		return nil, err
+	}
+
+	size := s.opts.CacheSize
+	if size == 0 {
+		size = defaultTileCache
+	}
+	if size > 0 {
+		s.mu.Lock()
+		if _, ok := s.cache[k]; !ok {
+			for len(s.order) >= size {
+				delete(s.cache, s.order[0])
+				s.order = s.order[1:]
+			}
+			s.cache[k] = b
+			s.order = append(s.order, k)
+		}
+		s.mu.Unlock()
+	}
+	return b, nil
+}
+
+// draw renders a tile as a PNG
+func (s *TileServer) draw(k tileKey) ([]byte, error) {
+	size := 2 * webMercatorExtent / float64(int(1)<<k.z)
+	minX := -webMercatorExtent + float64(k.x)*size
+	maxY := webMercatorExtent - float64(k.y)*size
+	maxX, minY := minX+size, maxY-size
+	target := grid{
+		upperLeft: Point{Lon: minX, Lat: maxY},
+		scaleX:    size / tileSize,
+		scaleY:    size / tileSize,
+		width:     tileSize,
+		length:    tileSize,
+	}
+
+	raster := make([]float32, tileSize*tileSize)
+	for i := range raster {
+		raster[i] = float32(math.NaN())
+	}
+	// Later images are drawn first so earlier ones cover them
+	for i := len(s.sources) - 1; i >= 0; i-- {
+		if b := s.bounds[i]; b != nil && (b.LowerRight.Lon <= minX || b.UpperLeft.Lon >= maxX || b.UpperLeft.Lat <= minY || b.LowerRight.Lat >= maxY) {
+			continue
+		}
+		for j, v := range s.sources[i].warp(target, s.mercator, s.opts.Resampling, noData{}) {
+			if !math.IsNaN(float64(v)) && !math.IsInf(float64(v), 0) {
+				raster[j] = v
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	img := paint(raster, tileSize, tileSize, noData{}, s.colour, s.noData)
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("%w: %s", errTiles, err)
+	}
+	return buf.Bytes(), nil
+}
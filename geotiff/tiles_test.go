@@ -0,0 +1,229 @@
+package geotiff
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// tileOf returns the path of the tile holding a point at a zoom level
+func tileOf(lon float64, lat float64, z int) string {
+	n := math.Exp2(float64(z))
+	x := int((lon + 180) / 360 * n)
+	phi := lat * math.Pi / 180
+	y := int((1 - math.Log(math.Tan(phi)+1/math.Cos(phi))/math.Pi) / 2 * n)
+	return fmt.Sprintf("/%d/%d/%d.png", z, x, y)
+}
+
+// getTile requests a tile from a server, returning the decoded PNG
+func getTile(t *testing.T, url string) image.Image {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "image/png" {
+		t.Errorf("got content type %q want image/png", ct)
+	}
+	img, err := png.Decode(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 256 || b.Dy() != 256 {
+		t.Fatalf("got bounds %v want 256x256", b)
+	}
+	return img
+}
+
+// opaque counts the opaque pixels of an image
+func opaque(img image.Image) int {
+	n := 0
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a == 0xffff {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func Test_TileServer_Happy(t *testing.T) {
+	// rampGeoTIFF covers 135 to 136 east and 20 to 21 south
+	s, err := NewTileServer([]*GeoTIFF{rampGeoTIFF(t, 10, 10)}, &TileOptions{Render: &RenderOptions{Palette: Viridis}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	t.Run("tile", func(t *testing.T) {
+		img := getTile(t, ts.URL+tileOf(135.5, -20.5, 8))
+		// The image covers about 0.7 of the width and length of the tile
+		if n := opaque(img); n < 20000 || n > 40000 {
+			t.Errorf("got %d opaque pixels want about 32000", n)
+		}
+	})
+
+	t.Run("outside", func(t *testing.T) {
+		if n := opaque(getTile(t, ts.URL+tileOf(-60, 40, 8))); n != 0 {
+			t.Errorf("got %d opaque pixels want a transparent tile", n)
+		}
+	})
+
+	t.Run("zoomed out", func(t *testing.T) {
+		// The image spans a few pixels of the tile
+		if n := opaque(getTile(t, ts.URL+tileOf(135.5, -20.5, 2))); n == 0 {
+			t.Error("got a transparent tile want the image")
+		}
+	})
+
+	t.Run("head", func(t *testing.T) {
+		resp, err := http.Head(ts.URL + "/0/0/0.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Access-Control-Allow-Origin") != "*" {
+			t.Errorf("got status %d and headers %v", resp.StatusCode, resp.Header)
+		}
+	})
+}
+
+func Test_TileServer_Cache(t *testing.T) {
+	s, err := NewTileServer([]*GeoTIFF{rampGeoTIFF(t, 10, 10)}, &TileOptions{CacheSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := tileKey{0, 0, 0}, tileKey{1, 1, 1}
+	first, err := s.tile(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := s.tile(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The same cached bytes are returned
+	if &first[0] != &again[0] {
+		t.Error("tile was drawn again want the cached tile")
+	}
+	if _, err := s.tile(b); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.cache[a]; ok || len(s.cache) != 1 {
+		t.Errorf("got %d cached tiles want only the latest", len(s.cache))
+	}
+}
+
+func Test_TileServer_Overlap(t *testing.T) {
+	// The first image is drawn over the second
+	red, blue := color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}
+	top := constGeoTIFF(t, 135, -20, 0.1, 10, 10, 1)
+	bottom := constGeoTIFF(t, 135.5, -20, 0.1, 10, 10, 2)
+	s, err := NewTileServer([]*GeoTIFF{top, bottom}, &TileOptions{
+		Render: &RenderOptions{Stops: []ColorStop{{1, red}, {2, blue}}, Nearest: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	img := getTile(t, ts.URL+tileOf(135.5, -20.5, 6))
+	colours := map[color.NRGBA]int{}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			colours[color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)]++
+		}
+	}
+	// The second image shows only beyond the first, half as wide
+	if r, bl := colours[red], colours[blue]; r == 0 || bl == 0 || math.Abs(float64(r-2*bl)) > float64(r)/10 {
+		t.Errorf("got %d red and %d blue pixels want twice as many red", r, bl)
+	}
+}
+
+func Test_TileServer_Stretch(t *testing.T) {
+	// The stretch spans both images, so the first is black and the second
+	// white rather than each a middle grey
+	black, white := color.NRGBA{0, 0, 0, 255}, color.NRGBA{255, 255, 255, 255}
+	images := []*GeoTIFF{
+		constGeoTIFF(t, 135, -20, 0.1, 10, 10, 1),
+		constGeoTIFF(t, 136, -20, 0.1, 10, 10, 2),
+	}
+	low, high := 0.0, 100.0
+	for _, stretch := range []Stretch{StretchLinear, StretchPercentile, StretchEqualize} {
+		t.Run(stretch.String(), func(t *testing.T) {
+			render := &RenderOptions{Stretch: stretch, LowPercentile: &low, HighPercentile: &high}
+			s, err := NewTileServer(images, &TileOptions{Render: render})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ts := httptest.NewServer(s)
+			defer ts.Close()
+
+			img := getTile(t, ts.URL+tileOf(135.5, -20.5, 6))
+			colours := map[color.NRGBA]int{}
+			b := img.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					colours[color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)]++
+				}
+			}
+			if colours[black] == 0 || colours[white] == 0 {
+				t.Errorf("got colours %v want black and white", colours)
+			}
+		})
+	}
+}
+
+func Test_TileServer_Sad(t *testing.T) {
+	if _, err := NewTileServer(nil, nil); err == nil {
+		t.Error("no images: expected an error")
+	}
+	if _, err := NewTileServer([]*GeoTIFF{rampGeoTIFF(t, 10, 10)}, &TileOptions{Resampling: Mode}); err == nil {
+		t.Error("resampling: expected an error")
+	}
+	if _, err := NewTileServer([]*GeoTIFF{rampGeoTIFF(t, 10, 10)}, &TileOptions{Render: &RenderOptions{Palette: Palette(9)}}); err == nil {
+		t.Error("palette: expected an error")
+	}
+
+	s, err := NewTileServer([]*GeoTIFF{rampGeoTIFF(t, 10, 10)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodPost, "/0/0/0.png", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/", http.StatusNotFound},
+		{http.MethodGet, "/0/0/0.jpg", http.StatusNotFound},
+		{http.MethodGet, "/0/0/0/0.png", http.StatusNotFound},
+		{http.MethodGet, "/a/0/0.png", http.StatusNotFound},
+		{http.MethodGet, "/1/2/0.png", http.StatusNotFound},
+		{http.MethodGet, "/1/0/-1.png", http.StatusNotFound},
+		{http.MethodGet, "/31/0/0.png", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.want {
+				t.Errorf("got status %d want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,602 @@
+package geotiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// Severity is the importance of a validation finding
+type Severity int
+
+const (
+	Info    Severity = iota // informational, the file is valid
+	Warning                 // the file is readable but does not follow recommended practice
+	Error                   // the file is invalid
+)
+
+var severityToLabel = map[Severity]string{
+	Info:    "info",
+	Warning: "warning",
+	Error:   "error",
+}
+
+func (s Severity) String() string {
+	v, ok := severityToLabel[s]
+	if !ok {
+		return fmt.Sprintf("severity %d", int(s))
+	}
+	return v
+}
+
+// MarshalText encodes the severity by name, for machine readable reports
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Finding codes reported by Validate
+const (
+	FindingUnreadable          = "unreadable"            // the header or IFDs could not be parsed
+	FindingMissingTag          = "missing-tag"           // a required TIFF or GeoTIFF tag is missing
+	FindingInvalidTag          = "invalid-tag"           // a tag has an invalid type, length or value
+	FindingBlockCount          = "block-count"           // the number of tile or strip offsets does not match the image size
+	FindingByteCount           = "byte-count"            // a tile or strip byte count does not match its size
+	FindingOffsetBeyondEOF     = "offset-beyond-eof"     // a tile or strip extends past the end of the file
+	FindingOverviewDimensions  = "overview-dimensions"   // overview sizes are not consistent with the full resolution image
+	FindingGeoKeys             = "geokeys"               // the GeoKeyDirectory is malformed or inconsistent
+	FindingMissingOverviews    = "missing-overviews"     // a large image has no overviews
+	FindingNotTiled            = "not-tiled"             // the image is stored in strips
+	FindingIFDAfterData        = "ifd-after-data"        // an IFD is stored after image data
+	FindingDataOrder           = "data-order"            // image data is not ordered as required for a COG
+	FindingGhostArea           = "ghost-area"            // the GDAL structural metadata is missing or inconsistent
+	FindingBlockLeaderMismatch = "block-leader-mismatch" // a tile leader or trailer does not match the tile
+)
+
+// Finding is a single problem found while validating a file
+type Finding struct {
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	// IFD is the index of the IFD the finding relates to, or -1 for the
+	// whole file
+	IFD     int    `json:"ifd"`
+	Tag     Tag    `json:"tag,omitempty"`
+	Message string `json:"message"`
+}
+
+func (f Finding) String() string {
+	if f.IFD < 0 {
+		return fmt.Sprintf("%s: %s: %s", f.Severity, f.Code, f.Message)
+	}
+	return fmt.Sprintf("%s: %s: IFD %d: %s", f.Severity, f.Code, f.IFD, f.Message)
+}
+
+// Report holds the findings of validating a file
+//
+// It can be encoded with encoding/json to produce a machine readable report.
+type Report struct {
+	IFDs     int       `json:"ifds"`
+	COG      bool      `json:"cog"` // the file follows the Cloud Optimized GeoTIFF layout
+	Findings []Finding `json:"findings"`
+}
+
+// Valid reports if the file has no findings of Error severity
+func (r *Report) Valid() bool {
+	for _, f := range r.Findings {
+		if f.Severity == Error {
+			return false
+		}
+	}
+	return true
+}
+
+// Has reports if the report contains a finding with the code
+func (r *Report) Has(code string) bool {
+	for _, f := range r.Findings {
+		if f.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Report) String() string {
+	var sb strings.Builder
+	for _, f := range r.Findings {
+		sb.WriteString(f.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func (r *Report) add(severity Severity, code string, ifd int, tag Tag, format string, args ...interface{}) {
+	r.Findings = append(r.Findings, Finding{
+		Severity: severity,
+		Code:     code,
+		IFD:      ifd,
+		Tag:      tag,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// ValidateOptions configures Validate
+type ValidateOptions struct {
+	// RequireCOG reports layouts which are not Cloud Optimized as errors,
+	// rather than as warnings.
+	RequireCOG bool
+}
+
+// validator holds the state of a single validation
+type validator struct {
+	r        io.ReadSeeker
+	fileSize int64
+	header   head
+	ifds     []ifd
+	layouts  []*layout
+	report   *Report
+	cog      Severity
+}
+
+// Validate checks a TIFF file against the TIFF 6.0, GeoTIFF and Cloud
+// Optimized GeoTIFF specifications
+//
+// Every IFD is checked for the required tags and a consistent tile or strip
+// layout, the first IFD for its georeferencing and GeoKeys, any overviews for
+// consistent dimensions, and the file for the COG layout.
+//
+// Problems with the file are reported as findings rather than errors, an
+// error is only returned if r can not be read. opts may be nil.
+func Validate(r io.ReadSeeker, opts *ValidateOptions) (*Report, error) {
+	v := validator{r: r, report: &Report{Findings: []Finding{}}, cog: Warning}
+	if opts != nil && opts.RequireCOG {
+		v.cog = Error
+	}
+
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	v.fileSize = size
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	ifds, h, err := readIFDs(r)
+	if err != nil {
+		v.report.add(Error, FindingUnreadable, -1, 0, "%s", err)
+		return v.report, nil
+	}
+	v.header, v.ifds = h, ifds
+	v.report.IFDs = len(ifds)
+
+	v.layouts = make([]*layout, len(ifds))
+	for i := range ifds {
+		v.layouts[i] = v.checkLayout(i)
+	}
+	v.checkGeoreference()
+	v.checkGeoKeys()
+	v.checkOverviews()
+	valid := v.report.Valid()
+	cogFindings := len(v.report.Findings)
+	v.checkCOG()
+	v.report.COG = valid
+	for _, f := range v.report.Findings[cogFindings:] {
+		if f.Severity != Info {
+			v.report.COG = false
+		}
+	}
+	return v.report, nil
+}
+
+// checkLayout checks the required tags and the tile or strip layout of an IFD
+func (v *validator) checkLayout(i int) *layout {
+	tags := v.ifds[i].tags
+	required := []Tag{ImageWidth, ImageLength, PhotometricInterpretation}
+	_, tiled := tags[TileWidth]
+	if tiled {
+		required = append(required, TileLength, TileOffsets, TileByteCounts)
+	} else {
+		required = append(required, StripOffsets, StripByteCounts)
+	}
+	missing := false
+	for _, t := range required {
+		if _, ok := tags[t]; !ok {
+			v.report.add(Error, FindingMissingTag, i, t, "missing required tag %s", t)
+			missing = true
+		}
+	}
+	if missing {
+		return nil
+	}
+
+	// The layout is checked for any sample size, although only 32 bit
+	// samples can be read
+	l, err := parseLayout(tags)
+	if err != nil {
+		v.report.add(Error, FindingInvalidTag, i, 0, "%s", err)
+		return nil
+	}
+	if l.bitsPerSample != 32 {
+		v.report.add(Warning, FindingInvalidTag, i, BitsPerSample,
+			"%s %d is not supported by this package, only 32 bit samples", BitsPerSample, l.bitsPerSample)
+	}
+	if format, err := tags.Uint64s(SampleFormat); err == nil && format[0] != uint64(sampleFormatIEEEFP) {
+		v.report.add(Warning, FindingInvalidTag, i, SampleFormat,
+			"%s %d is not supported by this package, only IEEE floating point", SampleFormat, format[0])
+	}
+
+	// Chunky blocks hold every sample of their pixels, while planar blocks
+	// hold one sample each and are stored one band after another
+	samples := uint64(1)
+	if n, err := tags.Uint64s(SamplesPerPixel); err == nil && len(n) > 0 && n[0] > 0 {
+		samples = n[0]
+	}
+	if samples != 1 {
+		v.report.add(Warning, FindingInvalidTag, i, SamplesPerPixel,
+			"%s %d is not supported by this package, only single band images", SamplesPerPixel, samples)
+	}
+	bits, err := tags.Uint64s(BitsPerSample)
+	if err != nil || len(bits) == 0 {
+		bits = []uint64{uint64(l.bitsPerSample)}
+	}
+	sampleBits := func(k int) uint64 {
+		if k < len(bits) {
+			return bits[k]
+		}
+		return bits[0]
+	}
+	planes, pixelBits := 1, uint64(0)
+	if p, err := tags.Uint64s(PlanarConfiguration); err == nil && len(p) > 0 && p[0] == 2 {
+		planes = int(samples)
+	} else {
+		for k := 0; k < int(samples); k++ {
+			pixelBits += sampleBits(k)
+		}
+	}
+	if tiled && (l.tileWidth%16 != 0 || l.tileLength%16 != 0) {
+		v.report.add(Error, FindingInvalidTag, i, TileWidth,
+			"tile size %dx%d is not a multiple of 16", l.tileWidth, l.tileLength)
+	}
+
+	offsetsTag, countsTag := StripOffsets, StripByteCounts
+	if tiled {
+		offsetsTag, countsTag = TileOffsets, TileByteCounts
+	}
+	if len(l.offsets) != planes*l.blocks() {
+		v.report.add(Error, FindingBlockCount, i, offsetsTag,
+			"got %d offsets for %d blocks", len(l.offsets), planes*l.blocks())
+	}
+	if len(l.byteCounts) != len(l.offsets) {
+		v.report.add(Error, FindingBlockCount, i, countsTag,
+			"got %d byte counts for %d offsets", len(l.byteCounts), len(l.offsets))
+		return nil
+	}
+
+	for j, offset := range l.offsets {
+		count := l.byteCounts[j]
+		if offset == 0 && count == 0 {
+			// sparse blocks are allowed by GDAL and read as nodata
+			continue
+		}
+		if int64(offset+count) > v.fileSize {
+			v.report.add(Error, FindingOffsetBeyondEOF, i, offsetsTag,
+				"block %d at %d with %d bytes extends past the end of the file at %d", j, offset, count, v.fileSize)
+			continue
+		}
+		if l.compression == uint16(Uncompressed) {
+			block, bits := j%l.blocks(), pixelBits
+			if planes > 1 {
+				bits = sampleBits(j / l.blocks())
+			}
+			rows := int(l.tileLength)
+			if !tiled && (block+1)*rows > int(l.imageLength) {
+				rows = int(l.imageLength) - block*rows
+			}
+			// Rows of samples smaller than a byte are padded to a whole byte
+			rowBytes := (uint64(l.tileWidth)*bits + 7) / 8
+			if want := uint64(rows) * rowBytes; count != want {
+				v.report.add(Error, FindingByteCount, i, countsTag,
+					"block %d has %d bytes, expected %d", j, count, want)
+			}
+		}
+	}
+	return l
+}
+
+// checkGeoreference checks the first IFD has the tags which place it on the
+// earth
+func (v *validator) checkGeoreference() {
+	tags := v.ifds[0].tags
+	if _, ok := tags[ModelTransformation]; ok {
+		if n, _ := tags.Len(ModelTransformation); n != 16 {
+			v.report.add(Error, FindingInvalidTag, 0, ModelTransformation,
+				"%s has %d values, expected 16", ModelTransformation, n)
+		}
+		return
+	}
+	for _, t := range []Tag{ModelPixelScale, ModelTiepoint} {
+		n, err := tags.Len(t)
+		if err != nil {
+			v.report.add(Error, FindingMissingTag, 0, t, "missing required tag %s", t)
+			continue
+		}
+		if t == ModelPixelScale && n != 3 {
+			v.report.add(Error, FindingInvalidTag, 0, t, "%s has %d values, expected 3", t, n)
+		}
+		if t == ModelTiepoint && (n == 0 || n%6 != 0) {
+			v.report.add(Error, FindingInvalidTag, 0, t, "%s has %d values, expected a multiple of 6", t, n)
+		}
+		if _, err := tags.Float64s(t); err != nil {
+			v.report.add(Error, FindingInvalidTag, 0, t, "%s", err)
+		}
+	}
+	if scale, err := tags.Float64s(ModelPixelScale); err == nil && len(scale) >= 2 {
+		if scale[0] <= 0 || scale[1] <= 0 {
+			v.report.add(Error, FindingInvalidTag, 0, ModelPixelScale,
+				"pixel scale %v, %v must be greater than zero", scale[0], scale[1])
+		}
+	}
+}
+
+// checkGeoKeys checks the structure of the GeoKeyDirectory and the
+// consistency of the keys it holds
+func (v *validator) checkGeoKeys() {
+	tags := v.ifds[0].tags
+	if _, ok := tags[GeoKeyDirectory]; !ok {
+		v.report.add(Error, FindingMissingTag, 0, GeoKeyDirectory, "missing required tag %s", GeoKeyDirectory)
+		return
+	}
+	dir, err := tags.Uint64s(GeoKeyDirectory)
+	if err != nil || len(dir) < 4 {
+		v.report.add(Error, FindingGeoKeys, 0, GeoKeyDirectory, "%s is not a SHORT array with a header", GeoKeyDirectory)
+		return
+	}
+	if dir[0] != 1 {
+		v.report.add(Error, FindingGeoKeys, 0, GeoKeyDirectory, "unsupported KeyDirectoryVersion %d", dir[0])
+	}
+	n := int(dir[3])
+	if len(dir) < 4+4*n {
+		v.report.add(Error, FindingGeoKeys, 0, GeoKeyDirectory,
+			"NumberOfKeys %d does not fit in %d values", n, len(dir))
+		return
+	}
+	for i := 1; i < n; i++ {
+		if dir[4+4*i] <= dir[4*i] {
+			v.report.add(Warning, FindingGeoKeys, 0, GeoKeyDirectory,
+				"keys are not sorted, %s follows %s", GeoKey(dir[4+4*i]), GeoKey(dir[4*i]))
+			break
+		}
+	}
+	for i := 0; i < n; i++ {
+		key, location := GeoKey(dir[4+4*i]), Tag(dir[5+4*i])
+		if location != 0 && location != GeoKeyDirectory {
+			if _, ok := tags[location]; !ok {
+				v.report.add(Error, FindingGeoKeys, 0, location, "%s references missing tag %s", key, location)
+			}
+		}
+	}
+
+	keys, err := readGeoKeys(tags)
+	if err != nil {
+		v.report.add(Error, FindingGeoKeys, 0, GeoKeyDirectory, "%s", err)
+		return
+	}
+	modelType, ok := keys.short(GTModelTypeGeoKey)
+	switch {
+	case !ok:
+		v.report.add(Error, FindingGeoKeys, 0, GeoKeyDirectory, "missing %s", GTModelTypeGeoKey)
+	case modelType == modelTypeProjected:
+		if _, ok := keys.short(ProjectedCSTypeGeoKey); !ok {
+			v.report.add(Error, FindingGeoKeys, 0, GeoKeyDirectory,
+				"projected model without %s", ProjectedCSTypeGeoKey)
+		}
+	case modelType == modelTypeGeographic:
+		if _, ok := keys.short(ProjectedCSTypeGeoKey); ok {
+			v.report.add(Error, FindingGeoKeys, 0, GeoKeyDirectory,
+				"geographic model with %s", ProjectedCSTypeGeoKey)
+		}
+		if _, ok := keys.short(GeographicTypeGeoKey); !ok {
+			if _, ok := keys.double(GeogSemiMajorAxisGeoKey); !ok {
+				v.report.add(Warning, FindingGeoKeys, 0, GeoKeyDirectory,
+					"geographic model without %s or a user defined ellipsoid", GeographicTypeGeoKey)
+			}
+		}
+	case modelType == modelTypeGeocentric || modelType == userDefined:
+	default:
+		v.report.add(Error, FindingGeoKeys, 0, GeoKeyDirectory, "unrecognized %s %d", GTModelTypeGeoKey, modelType)
+	}
+
+	rasterType, ok := keys.short(GTRasterTypeGeoKey)
+	switch {
+	case !ok:
+		v.report.add(Warning, FindingGeoKeys, 0, GeoKeyDirectory, "missing %s, PixelIsArea is assumed", GTRasterTypeGeoKey)
+	case rasterType != rasterPixelIsArea && rasterType != rasterPixelIsPoint:
+		v.report.add(Error, FindingGeoKeys, 0, GeoKeyDirectory, "unrecognized %s %d", GTRasterTypeGeoKey, rasterType)
+	}
+}
+
+// checkOverviews checks each reduced resolution IFD is smaller than the one
+// before it, with the same aspect ratio as the full resolution image
+func (v *validator) checkOverviews() {
+	full := v.layouts[0]
+	prev := full
+	for i := 1; i < len(v.ifds); i++ {
+		l := v.layouts[i]
+		subfile, err := v.ifds[i].tags.Uint64s(NewSubfileType)
+		if err != nil || subfile[0]&uint64(subfileReducedResolution) == 0 {
+			if err == nil && subfile[0]&uint64(subfileMask) != 0 {
+				continue
+			}
+			v.report.add(Warning, FindingOverviewDimensions, i, NewSubfileType,
+				"IFD is not marked as a reduced resolution image")
+		}
+		if l == nil || full == nil || prev == nil {
+			continue
+		}
+		if l.imageWidth >= prev.imageWidth || l.imageLength >= prev.imageLength {
+			v.report.add(Error, FindingOverviewDimensions, i, ImageWidth,
+				"overview %dx%d is not smaller than the previous image %dx%d",
+				l.imageWidth, l.imageLength, prev.imageWidth, prev.imageLength)
+		}
+		// The factor is taken from the width and applied to the length,
+		// allowing for the rounding of either dimension
+		factor := float64(full.imageWidth) / float64(l.imageWidth)
+		want := float64(full.imageLength) / factor
+		if math.Abs(want-float64(l.imageLength)) > 1+factor/float64(full.imageWidth) {
+			v.report.add(Error, FindingOverviewDimensions, i, ImageLength,
+				"overview %dx%d does not match the aspect ratio of the image %dx%d",
+				l.imageWidth, l.imageLength, full.imageWidth, full.imageLength)
+		}
+		if l.compression != full.compression || l.bitsPerSample != full.bitsPerSample {
+			v.report.add(Warning, FindingOverviewDimensions, i, Compression,
+				"overview compression or sample size differs from the full resolution image")
+		}
+		prev = l
+	}
+}
+
+// checkCOG checks the file follows the Cloud Optimized GeoTIFF layout
+//
+// See https://github.com/OSGeo/gdal/blob/master/swig/python/gdal-utils/osgeo_utils/samples/validate_cloud_optimized_geotiff.py
+func (v *validator) checkCOG() {
+	full := v.layouts[0]
+	if full == nil {
+		return
+	}
+	if _, tiled := v.ifds[0].tags[TileWidth]; !tiled {
+		if full.imageWidth > 512 || full.imageLength > 512 {
+			v.report.add(v.cog, FindingNotTiled, 0, TileWidth, "image larger than 512x512 is not tiled")
+		}
+	}
+	if (full.imageWidth > 512 || full.imageLength > 512) && len(v.ifds) == 1 {
+		v.report.add(Warning, FindingMissingOverviews, 0, 0,
+			"image larger than 512x512 has no overviews")
+	}
+
+	// Every IFD must come before the image data
+	firstData := uint64(math.MaxUint64)
+	for _, l := range v.layouts {
+		if l == nil {
+			continue
+		}
+		for _, o := range l.offsets {
+			if o != 0 && o < firstData {
+				firstData = o
+			}
+		}
+	}
+	for i, d := range v.ifds {
+		if uint64(d.offset) > firstData {
+			v.report.add(v.cog, FindingIFDAfterData, i, 0,
+				"IFD at %d is after image data at %d", d.offset, firstData)
+		}
+	}
+
+	// The data of the smallest overview comes first, and the full resolution
+	// image last, with the blocks of each in row major order
+	var prevStart uint64
+	for i := len(v.layouts) - 1; i >= 0; i-- {
+		l := v.layouts[i]
+		if l == nil || len(l.offsets) == 0 {
+			continue
+		}
+		if l.offsets[0] != 0 && l.offsets[0] < prevStart {
+			v.report.add(v.cog, FindingDataOrder, i, 0,
+				"data at %d is before the data of a smaller overview at %d", l.offsets[0], prevStart)
+		}
+		var last uint64
+		for j, o := range l.offsets {
+			if o == 0 {
+				continue
+			}
+			if o < last {
+				v.report.add(v.cog, FindingDataOrder, i, 0, "block %d is not in row major order", j)
+				break
+			}
+			last = o
+		}
+		if l.offsets[0] != 0 {
+			prevStart = l.offsets[0]
+		}
+	}
+
+	v.checkGhostArea()
+}
+
+// checkGhostArea checks the GDAL structural metadata, and the tile leaders
+// and trailers it declares
+func (v *validator) checkGhostArea() {
+	const prefix = "GDAL_STRUCTURAL_METADATA_SIZE="
+	buf := make([]byte, len(prefix)+len("000000 bytes\n"))
+	if _, err := v.r.Seek(headerSize, io.SeekStart); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(v.r, buf); err != nil || !bytes.HasPrefix(buf, []byte(prefix)) {
+		v.report.add(Info, FindingGhostArea, -1, 0, "no GDAL structural metadata")
+		return
+	}
+	var size int
+	if _, err := fmt.Sscanf(string(buf[len(prefix):]), "%06d bytes\n", &size); err != nil || size < 0 ||
+		int64(headerSize+len(buf)+size) > v.fileSize {
+		v.report.add(Error, FindingGhostArea, -1, 0, "malformed GDAL structural metadata size")
+		return
+	}
+	metadata := make([]byte, size)
+	if _, err := io.ReadFull(v.r, metadata); err != nil {
+		v.report.add(Error, FindingGhostArea, -1, 0, "could not read GDAL structural metadata: %s", err)
+		return
+	}
+	md := string(metadata)
+	if !strings.Contains(md, "LAYOUT=IFDS_BEFORE_DATA") {
+		v.report.add(Warning, FindingGhostArea, -1, 0, "GDAL structural metadata does not declare LAYOUT=IFDS_BEFORE_DATA")
+	}
+	leader := strings.Contains(md, "BLOCK_LEADER=SIZE_AS_UINT4")
+	trailer := strings.Contains(md, "BLOCK_TRAILER=LAST_4_BYTES_REPEATED")
+	if !leader && !trailer {
+		return
+	}
+
+	for i, l := range v.layouts {
+		if l == nil || len(l.byteCounts) != len(l.offsets) {
+			continue
+		}
+		for j, o := range l.offsets {
+			count := l.byteCounts[j]
+			if o < fourByte || count < fourByte || int64(o+count+fourByte) > v.fileSize {
+				continue
+			}
+			// Only the leader, the 4 bytes before the block, and the last 4
+			// bytes of the block with the trailer repeating them are read
+			if leader {
+				var b [fourByte]byte
+				if _, err := v.r.Seek(int64(o-fourByte), io.SeekStart); err != nil {
+					return
+				}
+				if _, err := io.ReadFull(v.r, b[:]); err != nil {
+					return
+				}
+				if uint64(binary.LittleEndian.Uint32(b[:])) != count {
+					v.report.add(Error, FindingBlockLeaderMismatch, i, 0,
+						"block %d leader %d does not match its byte count %d", j, binary.LittleEndian.Uint32(b[:]), count)
+					return
+				}
+			}
+			if trailer {
+				var b [2 * fourByte]byte
+				if _, err := v.r.Seek(int64(o+count-fourByte), io.SeekStart); err != nil {
+					return
+				}
+				if _, err := io.ReadFull(v.r, b[:]); err != nil {
+					return
+				}
+				if !bytes.Equal(b[:fourByte], b[fourByte:]) {
+					v.report.add(Error, FindingBlockLeaderMismatch, i, 0,
+						"block %d trailer does not repeat its last 4 bytes", j)
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package geotiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func writeTestCOG(t *testing.T, file string) []byte {
+	t.Helper()
+	r, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	g, err := Read(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	opts := &COGOptions{WriteOptions: WriteOptions{Compression: Deflate, TileWidth: 64, TileLength: 64}}
+	if err := WriteCOG(&buf, g, opts); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// uint16TIFF returns an uncompressed WGS 84 GeoTIFF of 16 bit unsigned
+// integer samples, as delivered by many contractors, in a single strip or one
+// strip per band if planar
+func uint16TIFF(t *testing.T, width int, length int, bands int, planar bool) []byte {
+	t.Helper()
+	wgs84, _ := CRSFromEPSG(4326)
+	tags := wgs84.geoKeyTags()
+	tags[ImageWidth] = shortTag(uint16(width))
+	tags[ImageLength] = shortTag(uint16(length))
+	tags[Compression] = shortTag(uint16(Uncompressed))
+	tags[PhotometricInterpretation] = shortTag(uint16(blackIsZero))
+	if bands == 3 {
+		tags[PhotometricInterpretation] = shortTag(uint16(rGB))
+	}
+	tags[SamplesPerPixel] = shortTag(uint16(bands))
+	tags[RowsPerStrip] = shortTag(uint16(length))
+	tags[ModelPixelScale] = doubleTag(0.1, 0.1, 0)
+	tags[ModelTiepoint] = doubleTag(0, 0, 0, 135, -20, 0)
+	bits, formats := make([]uint16, bands), make([]uint16, bands)
+	for i := range bits {
+		bits[i], formats[i] = 16, sampleFormatUint
+	}
+	tags[BitsPerSample] = shortTag(bits...)
+	tags[SampleFormat] = shortTag(formats...)
+
+	strips, stripBytes := 1, 2*bands*width*length
+	if planar {
+		strips, stripBytes = bands, 2*width*length
+		tags[PlanarConfiguration] = shortTag(2)
+	}
+	offsets, counts := make([]uint32, strips), make([]uint32, strips)
+	for i := range counts {
+		counts[i] = uint32(stripBytes)
+	}
+	tags[StripByteCounts] = longTag(counts...)
+	tags[StripOffsets] = longTag(offsets...)
+
+	// The data follows the IFD, whose size does not depend on the offsets
+	data := headerSize + ifdSize(tags)
+	for i := range offsets {
+		offsets[i] = data + uint32(i*stripBytes)
+	}
+	file := encodeHeader(binary.LittleEndian, headerSize)
+	file = append(file, encodeIFD(tags, binary.LittleEndian, headerSize, 0)...)
+	for i := 0; i < bands*width*length; i++ {
+		file = binary.LittleEndian.AppendUint16(file, uint16(i))
+	}
+	return file
+}
+
+func Test_Validate_Happy(t *testing.T) {
+	t.Run("test file", func(t *testing.T) {
+		r, err := os.Open(testfile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		report, err := Validate(r, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !report.Valid() || report.IFDs != 1 {
+			t.Errorf("got invalid report with %d IFDs:\n%s", report.IFDs, report)
+		}
+	})
+
+	t.Run("cloud optimized", func(t *testing.T) {
+		file := writeTestCOG(t, "testdata/WCSServer_cropped.tif")
+		report, err := Validate(bytes.NewReader(file), &ValidateOptions{RequireCOG: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !report.Valid() || !report.COG {
+			t.Errorf("got invalid COG report:\n%s", report)
+		}
+		if report.IFDs < 2 {
+			t.Errorf("got %d IFDs, expected overviews", report.IFDs)
+		}
+		for _, f := range report.Findings {
+			if f.Severity != Info {
+				t.Errorf("unexpected finding %s", f)
+			}
+		}
+	})
+
+	// Files this package cannot read are valid, with a warning
+	unsupported := []struct {
+		name string
+		file []byte
+		tag  Tag
+	}{
+		{"16 bit", uint16TIFF(t, 10, 10, 1, false), BitsPerSample},
+		{"3 bands", uint16TIFF(t, 10, 10, 3, false), SamplesPerPixel},
+		{"3 planar bands", uint16TIFF(t, 10, 10, 3, true), SamplesPerPixel},
+	}
+	for _, tt := range unsupported {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Validate(bytes.NewReader(tt.file), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			warned := false
+			for _, f := range report.Findings {
+				warned = warned || (f.Severity == Warning && f.Tag == tt.tag)
+			}
+			if !report.Valid() || !warned {
+				t.Errorf("got report without an unsupported %s warning:\n%s", tt.tag, report)
+			}
+		})
+	}
+
+	t.Run("json report", func(t *testing.T) {
+		report := &Report{IFDs: 1}
+		report.add(Warning, FindingNotTiled, 0, TileWidth, "not tiled")
+		b, err := json.Marshal(report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `{"ifds":1,"cog":false,"findings":[{"severity":"warning","code":"not-tiled","ifd":0,"tag":322,"message":"not tiled"}]}`
+		if string(b) != want {
+			t.Errorf("got %s want %s", b, want)
+		}
+	})
+}
+
+func Test_Validate_Sad(t *testing.T) {
+	cog := writeTestCOG(t, "testdata/WCSServer_cropped.tif")
+	ifds, _, err := readIFDs(bytes.NewReader(cog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	offsets, _ := ifds[0].tags.Uint64s(TileOffsets)
+	gray16 := uint16TIFF(t, 10, 10, 1, false)
+	rgb := uint16TIFF(t, 10, 10, 3, false)
+	planar := uint16TIFF(t, 10, 10, 3, true)
+	trailer := append([]byte(nil), cog...)
+	counts, _ := ifds[0].tags.Uint64s(TileByteCounts)
+	trailer[offsets[0]+counts[0]] ^= 0xff
+
+	// patch overwrites the first value of a tag in the first IFD of a little
+	// endian file
+	patch := func(file []byte, tag Tag, value uint32) []byte {
+		file = append([]byte(nil), file...)
+		first := binary.LittleEndian.Uint32(file[4:])
+		n := binary.LittleEndian.Uint16(file[first:])
+		for i := 0; i < int(n); i++ {
+			entry := file[int(first)+2+12*i:]
+			if Tag(binary.LittleEndian.Uint16(entry)) != tag {
+				continue
+			}
+			valueOffset := entry[8:]
+			if binary.LittleEndian.Uint32(entry[4:]) > 1 || fieldType(binary.LittleEndian.Uint16(entry[2:])).bytes() > 4 {
+				valueOffset = file[binary.LittleEndian.Uint32(entry[8:]):]
+			}
+			switch fieldType(binary.LittleEndian.Uint16(entry[2:])) {
+			case SHORT:
+				binary.LittleEndian.PutUint16(valueOffset, uint16(value))
+			default:
+				binary.LittleEndian.PutUint32(valueOffset, value)
+			}
+			return file
+		}
+		t.Fatalf("tag %s not found", tag)
+		return nil
+	}
+
+	tests := []struct {
+		name       string
+		file       []byte
+		requireCOG bool
+		want       string
+	}{
+		{"not a tiff", []byte("not a tiff file"), false, FindingUnreadable},
+		{"truncated", cog[:len(cog)-100], false, FindingOffsetBeyondEOF},
+		{"offset beyond eof", patch(cog, TileOffsets, uint32(len(cog))), false, FindingOffsetBeyondEOF},
+		{"tile size", patch(cog, TileWidth, 40), false, FindingInvalidTag},
+		{"block count", patch(cog, ImageWidth, 1000), false, FindingBlockCount},
+		{"overview dimensions", patch(cog, ImageLength, 100), false, FindingOverviewDimensions},
+		{"geokeys", patch(cog, GeoKeyDirectory, 2), false, FindingGeoKeys},
+		{"leader", patch(cog, TileByteCounts, 10), false, FindingBlockLeaderMismatch},
+		{"data order", patch(cog, TileOffsets, uint32(offsets[len(offsets)-1])), true, FindingDataOrder},
+		{"16 bit truncated", gray16[:len(gray16)-1], false, FindingOffsetBeyondEOF},
+		{"16 bit byte count", patch(gray16, StripByteCounts, 150), false, FindingByteCount},
+		{"16 bit block count", patch(gray16, RowsPerStrip, 5), false, FindingBlockCount},
+		{"3 band byte count", patch(rgb, StripByteCounts, 200), false, FindingByteCount},
+		{"3 planar band byte count", patch(planar, StripByteCounts, 600), false, FindingByteCount},
+		{"3 planar band block count", patch(planar, PlanarConfiguration, 1), false, FindingBlockCount},
+		{"trailer", trailer, false, FindingBlockLeaderMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Validate(bytes.NewReader(tt.file), &ValidateOptions{RequireCOG: tt.requireCOG})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Valid() || report.COG {
+				t.Errorf("expected an invalid report:\n%s", report)
+			}
+			if !report.Has(tt.want) {
+				t.Errorf("missing %s finding:\n%s", tt.want, report)
+			}
+		})
+	}
+
+	t.Run("strips are not cloud optimized", func(t *testing.T) {
+		r, err := os.Open("testdata/WCSServer_cropped.tif")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		report, err := Validate(r, &ValidateOptions{RequireCOG: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.COG || !report.Has(FindingNotTiled) || !report.Has(FindingMissingOverviews) {
+			t.Errorf("got COG report:\n%s", report)
+		}
+	})
+}
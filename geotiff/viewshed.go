@@ -0,0 +1,382 @@
+package geotiff
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var errViewshed = errors.New("unable to compute visibility")
+
+// viewshedNoData is the value of viewshed pixels beyond the radius or
+// without elevation
+const viewshedNoData = -1
+
+// defaultRefraction is the refraction coefficient of visible light in a
+// standard atmosphere, which bends lines of sight down around the earth
+const defaultRefraction = 1.0 / 7
+
+// ViewshedOptions configures Viewshed and LineOfSight
+type ViewshedOptions struct {
+	// ObserverHeight is the height of the observer above the ground
+	ObserverHeight float64
+
+	// TargetHeight is the height above the ground of the targets the
+	// observer is looking for
+	TargetHeight float64
+
+	// Radius is the maximum distance from the observer in metres, or zero
+	// for no limit
+	Radius float64
+
+	// Curvature lowers distant terrain to allow for the curvature of the
+	// earth, less the refraction of the line of sight
+	Curvature bool
+
+	// Refraction is the refraction coefficient used with Curvature.
+	//
+	// Defaults to 1/7, for visible light. Radio waves are usually modelled
+	// with 0.25, a four thirds earth radius.
+	Refraction *float64
+}
+
+// Sightline is the result of a line of sight query
+type Sightline struct {
+	Visible bool // the target can be seen from the observer
+
+	// Obstruction is the first point from the observer where the terrain
+	// rises above the line of sight, if the target is not visible
+	Obstruction Point
+	Distance    float64 // distance from the observer to the obstruction in metres
+	Elevation   float64 // elevation of the terrain at the obstruction
+}
+
+// sight holds the geometry of the lines of sight from an observer
+type sight struct {
+	raster []float32
+	width  int
+	length int
+	nd     noData
+	// Metres per pixel across and down
+	mx, my float64
+	// Observer position in pixels and elevation, including its height
+	ox, oy, oz float64
+	opts       *ViewshedOptions
+	drop       float64 // fall of the terrain per metre squared from the observer
+}
+
+// newSight returns the geometry of the lines of sight from an observer in
+// the coordinate reference system of the image
+func (g *GeoTIFF) newSight(observer Point, opts *ViewshedOptions) (*sight, error) {
+	if opts.Radius < 0 {
+		return nil, fmt.Errorf("%w: negative radius %v", errViewshed, opts.Radius)
+	}
+	crs, err := g.CRS()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errViewshed, err)
+	}
+	bounds, err := g.Bounds()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errViewshed, err)
+	}
+	// Geographic images are measured on a plane tangent at the observer,
+	// which is accurate over the distances terrain can be seen
+	mx, my := g.pixelSize(crs, observer.Lat)
+	s := &sight{
+		raster: g.raster(),
+		width:  int(g.imageWidth),
+		length: int(g.imageLength),
+		nd:     g.noData(),
+		mx:     mx,
+		my:     my,
+		ox:     (observer.Lon - bounds.UpperLeft.Lon) / g.PixelScaleX,
+		oy:     (bounds.UpperLeft.Lat - observer.Lat) / g.PixelScaleY,
+		opts:   opts,
+	}
+	if opts.Curvature {
+		k := defaultRefraction
+		if opts.Refraction != nil {
+			k = *opts.Refraction
+		}
+		s.drop = (1 - k) / (2 * earthRadiusInMetres)
+	}
+
+	z, ok := s.elevation(s.ox, s.oy)
+	if !ok {
+		return nil, fmt.Errorf("%w: observer %v is outside the image or on nodata", errViewshed, observer)
+	}
+	s.oz = z + opts.ObserverHeight
+	return s, nil
+}
+
+// elevation returns the elevation of the pixel containing x, y
+func (s *sight) elevation(x float64, y float64) (float64, bool) {
+	if !(x >= 0 && x < float64(s.width) && y >= 0 && y < float64(s.length)) {
+		return 0, false
+	}
+	v := s.raster[int(y)*s.width+int(x)]
+	if s.nd.is(v) {
+		return 0, false
+	}
+	return float64(v), true
+}
+
+// distance returns the distance in metres from the observer to x, y
+func (s *sight) distance(x float64, y float64) float64 {
+	return math.Hypot((x-s.ox)*s.mx, (y-s.oy)*s.my)
+}
+
+// obstruction returns the first point from the observer to x, y where the
+// terrain rises above the line of sight to a target at elevation z, in
+// pixels and metres from the observer, or false if there is none
+//
+// The terrain is sampled wherever the line of sight crosses a row or
+// column of pixel centres, interpolating between the two pixels either
+// side, so every pixel the line passes near is considered.
+func (s *sight) obstruction(x float64, y float64, z float64) (float64, float64, float64, bool) {
+	dx, dy := x-s.ox, y-s.oy
+	total := s.distance(x, y)
+	target := z - s.drop*total*total
+
+	for _, t := range s.crossings(nil, dx, dy, false) {
+		px, py := s.ox+t*dx, s.oy+t*dy
+		v, ok := s.interpolate(px, py)
+		if !ok {
+			continue
+		}
+		d := t * total
+		if v-s.drop*d*d > s.oz+(target-s.oz)*t {
+			return px, py, v, true
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// crossings appends to ts, in increasing order, the fractions of the way
+// along the line from the observer by dx, dy pixels at which it crosses a
+// row or column of pixel centres, including the end of the line if end is
+// set
+func (s *sight) crossings(ts []float64, dx float64, dy float64, end bool) []float64 {
+	add := func(o float64, d float64) {
+		if d == 0 {
+			return
+		}
+		lo, hi := math.Min(o, o+d), math.Max(o, o+d)
+		for c := math.Ceil(lo-0.5) + 0.5; c <= hi; c++ {
+			if t := (c - o) / d; t > 0 && (t < 1 || end && t == 1) {
+				ts = append(ts, t)
+			}
+		}
+	}
+	add(s.ox, dx)
+	add(s.oy, dy)
+	sort.Float64s(ts)
+	return ts
+}
+
+// interpolate returns the elevation at a point on a row or column of pixel
+// centres, linearly interpolated between the pixels either side of it
+func (s *sight) interpolate(x float64, y float64) (float64, bool) {
+	fx, fy := x-0.5, y-0.5
+	i0, j0 := int(math.Floor(fx)), int(math.Floor(fy))
+	wx, wy := fx-float64(i0), fy-float64(j0)
+	var sum, weights float64
+	for _, c := range [4][3]float64{{0, 0, (1 - wx) * (1 - wy)}, {1, 0, wx * (1 - wy)}, {0, 1, (1 - wx) * wy}, {1, 1, wx * wy}} {
+		if c[2] < 1e-12 {
+			continue
+		}
+		v, ok := s.elevation(float64(i0)+c[0]+0.5, float64(j0)+c[1]+0.5)
+		if !ok {
+			continue
+		}
+		sum += c[2] * v
+		weights += c[2]
+	}
+	if weights == 0 {
+		return 0, false
+	}
+	return sum / weights, true
+}
+
+// Viewshed returns an image of the pixels visible from an observer, in the
+// coordinate reference system of the image, analogous to gdal_viewshed
+//
+// Visible pixels are 1 and hidden pixels 0, with pixels beyond the radius
+// or without elevation set to nodata, -1. A pixel is visible if a target
+// at its centre can be seen over the terrain, see LineOfSight, which is
+// decided by rays swept out from the observer in time proportional to the
+// number of pixels. Close to a ridge a pixel may take the visibility of a
+// ray passing beside its centre. opts may be nil.
+func (g *GeoTIFF) Viewshed(observer Point, opts *ViewshedOptions) (*GeoTIFF, error) {
+	if opts == nil {
+		opts = &ViewshedOptions{}
+	}
+	s, err := g.newSight(observer, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the pixels within the radius are traced
+	i0, i1, j0, j1 := 0, s.width, 0, s.length
+	if opts.Radius > 0 {
+		i0 = clamp(int(math.Floor(s.ox-opts.Radius/s.mx)), 0, s.width)
+		i1 = clamp(int(math.Ceil(s.ox+opts.Radius/s.mx)), 0, s.width)
+		j0 = clamp(int(math.Floor(s.oy-opts.Radius/s.my)), 0, s.length)
+		j1 = clamp(int(math.Ceil(s.oy+opts.Radius/s.my)), 0, s.length)
+	}
+	out := make([]float32, len(s.raster))
+	for k := range out {
+		out[k] = viewshedNoData
+	}
+	inside := func(i int, j int) bool {
+		return i >= i0 && i < i1 && j >= j0 && j < j1 &&
+			(opts.Radius <= 0 || s.distance(float64(i)+0.5, float64(j)+0.5) <= opts.Radius)
+	}
+	s.sweep(out, i0, i1, j0, j1, inside)
+
+	// Pixels no ray passes through, which are rare, are traced on their own
+	for j := j0; j < j1; j++ {
+		for i := i0; i < i1; i++ {
+			if out[j*s.width+i] != viewshedNoData || !inside(i, j) {
+				continue
+			}
+			x, y := float64(i)+0.5, float64(j)+0.5
+			z, ok := s.elevation(x, y)
+			if !ok {
+				continue
+			}
+			out[j*s.width+i] = 1
+			if _, _, _, blocked := s.obstruction(x, y, z+opts.TargetHeight); blocked {
+				out[j*s.width+i] = 0
+			}
+		}
+	}
+
+	bounds, err := g.Bounds()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errViewshed, err)
+	}
+	v := g.derive(out, s.width, s.length, bounds.UpperLeft, g.PixelScaleX, g.PixelScaleY)
+	v.SetNoData(viewshedNoData)
+	return v, nil
+}
+
+// sweep marks the visibility of the pixels of a window, using the R2
+// algorithm of Franklin and Ray (1994)
+//
+// A ray is cast to the centre of each pixel on the edge of the window,
+// keeping the steepest slope of the terrain from the observer as it goes, so
+// each ray decides every pixel it passes in a single pass rather than each
+// pixel being traced back to the observer. A pixel takes its visibility from
+// the ray passing closest to its centre.
+func (s *sight) sweep(out []float32, i0 int, i1 int, j0 int, j1 int, inside func(i int, j int) bool) {
+	if i0 >= i1 || j0 >= j1 {
+		return
+	}
+	// The offset of the closest ray from the centre of each pixel decided
+	closest := make([]float64, len(out))
+	for k := range closest {
+		closest[k] = math.Inf(1)
+	}
+	// The observer sees its own pixel
+	if oi, oj := int(s.ox), int(s.oy); inside(oi, oj) {
+		if _, ok := s.elevation(s.ox, s.oy); ok {
+			out[oj*s.width+oi] = 1
+			closest[oj*s.width+oi] = 0
+		}
+	}
+
+	// The buffers are reused by every ray
+	var ts, horizon []float64
+	ray := func(i int, j int) {
+		dx, dy := float64(i)+0.5-s.ox, float64(j)+0.5-s.oy
+		total := math.Hypot(dx*s.mx, dy*s.my)
+		ts = s.crossings(ts[:0], dx, dy, true)
+
+		// The steepest slope of the terrain up to each sample along the ray
+		horizon = horizon[:0]
+		steepest := math.Inf(-1)
+		for _, t := range ts {
+			d := t * total
+			if v, ok := s.interpolate(s.ox+t*dx, s.oy+t*dy); ok {
+				steepest = math.Max(steepest, (v-s.drop*d*d-s.oz)/d)
+			}
+			horizon = append(horizon, steepest)
+		}
+
+		for n, t := range ts {
+			px, py := s.ox+t*dx, s.oy+t*dy
+			pi, pj := int(px), int(py)
+			if pi < 0 || pi >= s.width || pj < 0 || pj >= s.length || !inside(pi, pj) {
+				continue
+			}
+			k := pj*s.width + pi
+			cx, cy := float64(pi)+0.5, float64(pj)+0.5
+			offset := math.Hypot(px-cx, py-cy)
+			if offset >= closest[k] {
+				continue
+			}
+			z, ok := s.elevation(cx, cy)
+			if !ok {
+				continue
+			}
+			// The pixel is seen over the samples before the one within it
+			dc := s.distance(cx, cy)
+			slope := (z + s.opts.TargetHeight - s.drop*dc*dc - s.oz) / dc
+			out[k] = 1
+			if n > 0 && horizon[n-1] > slope {
+				out[k] = 0
+			}
+			closest[k] = offset
+		}
+	}
+	for i := i0; i < i1; i++ {
+		ray(i, j0)
+		ray(i, j1-1)
+	}
+	for j := j0 + 1; j < j1-1; j++ {
+		ray(i0, j)
+		ray(i1-1, j)
+	}
+}
+
+// LineOfSight reports if a target can be seen from an observer, both in the
+// coordinate reference system of the image, and if not the first point
+// where the terrain blocks the view
+//
+// The observer and target stand at the given heights on the terrain of the
+// pixels containing them. Nodata pixels do not block the view. The radius
+// is not applied. opts may be nil.
+func (g *GeoTIFF) LineOfSight(observer Point, target Point, opts *ViewshedOptions) (*Sightline, error) {
+	if opts == nil {
+		opts = &ViewshedOptions{}
+	}
+	s, err := g.newSight(observer, opts)
+	if err != nil {
+		return nil, err
+	}
+	bounds, err := g.Bounds()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errViewshed, err)
+	}
+	x := (target.Lon - bounds.UpperLeft.Lon) / g.PixelScaleX
+	y := (bounds.UpperLeft.Lat - target.Lat) / g.PixelScaleY
+	z, ok := s.elevation(x, y)
+	if !ok {
+		return nil, fmt.Errorf("%w: target %v is outside the image or on nodata", errViewshed, target)
+	}
+
+	px, py, v, blocked := s.obstruction(x, y, z+opts.TargetHeight)
+	if !blocked {
+		return &Sightline{Visible: true}, nil
+	}
+	return &Sightline{
+		Obstruction: Point{
+			Lon: bounds.UpperLeft.Lon + px*g.PixelScaleX,
+			Lat: bounds.UpperLeft.Lat - py*g.PixelScaleY,
+		},
+		Distance:  s.distance(px, py),
+		Elevation: v,
+	}, nil
+}
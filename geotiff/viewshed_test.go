@@ -0,0 +1,161 @@
+package geotiff
+
+import (
+	"math"
+	"testing"
+)
+
+// wallGeoTIFF returns a 20x5 image of 10 m pixels in MGA zone 55 at sea
+// level, with a 100 m wall along column 10
+func wallGeoTIFF(t *testing.T) *GeoTIFF {
+	return demGeoTIFF(t, 28355, 300000, 6000000, 10, 20, 5, func(i, j int) float32 {
+		if i == 10 {
+			return 100
+		}
+		return 0
+	})
+}
+
+func Test_Viewshed_Happy(t *testing.T) {
+	t.Run("wall", func(t *testing.T) {
+		g := wallGeoTIFF(t)
+		v, err := g.Viewshed(centre(t, g, 2, 2), &ViewshedOptions{ObserverHeight: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPixels(t, v, map[[2]int]float32{
+			{2, 2}: 1, {5, 0}: 1, {9, 4}: 1, {10, 2}: 1, {11, 2}: 0, {19, 0}: 0,
+		})
+		if nd, ok := v.NoData(); !ok || nd != viewshedNoData {
+			t.Errorf("got nodata %v, %v want %v", nd, ok, viewshedNoData)
+		}
+	})
+
+	t.Run("tall target", func(t *testing.T) {
+		// A target 200 m tall can be seen over the wall from close behind it,
+		// but further away the top drops below the wall
+		g := wallGeoTIFF(t)
+		v, err := g.Viewshed(centre(t, g, 2, 2), &ViewshedOptions{ObserverHeight: 2, TargetHeight: 200})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPixels(t, v, map[[2]int]float32{{11, 2}: 1, {19, 2}: 0})
+	})
+
+	t.Run("radius", func(t *testing.T) {
+		g := demGeoTIFF(t, 28355, 300000, 6000000, 10, 20, 5, func(i, j int) float32 { return 0 })
+		v, err := g.Viewshed(centre(t, g, 0, 0), &ViewshedOptions{ObserverHeight: 2, Radius: 50})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPixels(t, v, map[[2]int]float32{{5, 0}: 1, {6, 0}: viewshedNoData, {3, 4}: 1, {4, 4}: viewshedNoData})
+	})
+
+	t.Run("curvature", func(t *testing.T) {
+		// From 10 m above a flat sea the horizon is about 12 km away
+		g := demGeoTIFF(t, 28355, 300000, 6000000, 1000, 30, 1, func(i, j int) float32 { return 0 })
+		flat, err := g.Viewshed(centre(t, g, 0, 0), &ViewshedOptions{ObserverHeight: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPixels(t, flat, map[[2]int]float32{{5, 0}: 1, {20, 0}: 1})
+		curved, err := g.Viewshed(centre(t, g, 0, 0), &ViewshedOptions{ObserverHeight: 10, Curvature: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPixels(t, curved, map[[2]int]float32{{5, 0}: 1, {11, 0}: 1, {14, 0}: 0, {20, 0}: 0})
+		// Radio waves bend further, so reach further
+		k := 0.25
+		radio, err := g.Viewshed(centre(t, g, 0, 0), &ViewshedOptions{ObserverHeight: 10, Curvature: true, Refraction: &k})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPixels(t, radio, map[[2]int]float32{{13, 0}: 1, {15, 0}: 0})
+	})
+}
+
+func Test_Viewshed_Sweep(t *testing.T) {
+	// The rays swept from the observer agree with tracing each pixel back to
+	// the observer, other than a few pixels beside ridges, where a ray passing
+	// beside the centre of a pixel sees slightly different terrain
+	g := demGeoTIFF(t, 28355, 300000, 6000000, 10, 60, 60, func(i, j int) float32 {
+		return float32(50 * math.Sin(float64(i)/7) * math.Cos(float64(j)/11))
+	})
+	observer := centre(t, g, 20, 30)
+	opts := &ViewshedOptions{ObserverHeight: 10}
+	v, err := g.Viewshed(observer, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := g.newSight(observer, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raster, differ := v.raster(), 0
+	for k, got := range raster {
+		x, y := float64(k%60)+0.5, float64(k/60)+0.5
+		z, _ := s.elevation(x, y)
+		want := float32(1)
+		if _, _, _, blocked := s.obstruction(x, y, z); blocked {
+			want = 0
+		}
+		if got != want {
+			differ++
+		}
+	}
+	if differ > 3*len(raster)/100 {
+		t.Errorf("%d of %d pixels differ from tracing each pixel", differ, len(raster))
+	}
+}
+
+func Test_LineOfSight_Happy(t *testing.T) {
+	g := wallGeoTIFF(t)
+	observer := centre(t, g, 2, 2)
+
+	got, err := g.LineOfSight(observer, centre(t, g, 15, 2), &ViewshedOptions{ObserverHeight: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Visible {
+		t.Fatal("want the wall to block the view")
+	}
+	want := centre(t, g, 10, 2)
+	if !checkToTolerance(got.Obstruction.Lon, want.Lon, 1e-6) || !checkToTolerance(got.Obstruction.Lat, want.Lat, 1e-6) {
+		t.Errorf("got obstruction %v want %v", got.Obstruction, want)
+	}
+	if !checkToTolerance(got.Distance, 80, 1e-6) || got.Elevation != 100 {
+		t.Errorf("got distance %v elevation %v want 80, 100", got.Distance, got.Elevation)
+	}
+
+	got, err = g.LineOfSight(observer, centre(t, g, 8, 0), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Visible {
+		t.Errorf("got %+v want visible", got)
+	}
+}
+
+func Test_Viewshed_Sad(t *testing.T) {
+	g := wallGeoTIFF(t)
+	g.SetNoData(100)
+	tests := []struct {
+		name     string
+		observer Point
+		opts     *ViewshedOptions
+	}{
+		{"outside the image", Point{Lon: 0, Lat: 0}, nil},
+		{"on nodata", centre(t, g, 10, 2), nil},
+		{"negative radius", centre(t, g, 2, 2), &ViewshedOptions{Radius: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := g.Viewshed(tt.observer, tt.opts); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+	if _, err := g.LineOfSight(centre(t, g, 2, 2), centre(t, g, 10, 2), nil); err == nil {
+		t.Error("expected an error for a target on nodata")
+	}
+}
@@ -0,0 +1,233 @@
+package geotiff
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var errWarp = errors.New("unable to warp GeoTIFF")
+
+// WarpOptions configures the target grid of Warp
+type WarpOptions struct {
+	// CRS is the target coordinate reference system.
+	//
+	// Defaults to the coordinate reference system of the source image
+	CRS *CRS
+
+	// Bounds is the target extent in the target coordinate reference system.
+	//
+	// Defaults to the bounds of the source image in the target coordinate
+	// reference system
+	Bounds *CornerCoordinates
+
+	// PixelScaleX and PixelScaleY are the target resolution in units of the
+	// target coordinate reference system. Zero values are derived from the
+	// target size if one is given, otherwise square pixels are used with the
+	// same number of pixels as the source image.
+	PixelScaleX float64
+	PixelScaleY float64
+
+	// Width and Length are the target size in pixels, used when the
+	// resolution is not given
+	Width  int
+	Length int
+
+	// Resampling is one of Nearest, Bilinear, Cubic or Lanczos.
+	//
+	// Defaults to Nearest
+	Resampling Resampling
+
+	// NoData is the value of target pixels without source data.
+	//
+	// Defaults to the nodata value of the source image, or NaN if it has none
+	NoData *float64
+
+	// TileWidth and TileLength are the tile size of the target image.
+	//
+	// Defaults to 256x256
+	TileWidth  int
+	TileLength int
+}
+
+// grid is the pixel grid of a warped image
+type grid struct {
+	upperLeft Point
+	scaleX    float64
+	scaleY    float64
+	width     int
+	length    int
+}
+
+// targetGrid returns the pixel grid of the target image
+//
+// The upper left corner of the bounds is kept fixed, and the lower right
+// corner is moved out to the nearest whole pixel.
+func (o *WarpOptions) targetGrid(g *GeoTIFF, crs *CRS) (grid, error) {
+	bounds := o.Bounds
+	if bounds == nil {
+		b, err := g.BoundsIn(crs)
+		if err != nil {
+			return grid{}, fmt.Errorf("%w: %s", errWarp, err)
+		}
+		bounds = b
+	}
+	extentX := bounds.UpperRight.Lon - bounds.UpperLeft.Lon
+	extentY := bounds.UpperLeft.Lat - bounds.LowerLeft.Lat
+	if !(extentX > 0 && extentY > 0) {
+		return grid{}, fmt.Errorf("%w: empty target bounds\n%s", errWarp, bounds)
+	}
+
+	scaleX, scaleY := o.PixelScaleX, o.PixelScaleY
+	switch {
+	case scaleX < 0 || scaleY < 0 || o.Width < 0 || o.Length < 0:
+		return grid{}, fmt.Errorf("%w: negative resolution or size", errWarp)
+	case scaleX > 0 && scaleY > 0:
+	case scaleX > 0 || scaleY > 0:
+		return grid{}, fmt.Errorf("%w: both PixelScaleX and PixelScaleY are required", errWarp)
+	case o.Width > 0 && o.Length > 0:
+		scaleX, scaleY = extentX/float64(o.Width), extentY/float64(o.Length)
+	case o.Width > 0 || o.Length > 0:
+		return grid{}, fmt.Errorf("%w: both Width and Length are required", errWarp)
+	default:
+		scale := math.Sqrt(extentX * extentY / (float64(g.imageWidth) * float64(g.imageLength)))
+		scaleX, scaleY = scale, scale
+	}
+
+	// The small tolerance keeps extents which are a whole number of pixels
+	// from gaining a pixel to rounding error
+	width := int(math.Ceil(extentX/scaleX - 1e-6))
+	length := int(math.Ceil(extentY/scaleY - 1e-6))
+	if width < 1 || length < 1 || width > math.MaxUint16 || length > math.MaxUint16 {
+		return grid{}, fmt.Errorf("%w: target size %dx%d is outside 1 to %d pixels", errWarp, width, length, math.MaxUint16)
+	}
+	return grid{upperLeft: bounds.UpperLeft, scaleX: scaleX, scaleY: scaleY, width: width, length: length}, nil
+}
+
+// Warp resamples the image onto a new pixel grid, optionally in a different
+// coordinate reference system, analogous to gdalwarp
+//
+// Each target pixel centre is transformed into the source image and the
+// source sampled there with the requested resampling. Target pixels which
+// fall outside the source image, or on source nodata, are set to nodata.
+//
+// opts may be nil, in which case the image is resampled to its own grid.
+func Warp(g *GeoTIFF, opts *WarpOptions) (*GeoTIFF, error) {
+	if opts == nil {
+		opts = &WarpOptions{}
+	}
+	if !opts.Resampling.interpolates() {
+		return nil, fmt.Errorf("%w: %s resampling is not supported, use nearest, bilinear, cubic or lanczos", errWarp, opts.Resampling)
+	}
+	tileWidth, tileLength := defaultTileSize, defaultTileSize
+	if opts.TileWidth > 0 {
+		tileWidth = opts.TileWidth
+	}
+	if opts.TileLength > 0 {
+		tileLength = opts.TileLength
+	}
+	if tileWidth%16 != 0 || tileLength%16 != 0 {
+		return nil, fmt.Errorf("%w: tile size %dx%d is not a multiple of 16", errWarp, tileWidth, tileLength)
+	}
+
+	src, err := g.warpSource()
+	if err != nil {
+		return nil, err
+	}
+	to := opts.CRS
+	if to == nil {
+		to = src.crs
+	}
+	target, err := opts.targetGrid(g, to)
+	if err != nil {
+		return nil, err
+	}
+
+	out := src.noData
+	outValue, _ := g.NoData()
+	if opts.NoData != nil {
+		outValue = *opts.NoData
+		out = noData{value: float32(outValue), set: true}
+	}
+	warped := src.warp(target, to, opts.Resampling, out)
+
+	tags := to.geoKeyTags()
+	tags[ModelTiepoint] = doubleTag(0, 0, 0, target.upperLeft.Lon, target.upperLeft.Lat, 0)
+	w := &GeoTIFF{
+		tags:        tags,
+		data:        tile(warped, target.width, target.length, tileWidth, tileLength),
+		imageWidth:  uint16(target.width),
+		imageLength: uint16(target.length),
+		tileWidth:   uint16(tileWidth),
+		tileLength:  uint16(tileLength),
+		PixelScaleX: target.scaleX,
+		PixelScaleY: target.scaleY,
+	}
+	if out.set {
+		w.SetNoData(outValue)
+	}
+	return w, nil
+}
+
+// warpSource is an image prepared for sampling by warp, so an image warped
+// many times, such as for each tile of a map, is only read into a raster once
+type warpSource struct {
+	raster    []float32
+	width     int
+	length    int
+	upperLeft Point
+	scaleX    float64
+	scaleY    float64
+	crs       *CRS
+	noData    noData
+}
+
+// warpSource returns the image prepared for sampling by warp
+func (g *GeoTIFF) warpSource() (*warpSource, error) {
+	crs, err := g.CRS()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errWarp, err)
+	}
+	bounds, err := g.Bounds()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errWarp, err)
+	}
+	return &warpSource{
+		raster:    g.raster(),
+		width:     int(g.imageWidth),
+		length:    int(g.imageLength),
+		upperLeft: bounds.UpperLeft,
+		scaleX:    g.PixelScaleX,
+		scaleY:    g.PixelScaleY,
+		crs:       crs,
+		noData:    g.noData(),
+	}, nil
+}
+
+// warp samples the source at the centre of each pixel of the target grid in
+// the to coordinate reference system, returning the row major target raster
+// with pixels outside the source or on source nodata set to out
+func (s *warpSource) warp(target grid, to *CRS, resampling Resampling, out noData) []float32 {
+	warped := make([]float32, target.width*target.length)
+	for j := 0; j < target.length; j++ {
+		for i := 0; i < target.width; i++ {
+			p := Point{
+				Lon: target.upperLeft.Lon + (float64(i)+0.5)*target.scaleX,
+				Lat: target.upperLeft.Lat - (float64(j)+0.5)*target.scaleY,
+			}
+			q, err := Transform(p, to, s.crs)
+			if err != nil {
+				warped[j*target.width+i] = out.fill()
+				continue
+			}
+			x := (q.Lon - s.upperLeft.Lon) / s.scaleX
+			y := (s.upperLeft.Lat - q.Lat) / s.scaleY
+			v := sample(s.raster, s.width, s.length, x, y, 1, 1, resampling, s.noData)
+			if s.noData.is(v) {
+				v = out.fill()
+			}
+			warped[j*target.width+i] = v
+		}
+	}
+	return warped
+}
@@ -0,0 +1,203 @@
+package geotiff
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"testing"
+)
+
+func readTestFile(t *testing.T, file string) *GeoTIFF {
+	t.Helper()
+	r, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	g, err := Read(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func Test_Warp_Happy(t *testing.T) {
+	t.Run("same grid", func(t *testing.T) {
+		g := readTestFile(t, testfile)
+		got, err := Warp(g, &WarpOptions{PixelScaleX: g.PixelScaleX, PixelScaleY: g.PixelScaleY})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, gotRaster := g.raster(), got.raster()
+		if len(gotRaster) != len(want) {
+			t.Fatalf("got %d pixels want %d", len(gotRaster), len(want))
+		}
+		for i := range want {
+			if gotRaster[i] != want[i] {
+				t.Fatalf("pixel %d: got %v want %v", i, gotRaster[i], want[i])
+			}
+		}
+		gotBounds, _ := got.Bounds()
+		wantBounds, _ := g.Bounds()
+		if *gotBounds != *wantBounds {
+			t.Errorf("got bounds\n%swant\n%s", gotBounds, wantBounds)
+		}
+	})
+
+	t.Run("bilinear", func(t *testing.T) {
+		// Halving the pixel size of a linear ramp places the new pixel centres
+		// a quarter of a pixel from the old ones
+		g := rampGeoTIFF(t, 10, 10)
+		got, err := Warp(g, &WarpOptions{PixelScaleX: 0.05, PixelScaleY: 0.05, Resampling: Bilinear})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.imageWidth != 20 || got.imageLength != 20 {
+			t.Fatalf("got size %dx%d want 20x20", got.imageWidth, got.imageLength)
+		}
+		for _, tt := range []struct {
+			x, y int
+			want float64
+		}{{1, 1, 0.25 + 10*0.25}, {5, 8, 2.25 + 10*3.75}, {0, 0, 0}, {19, 19, 9 + 10*9}} {
+			v, _ := got.loc(tt.x, tt.y)
+			if !checkToTolerance(float64(v), tt.want, 1e-4) {
+				t.Errorf("pixel %d, %d: got %v want %v", tt.x, tt.y, v, tt.want)
+			}
+		}
+	})
+
+	t.Run("cubic preserves a linear ramp", func(t *testing.T) {
+		g := rampGeoTIFF(t, 10, 10)
+		got, err := Warp(g, &WarpOptions{Width: 30, Length: 30, Resampling: Cubic})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Pixel 16, 16 has its centre at source pixel 5.5, 5.5, two pixels
+		// from the edges of the image
+		v, _ := got.loc(16, 16)
+		want := (5.5 - 0.5) * 11
+		if !checkToTolerance(float64(v), want, 1e-4) {
+			t.Errorf("got %v want %v", v, want)
+		}
+	})
+
+	t.Run("to MGA", func(t *testing.T) {
+		g := readTestFile(t, testfile)
+		from, _ := g.CRS()
+		mga54, _ := CRSFromEPSG(28354)
+		got, err := Warp(g, &WarpOptions{CRS: mga54, PixelScaleX: 5000, PixelScaleY: 5000})
+		if err != nil {
+			t.Fatal(err)
+		}
+		crs, err := got.CRS()
+		if err != nil || !crs.Equal(mga54) || crs.EPSG != 28354 {
+			t.Fatalf("got %v, %v want %s", crs, err, mga54)
+		}
+
+		bounds, _ := got.Bounds()
+		var inside, outside int
+		for y := 0; y < int(got.imageLength); y++ {
+			for x := 0; x < int(got.imageWidth); x++ {
+				v, _ := got.loc(x, y)
+				p := Point{
+					Lon: bounds.UpperLeft.Lon + (float64(x)+0.5)*got.PixelScaleX,
+					Lat: bounds.UpperLeft.Lat - (float64(y)+0.5)*got.PixelScaleY,
+				}
+				q, err := Transform(p, mga54, from)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, err := g.AtCoord(q.Lon, q.Lat, false)
+				if err != nil {
+					// The image is not rectangular in MGA, so the corners of the
+					// target grid fall outside it
+					outside++
+					if !math.IsNaN(float64(v)) {
+						t.Errorf("pixel %d, %d: got %v want NaN", x, y, v)
+					}
+					continue
+				}
+				inside++
+				if v != want {
+					t.Errorf("pixel %d, %d: got %v want %v", x, y, v, want)
+				}
+			}
+		}
+		if inside == 0 || outside == 0 {
+			t.Errorf("got %d pixels inside and %d outside the source image", inside, outside)
+		}
+
+		// The warped image can be written and read back with its CRS
+		var buf bytes.Buffer
+		if err := Write(&buf, got, nil); err != nil {
+			t.Fatal(err)
+		}
+		back, err := Read(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		crs, err = back.CRS()
+		if err != nil || crs.EPSG != 28354 {
+			t.Errorf("got %v, %v want %s", crs, err, mga54)
+		}
+	})
+
+	t.Run("nodata", func(t *testing.T) {
+		g := rampGeoTIFF(t, 10, 10)
+		g.SetNoData(11)
+		nd := -9999.0
+		bounds := &CornerCoordinates{
+			UpperLeft:  Point{Lon: 134.9, Lat: -19.9},
+			UpperRight: Point{Lon: 135.5, Lat: -19.9},
+			LowerLeft:  Point{Lon: 134.9, Lat: -20.5},
+			LowerRight: Point{Lon: 135.5, Lat: -20.5},
+		}
+		got, err := Warp(g, &WarpOptions{Bounds: bounds, PixelScaleX: 0.1, PixelScaleY: 0.1, NoData: &nd})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, ok := got.NoData(); !ok || v != nd {
+			t.Errorf("got nodata %v, %v want %v", v, ok, nd)
+		}
+		for _, tt := range []struct {
+			x, y int
+			want float32
+		}{{0, 0, -9999}, {1, 1, 0}, {2, 2, -9999}, {3, 2, 12}} {
+			if v, _ := got.loc(tt.x, tt.y); v != tt.want {
+				t.Errorf("pixel %d, %d: got %v want %v", tt.x, tt.y, v, tt.want)
+			}
+		}
+	})
+}
+
+func Test_Warp_Sad(t *testing.T) {
+	g := rampGeoTIFF(t, 10, 10)
+	empty := &CornerCoordinates{UpperLeft: Point{Lon: 135, Lat: -20}, LowerLeft: Point{Lon: 135, Lat: -21}}
+	tests := []struct {
+		name string
+		opts *WarpOptions
+	}{
+		{"aggregating resampling", &WarpOptions{Resampling: Average}},
+		{"width without length", &WarpOptions{Width: 10}},
+		{"x scale without y scale", &WarpOptions{PixelScaleX: 0.1}},
+		{"negative scale", &WarpOptions{PixelScaleX: -0.1, PixelScaleY: 0.1}},
+		{"empty bounds", &WarpOptions{Bounds: empty}},
+		{"too large", &WarpOptions{PixelScaleX: 1e-6, PixelScaleY: 1e-6}},
+		{"tile size", &WarpOptions{TileWidth: 20}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Warp(g, tt.opts); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+
+	t.Run("no CRS", func(t *testing.T) {
+		g := rampGeoTIFF(t, 10, 10)
+		delete(g.tags, GeoKeyDirectory)
+		if _, err := Warp(g, nil); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package geotiff
+
+import (
+	"fmt"
+	"math"
+)
+
+// WatershedOptions configures Watersheds
+type WatershedOptions struct {
+	// SnapRadius is the distance in metres within which each pour point is
+	// moved to the pixel with the greatest flow accumulation, so that points
+	// placed beside a stream fall on it, or zero to use the pixel containing
+	// each pour point
+	SnapRadius float64
+
+	// Attribute is the name of the feature property holding the number of a
+	// watershed.
+	//
+	// Defaults to "watershed"
+	Attribute string
+}
+
+// Watersheds holds the catchments upstream of a set of pour points
+type Watersheds struct {
+	// Labels holds the number of the watershed of each pixel, from 1 in the
+	// order of the pour points, with 0 for pixels that drain to none of
+	// them and -1 for nodata
+	Labels *GeoTIFF
+
+	// Polygons holds the outline of each watershed, in the order of the pour
+	// points
+	Polygons *FeatureCollection
+
+	// PourPoints are the centres of the pixels the pour points were snapped
+	// to
+	PourPoints []Point
+}
+
+// Watersheds delineates the area draining to each of a set of pour points,
+// in the coordinate reference system of an elevation image
+//
+// Water is routed by D8 flow direction, so the image is usually filled
+// first with FillSinks. Where one pour point lies downstream of another, the
+// upstream area belongs to the upstream point alone. opts may be nil.
+func (g *GeoTIFF) Watersheds(pourPoints []Point, opts *WatershedOptions) (*Watersheds, error) {
+	if opts == nil {
+		opts = &WatershedOptions{}
+	}
+	if len(pourPoints) == 0 {
+		return nil, fmt.Errorf("%w: no pour points", errHydrology)
+	}
+	if !(opts.SnapRadius >= 0) || math.IsInf(opts.SnapRadius, 0) {
+		return nil, fmt.Errorf("%w: snap radius %v must be zero or positive", errHydrology, opts.SnapRadius)
+	}
+	attribute := opts.Attribute
+	if attribute == "" {
+		attribute = "watershed"
+	}
+	crs, err := g.CRS()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errHydrology, err)
+	}
+	bounds, err := g.Bounds()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errHydrology, err)
+	}
+	d, err := g.drainage(D8)
+	if err != nil {
+		return nil, err
+	}
+	var acc []float64
+	if opts.SnapRadius > 0 {
+		acc = d.accumulation()
+	}
+
+	width, length := d.width, d.length
+	labels := make([]int, len(d.valid))
+	ws := &Watersheds{}
+	pours := make([]int, len(pourPoints))
+	for n, p := range pourPoints {
+		x := (p.Lon - bounds.UpperLeft.Lon) / g.PixelScaleX
+		y := (bounds.UpperLeft.Lat - p.Lat) / g.PixelScaleY
+		if !(x >= 0 && x < float64(width) && y >= 0 && y < float64(length)) || !d.valid[int(y)*width+int(x)] {
+			return nil, fmt.Errorf("%w: pour point %v is outside the image or on nodata", errHydrology, p)
+		}
+		k := int(y)*width + int(x)
+
+		if opts.SnapRadius > 0 {
+			mx, my := g.pixelSize(crs, p.Lat)
+			ri, rj := int(math.Ceil(opts.SnapRadius/mx)), int(math.Ceil(opts.SnapRadius/my))
+			i0, j0 := k%width, k/width
+			best := math.Inf(1)
+			for j := clamp(j0-rj, 0, length-1); j <= clamp(j0+rj, 0, length-1); j++ {
+				for i := clamp(i0-ri, 0, width-1); i <= clamp(i0+ri, 0, width-1); i++ {
+					m := j*width + i
+					dist := math.Hypot((float64(i)+0.5-x)*mx, (float64(j)+0.5-y)*my)
+					if !d.valid[m] || dist > opts.SnapRadius {
+						continue
+					}
+					// The nearest of the pixels with the most accumulation
+					if acc[m] > acc[k] || (acc[m] == acc[k] && dist < best) {
+						k, best = m, dist
+					}
+				}
+			}
+		}
+
+		if labels[k] != 0 {
+			return nil, fmt.Errorf("%w: pour points %v and %v fall on the same pixel", errHydrology, pourPoints[labels[k]-1], p)
+		}
+		labels[k] = n + 1
+		pours[n] = k
+		ws.PourPoints = append(ws.PourPoints, Point{
+			Lon: bounds.UpperLeft.Lon + (float64(k%width)+0.5)*g.PixelScaleX,
+			Lat: bounds.UpperLeft.Lat - (float64(k/width)+0.5)*g.PixelScaleY,
+		})
+	}
+
+	// Each watershed grows upstream from its pour point, stopping at the
+	// pour points of the others
+	var features []Feature
+	var stack, region []int
+	for n, k := range pours {
+		label := n + 1
+		stack, region = append(stack[:0], k), region[:0]
+		for len(stack) > 0 {
+			c := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			region = append(region, c)
+			for _, o := range d8Neighbours {
+				i, j := c%width+o[0], c/width+o[1]
+				if i < 0 || i >= width || j < 0 || j >= length {
+					continue
+				}
+				if m := j*width + i; d.to[m][0] == c && labels[m] == 0 {
+					labels[m] = label
+					stack = append(stack, m)
+				}
+			}
+		}
+		geometry := g.regionGeometry(region, labels, label, bounds)
+		features = append(features, NewFeature(geometry, map[string]interface{}{attribute: float64(label)}))
+	}
+	ws.Polygons = NewFeatureCollection(features)
+
+	out := make([]float32, len(labels))
+	for k, label := range labels {
+		out[k] = float32(label)
+		if !d.valid[k] {
+			out[k] = flowNoData
+		}
+	}
+	if ws.Labels, err = g.flowImage(out); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
@@ -0,0 +1,119 @@
+package geotiff
+
+import (
+	"math"
+	"testing"
+)
+
+// valley returns a 9x9 image in MGA zone 55 of 10 m pixels, draining into
+// the middle column and down it to the south
+func valley(t *testing.T) *GeoTIFF {
+	return demGeoTIFF(t, 28355, 300000, 6000000, 10, 9, 9, func(i, j int) float32 {
+		return float32(2*math.Abs(float64(i-4)) + float64(8-j))
+	})
+}
+
+func Test_Watersheds_Happy(t *testing.T) {
+	t.Run("single", func(t *testing.T) {
+		g := valley(t)
+		ws, err := g.Watersheds([]Point{centre(t, g, 4, 8)}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range ws.Labels.raster() {
+			if v != 1 {
+				t.Fatalf("pixel %d, %d: got %v want 1", k%9, k/9, v)
+			}
+		}
+		if len(ws.Polygons.Features) != 1 {
+			t.Fatalf("got %d features want 1", len(ws.Polygons.Features))
+		}
+		f := ws.Polygons.Features[0]
+		rings := f.Geometry.Coordinates.([][]Position)
+		if f.Geometry.Type != GeometryPolygon || len(rings) != 1 || !checkToTolerance(ringArea(rings[0]), 8100, 1e-9) {
+			t.Errorf("got %v want the whole image", f.Geometry)
+		}
+		if got := f.Properties["watershed"]; got != 1.0 {
+			t.Errorf("got watershed %v want 1", got)
+		}
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		g := valley(t)
+		ws, err := g.Watersheds([]Point{centre(t, g, 4, 8), centre(t, g, 4, 4)}, &WatershedOptions{Attribute: "id"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[[2]int]float32{}
+		for j := 0; j < 9; j++ {
+			want[[2]int{4, j}] = 1
+			if j <= 4 {
+				want[[2]int{4, j}] = 2
+			}
+		}
+		checkPixels(t, ws.Labels, want)
+
+		// The two watersheds share the image between them
+		var area float64
+		for n, f := range ws.Polygons.Features {
+			if got := f.Properties["id"]; got != float64(n+1) {
+				t.Errorf("got id %v want %d", got, n+1)
+			}
+			for _, ring := range f.Geometry.Coordinates.([][]Position) {
+				area += ringArea(ring)
+			}
+		}
+		if !checkToTolerance(area, 8100, 1e-9) {
+			t.Errorf("got a total area of %v want 8100", area)
+		}
+	})
+
+	t.Run("snapped", func(t *testing.T) {
+		// The pixels of the middle column within 25 m are 4, 5 to 4, 7, of
+		// which the last drains the most
+		g := valley(t)
+		ws, err := g.Watersheds([]Point{centre(t, g, 2, 6)}, &WatershedOptions{SnapRadius: 25})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := ws.PourPoints[0], centre(t, g, 4, 7); got != want {
+			t.Errorf("got pour point %v want %v", got, want)
+		}
+		checkPixels(t, ws.Labels, map[[2]int]float32{{4, 7}: 1, {4, 8}: 0, {0, 0}: 1})
+	})
+
+	t.Run("nodata", func(t *testing.T) {
+		g := valley(t)
+		g.SetNoData(16)
+		ws, err := g.Watersheds([]Point{centre(t, g, 4, 8)}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPixels(t, ws.Labels, map[[2]int]float32{{0, 0}: flowNoData, {1, 0}: 1})
+		if v, ok := ws.Labels.NoData(); !ok || v != flowNoData {
+			t.Errorf("got nodata %v, %v want %v", v, ok, flowNoData)
+		}
+	})
+}
+
+func Test_Watersheds_Sad(t *testing.T) {
+	g := valley(t)
+	tests := []struct {
+		name   string
+		points []Point
+		opts   *WatershedOptions
+	}{
+		{"no pour points", nil, nil},
+		{"outside", []Point{{Lon: 0, Lat: 0}}, nil},
+		{"same pixel", []Point{centre(t, g, 4, 8), centre(t, g, 4, 8)}, nil},
+		{"snapped to the same pixel", []Point{centre(t, g, 3, 8), centre(t, g, 5, 8)}, &WatershedOptions{SnapRadius: 10}},
+		{"negative radius", []Point{centre(t, g, 4, 8)}, &WatershedOptions{SnapRadius: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := g.Watersheds(tt.points, tt.opts); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
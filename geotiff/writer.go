@@ -0,0 +1,387 @@
+package geotiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// headerSize is the length of the TIFF file header in bytes
+const headerSize = 8
+
+// defaultTileSize is used when the GeoTIFF's tiles are not a valid TIFF tile size
+const defaultTileSize = 256
+
+var errGeoTIFFWrite = errors.New("could not write GeoTIFF")
+
+// structuralTags are the tags which describe the layout of the image data.
+// They are always generated by the writer, rather than copied from the tags
+// of the GeoTIFF being written.
+var structuralTags = [...]Tag{
+	ImageWidth, ImageLength, BitsPerSample, Compression,
+	PhotometricInterpretation, FillOrder, StripOffsets, SamplesPerPixel,
+	RowsPerStrip, StripByteCounts, PlanarConfiguration, TileWidth,
+	TileLength, TileOffsets, TileByteCounts, Predictor, ExtraSamples,
+	SampleFormat, ModelPixelScale,
+}
+
+// WriteOptions configures how a GeoTIFF is encoded
+type WriteOptions struct {
+	// ByteOrder is the byte order of the output file.
+	//
+	// Defaults to binary.LittleEndian
+	ByteOrder binary.ByteOrder
+
+	// Compression applied to each tile or strip.
+	//
+	// Defaults to Uncompressed
+	Compression CompressionScheme
+
+	// CompressionLevel is the DEFLATE compression level, from 1 (fastest) to
+	// 9 (smallest). Zero selects the default level. It is ignored for LZW.
+	CompressionLevel int
+
+	// Predictor applied before compression, which requires LZW or Deflate.
+	//
+	// Defaults to NoPredictor
+	Predictor PredictorScheme
+
+	// TileWidth and TileLength are the tile size, which must be multiples
+	// of 16.
+	//
+	// Defaults to the tile size of the GeoTIFF, or 256 if that is not a
+	// multiple of 16.
+	TileWidth  int
+	TileLength int
+
+	// RowsPerStrip writes the image in strips of RowsPerStrip rows instead of
+	// tiles when it is greater than zero.
+	RowsPerStrip int
+}
+
+func (o *WriteOptions) byteOrder() binary.ByteOrder {
+	if o == nil || o.ByteOrder == nil {
+		return binary.LittleEndian
+	}
+	return o.ByteOrder
+}
+
+func (o *WriteOptions) compression() CompressionScheme {
+	if o == nil || o.Compression == 0 {
+		return Uncompressed
+	}
+	return o.Compression
+}
+
+func (o *WriteOptions) predictor() PredictorScheme {
+	if o == nil || o.Predictor == 0 {
+		return NoPredictor
+	}
+	return o.Predictor
+}
+
+func (o *WriteOptions) strips() bool {
+	return o != nil && o.RowsPerStrip > 0
+}
+
+// validate checks the options are supported by the writer
+func (o *WriteOptions) validate() error {
+	switch o.compression() {
+	case Uncompressed, LZW, Deflate:
+	default:
+		return fmt.Errorf("%w: unsupported %s", errGeoTIFFWrite, o.compression())
+	}
+	switch o.predictor() {
+	case NoPredictor:
+	case HorizontalPredictor, FloatingPointPredictor:
+		if o.compression() == Uncompressed {
+			return fmt.Errorf("%w: %s predictor requires compression", errGeoTIFFWrite, o.predictor())
+		}
+	default:
+		return fmt.Errorf("%w: unsupported %s", errGeoTIFFWrite, o.predictor())
+	}
+	if o == nil {
+		return nil
+	}
+	if o.CompressionLevel < 0 || o.CompressionLevel > 9 {
+		return fmt.Errorf("%w: invalid compression level %d", errGeoTIFFWrite, o.CompressionLevel)
+	}
+	if o.TileWidth < 0 || o.TileLength < 0 || o.TileWidth > math.MaxUint16 ||
+		o.TileLength > math.MaxUint16 || o.TileWidth%16 != 0 || o.TileLength%16 != 0 {
+		// Per the TIFF 6.0 Specification (p.67)
+		//
+		// TileWidth must be a multiple of 16. TileLength must be a multiple of 16.
+		return fmt.Errorf("%w: tile size %dx%d is not a multiple of 16",
+			errGeoTIFFWrite, o.TileWidth, o.TileLength)
+	}
+	if o.RowsPerStrip < 0 || o.RowsPerStrip > math.MaxUint16 {
+		return fmt.Errorf("%w: invalid rows per strip %d", errGeoTIFFWrite, o.RowsPerStrip)
+	}
+	return nil
+}
+
+// tileSize returns the size of the tiles to write
+func (o *WriteOptions) tileSize(g *GeoTIFF) (int, int) {
+	w, l := int(g.tileWidth), int(g.tileLength)
+	if w%16 != 0 || l%16 != 0 {
+		w, l = defaultTileSize, defaultTileSize
+	}
+	if o != nil && o.TileWidth > 0 {
+		w = o.TileWidth
+	}
+	if o != nil && o.TileLength > 0 {
+		l = o.TileLength
+	}
+	return w, l
+}
+
+// shortTag creates SHORT tag data
+func shortTag(v ...uint16) tagData {
+	return tagData{fType: SHORT, length: uint32(len(v)), shortData: v}
+}
+
+// longTag creates LONG tag data
+func longTag(v ...uint32) tagData {
+	return tagData{fType: LONG, length: uint32(len(v)), longData: v}
+}
+
+// doubleTag creates DOUBLE tag data
+func doubleTag(v ...float64) tagData {
+	return tagData{fType: DOUBLE, length: uint32(len(v)), doubleData: v}
+}
+
+// asciiTag creates ASCII tag data, terminated with the NUL byte required by
+// the TIFF specification
+func asciiTag(s string) tagData {
+	if len(s) == 0 || s[len(s)-1] != 0 {
+		s += "\x00"
+	}
+	return tagData{fType: ASCII, length: uint32(len(s)), asciiData: s}
+}
+
+// bytes encodes the tag values in the requested byte order
+func (t tagData) bytes(byteOrder binary.ByteOrder) []byte {
+	var buf bytes.Buffer
+	_, elem := t.value()
+	if len(elem) == 0 {
+		return nil
+	}
+	if s, ok := elem[0].(string); ok {
+		return []byte(s)
+	}
+	// Writing to a bytes.Buffer cannot fail for slices of fixed size values
+	_ = binary.Write(&buf, byteOrder, elem[0])
+	return buf.Bytes()
+}
+
+// ifdSize returns the number of bytes needed to store an IFD, including the
+// values which do not fit in the 4 byte value offset
+func ifdSize(tags Tags) uint32 {
+	size := uint32(2 + 12*len(tags) + 4)
+	for _, v := range tags {
+		if n := v.length * v.fType.bytes(); n > fourByte {
+			size += n + n%2
+		}
+	}
+	return size
+}
+
+// encodeIFD encodes an IFD which will be placed at offset in the file.
+//
+// Per the TIFF 6.0 Specification (p.15)
+//
+// The entries in an IFD must be sorted in ascending order by Tag. Values
+// which do not fit in the 4 byte Value Offset are written directly after
+// the IFD and begin on a word boundary.
+func encodeIFD(tags Tags, byteOrder binary.ByteOrder, offset uint32, next uint32) []byte {
+	keys := tags.Keys()
+	entries := make([]byte, 2, 2+12*len(keys)+4)
+	byteOrder.PutUint16(entries, uint16(len(keys)))
+
+	valueOffset := offset + uint32(2+12*len(keys)+4)
+	var values []byte
+	for _, k := range keys {
+		v := tags[k]
+		entry := make([]byte, 12)
+		byteOrder.PutUint16(entry[0:], uint16(k))
+		byteOrder.PutUint16(entry[2:], uint16(v.fType))
+		byteOrder.PutUint32(entry[4:], v.length)
+		data := v.bytes(byteOrder)
+		if len(data) <= fourByte {
+			copy(entry[8:], data)
+		} else {
+			byteOrder.PutUint32(entry[8:], valueOffset+uint32(len(values)))
+			values = append(values, data...)
+			if len(data)%2 != 0 {
+				values = append(values, 0)
+			}
+		}
+		entries = append(entries, entry...)
+	}
+	nextOffset := make([]byte, 4)
+	byteOrder.PutUint32(nextOffset, next)
+	entries = append(entries, nextOffset...)
+	return append(entries, values...)
+}
+
+// encodeHeader encodes the TIFF file header
+func encodeHeader(byteOrder binary.ByteOrder, iFDOffset uint32) []byte {
+	h := make([]byte, headerSize)
+	if byteOrder == binary.BigEndian {
+		binary.BigEndian.PutUint16(h, bigEndian)
+	} else {
+		binary.BigEndian.PutUint16(h, littleEndian)
+	}
+	byteOrder.PutUint16(h[2:], tiffIdentifier)
+	byteOrder.PutUint32(h[4:], iFDOffset)
+	return h
+}
+
+// imageTags returns the tags describing the GeoTIFF image, without the tile
+// or strip layout, which is added by encodeBlocks.
+//
+// Tags which are not structural, such as the GeoKeys and any private tags,
+// are carried over from the GeoTIFF unchanged.
+func (g *GeoTIFF) imageTags(opts *WriteOptions) (Tags, error) {
+	_, hasTiepoint := g.tags[ModelTiepoint]
+	_, hasTransformation := g.tags[ModelTransformation]
+	if !hasTiepoint && !hasTransformation {
+		return nil, fmt.Errorf("%w: missing %s", errGeoTIFFWrite, ModelTiepoint)
+	}
+
+	tags := make(Tags, len(g.tags)+len(structuralTags))
+	for k, v := range g.tags {
+		tags[k] = v
+	}
+	for _, k := range structuralTags {
+		delete(tags, k)
+	}
+
+	tags[ImageWidth] = shortTag(g.imageWidth)
+	tags[ImageLength] = shortTag(g.imageLength)
+	tags[BitsPerSample] = shortTag(32)
+	tags[Compression] = shortTag(uint16(opts.compression()))
+	tags[PhotometricInterpretation] = shortTag(uint16(blackIsZero))
+	tags[SamplesPerPixel] = shortTag(1)
+	tags[PlanarConfiguration] = shortTag(1)
+	tags[SampleFormat] = shortTag(sampleFormatIEEEFP)
+	tags[ModelPixelScale] = doubleTag(g.PixelScaleX, g.PixelScaleY, 0)
+	if opts.predictor() != NoPredictor {
+		tags[Predictor] = shortTag(uint16(opts.predictor()))
+	}
+	return tags, nil
+}
+
+// encodeBlocks encodes the image data as tiles or strips, returning the
+// encoded blocks and adding the layout tags to tags.
+//
+// The offsets tag is filled with zeros, as the offsets depend on where the
+// caller places the blocks in the file.
+func (g *GeoTIFF) encodeBlocks(tags Tags, opts *WriteOptions) ([][]byte, error) {
+	var blocks [][]float32
+	var rowWidth int
+	if opts.strips() {
+		// Per the TIFF 6.0 Specification (p.39)
+		//
+		// The last strip only contains the remaining rows of the image
+		raster := g.raster()
+		rows := opts.RowsPerStrip
+		rowWidth = int(g.imageWidth)
+		for y := 0; y < int(g.imageLength); y += rows {
+			end := y + rows
+			if end > int(g.imageLength) {
+				end = int(g.imageLength)
+			}
+			blocks = append(blocks, raster[y*rowWidth:end*rowWidth])
+		}
+		tags[RowsPerStrip] = shortTag(uint16(rows))
+	} else {
+		w, l := opts.tileSize(g)
+		blocks = g.data
+		if w != int(g.tileWidth) || l != int(g.tileLength) {
+			blocks = tile(g.raster(), int(g.imageWidth), int(g.imageLength), w, l)
+		}
+		rowWidth = w
+		tags[TileWidth] = shortTag(uint16(w))
+		tags[TileLength] = shortTag(uint16(l))
+	}
+
+	encoded := make([][]byte, len(blocks))
+	counts := make([]uint32, len(blocks))
+	for i, b := range blocks {
+		e, err := encodeBlock(b, rowWidth, opts.byteOrder(), opts)
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = e
+		counts[i] = uint32(len(e))
+	}
+
+	offsets, byteCounts := TileOffsets, TileByteCounts
+	if opts.strips() {
+		offsets, byteCounts = StripOffsets, StripByteCounts
+	}
+	tags[offsets] = longTag(make([]uint32, len(blocks))...)
+	tags[byteCounts] = longTag(counts...)
+	return encoded, nil
+}
+
+// blockOffsets returns the offsets of the tiles or strips, which are filled
+// in after the layout of the file is known
+func blockOffsets(tags Tags) []uint32 {
+	if v, ok := tags[StripOffsets]; ok {
+		return v.longData
+	}
+	return tags[TileOffsets].longData
+}
+
+// Write encodes the GeoTIFF as a tiled or stripped 32 bit floating point TIFF
+//
+// The file is written sequentially as the header, the IFD, and then the tile
+// or strip data, so w does not need to support seeking. The ModelPixelScale
+// is taken from PixelScaleX and PixelScaleY, while the ModelTiepoint, GeoKeys
+// and any other non structural tags are copied from the GeoTIFF.
+//
+// opts may be nil, in which case the defaults are used.
+func Write(w io.Writer, g *GeoTIFF, opts *WriteOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	byteOrder := opts.byteOrder()
+	tags, err := g.imageTags(opts)
+	if err != nil {
+		return err
+	}
+	blocks, err := g.encodeBlocks(tags, opts)
+	if err != nil {
+		return err
+	}
+
+	// The offsets have the same size regardless of their value, so the IFD
+	// size can be computed before the offsets are filled in
+	offsets := blockOffsets(tags)
+	dataOffset := uint64(headerSize) + uint64(ifdSize(tags))
+	for i, b := range blocks {
+		if dataOffset+uint64(len(b)) > math.MaxUint32 {
+			return fmt.Errorf("%w: file exceeds the 4GB TIFF limit", errGeoTIFFWrite)
+		}
+		offsets[i] = uint32(dataOffset)
+		dataOffset += uint64(len(b))
+	}
+
+	if _, err := w.Write(encodeHeader(byteOrder, headerSize)); err != nil {
+		return fmt.Errorf("%w: %s", errGeoTIFFWrite, err)
+	}
+	if _, err := w.Write(encodeIFD(tags, byteOrder, headerSize, 0)); err != nil {
+		return fmt.Errorf("%w: %s", errGeoTIFFWrite, err)
+	}
+	for _, b := range blocks {
+		if _, err := w.Write(b); err != nil {
+			return fmt.Errorf("%w: %s", errGeoTIFFWrite, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package geotiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Write_RoundTrip(t *testing.T) {
+	r, err := os.Open(testfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	want, err := Read(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, byteOrder := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		t.Run(byteOrder.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, want, &WriteOptions{ByteOrder: byteOrder}); err != nil {
+				t.Fatal(err)
+			}
+
+			h, err := readHeader(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h.byteOrder != byteOrder {
+				t.Errorf("got byte order %s want %s", h.byteOrder, byteOrder)
+			}
+
+			got, err := Read(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkSameGeoTIFF(t, got, want)
+		})
+	}
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "out.tif")
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Write(f, want, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err = os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		got, err := Read(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSameGeoTIFF(t, got, want)
+	})
+}
+
+func Test_Write_Sad(t *testing.T) {
+	tiles := [][]float32{make([]float32, 16*16)}
+
+	t.Run("missing tiepoint", func(t *testing.T) {
+		g, err := New(tiles, 16, 16, 16, 16, 1, 1, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Write(&bytes.Buffer{}, g, nil); err == nil {
+			t.Fail()
+		}
+	})
+
+	t.Run("tile size not a multiple of 16", func(t *testing.T) {
+		g, err := New(tiles, 16, 16, 16, 16, 1, 1,
+			Tags{ModelTiepoint: doubleTag(0, 0, 0, 135, -20, 0)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Write(&bytes.Buffer{}, g, &WriteOptions{TileWidth: 20}); err == nil {
+			t.Fail()
+		}
+	})
+
+	t.Run("predictor without compression", func(t *testing.T) {
+		g, err := New(tiles, 16, 16, 16, 16, 1, 1,
+			Tags{ModelTiepoint: doubleTag(0, 0, 0, 135, -20, 0)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Write(&bytes.Buffer{}, g, &WriteOptions{Predictor: HorizontalPredictor}); err == nil {
+			t.Fail()
+		}
+	})
+}
+
+// checkSameGeoTIFF compares the dimensions, georeferencing, tags and pixel
+// values of two GeoTIFFs
+func checkSameGeoTIFF(t *testing.T, got *GeoTIFF, want *GeoTIFF) {
+	t.Helper()
+	if got.imageWidth != want.imageWidth || got.imageLength != want.imageLength {
+		t.Fatalf("got size %dx%d want %dx%d", got.imageWidth, got.imageLength, want.imageWidth, want.imageLength)
+	}
+	if got.PixelScaleX != want.PixelScaleX || got.PixelScaleY != want.PixelScaleY {
+		t.Errorf("got pixel scale %f, %f want %f, %f", got.PixelScaleX, got.PixelScaleY, want.PixelScaleX, want.PixelScaleY)
+	}
+
+	for _, k := range []Tag{ModelTiepoint, GeoKeyDirectory, GeoDoubleParams, GeoASCIIParams, SampleFormat} {
+		wantV, ok := want.tags[k]
+		if !ok {
+			continue
+		}
+		if got.tags[k].String() != wantV.String() {
+			t.Errorf("tag %s: got %s want %s", k, got.tags[k], wantV)
+		}
+	}
+
+	gotBounds, err := got.Bounds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBounds, err := want.Bounds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *gotBounds != *wantBounds {
+		t.Errorf("got bounds %s want %s", gotBounds, wantBounds)
+	}
+
+	for y := 0; y < int(want.imageLength); y++ {
+		for x := 0; x < int(want.imageWidth); x++ {
+			gv, _ := got.loc(x, y)
+			wv, _ := want.loc(x, y)
+			if gv != wv {
+				t.Fatalf("got %f want %f at (%d, %d)", gv, wv, x, y)
+			}
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package geotiff
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var errZonal = errors.New("unable to compute zonal statistics")
+
+// earthRadiusInMetres is the mean radius of the earth
+//
+// https://en.wikipedia.org/wiki/Earth_radius#Mean_radius
+const earthRadiusInMetres = 6371008.8
+
+// ZonalOptions configures ZonalStats
+type ZonalOptions struct {
+	// CRS is the coordinate reference system of the feature coordinates.
+	//
+	// Defaults to WGS 84, as required by RFC 7946
+	CRS *CRS
+
+	// AllTouched includes every pixel touched by a polygon, rather than only
+	// the pixels whose centre is inside it
+	AllTouched bool
+
+	// AreaWeighted weights each pixel by its area on the ground when
+	// computing the mean, standard deviation and majority. Pixels on
+	// geographic grids cover less ground towards the poles, on projected
+	// grids every pixel has the same weight.
+	AreaWeighted bool
+}
+
+// ZoneStats are the statistics of the pixels inside a zone
+//
+// Min, Max, Mean, StdDev and Majority are NaN if the zone has no valid
+// pixels.
+type ZoneStats struct {
+	Count    int     // number of valid pixels
+	NoData   int     // number of nodata pixels
+	Min      float64 // minimum value
+	Max      float64 // maximum value
+	Mean     float64 // mean value
+	StdDev   float64 // population standard deviation
+	Sum      float64 // sum of the values
+	Majority float64 // most common value, ties are broken by the smallest value
+	Area     float64 // area of the valid pixels in square metres
+}
+
+func (zs ZoneStats) String() string {
+	return fmt.Sprintf("Count=%d, NoData=%d, Minimum=%.3f, Maximum=%.3f, Mean=%.3f, StdDev=%.3f, Sum=%.3f, Majority=%.3f, Area=%.3f",
+		zs.Count, zs.NoData, zs.Min, zs.Max, zs.Mean, zs.StdDev, zs.Sum, zs.Majority, zs.Area)
+}
+
+// ZonalStats returns the statistics of the pixels inside each of the
+// Polygon or MultiPolygon features
+//
+// Polygon holes are excluded from the zones. By default a pixel is inside a
+// polygon if its centre is, see ZonalOptions to change this and to weight
+// pixels by their area. opts may be nil.
+func (g *GeoTIFF) ZonalStats(features []Feature, opts *ZonalOptions) ([]ZoneStats, error) {
+	if opts == nil {
+		opts = &ZonalOptions{}
+	}
+	from := opts.CRS
+	if from == nil {
+		wgs84, err := CRSFromEPSG(epsgWGS84)
+		if err != nil {
+			return nil, err
+		}
+		from = wgs84
+	}
+	to, err := g.CRS()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errZonal, err)
+	}
+	bounds, err := g.Bounds()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errZonal, err)
+	}
+
+	raster := g.raster()
+	nd := g.noData()
+	stats := make([]ZoneStats, 0, len(features))
+	for n, f := range features {
+		if f.Geometry == nil {
+			return nil, fmt.Errorf("%w: feature %d has no geometry", errZonal, n)
+		}
+		polygons, err := f.Geometry.polygons()
+		if err != nil {
+			return nil, fmt.Errorf("%w: feature %d: %s", errZonal, n, err)
+		}
+
+		// Rings are converted to pixel coordinates of the image
+		rings := make([][][]Point, len(polygons))
+		for i, polygon := range polygons {
+			for _, ring := range polygon {
+				pixels := make([]Point, 0, len(ring))
+				for _, pos := range ring {
+					if len(pos) < 2 {
+						return nil, fmt.Errorf("%w: feature %d: %s", errZonal, n, errGeoJSON)
+					}
+					p, err := Transform(pos.point(), from, to)
+					if err != nil {
+						return nil, fmt.Errorf("%w: feature %d: %s", errZonal, n, err)
+					}
+					pixels = append(pixels, Point{
+						Lon: (p.Lon - bounds.UpperLeft.Lon) / g.PixelScaleX,
+						Lat: (bounds.UpperLeft.Lat - p.Lat) / g.PixelScaleY,
+					})
+				}
+				rings[i] = append(rings[i], pixels)
+			}
+		}
+
+		m := g.rasterize(rings, opts.AllTouched)
+		var z zone
+		for j := m.y0; j < m.y1; j++ {
+			area := g.pixelArea(to, bounds, j)
+			weight := 1.0
+			if opts.AreaWeighted {
+				weight = area
+			}
+			for i := m.x0; i < m.x1; i++ {
+				if !m.at(i, j) {
+					continue
+				}
+				v := raster[j*int(g.imageWidth)+i]
+				if nd.is(v) || math.IsInf(float64(v), 0) {
+					z.noData++
+					continue
+				}
+				z.add(float64(v), weight, area)
+			}
+		}
+		stats = append(stats, z.stats())
+	}
+	return stats, nil
+}
+
+// pixelArea returns the area in square metres of the pixels in row j
+func (g *GeoTIFF) pixelArea(crs *CRS, bounds *CornerCoordinates, j int) float64 {
+	if !crs.Geographic() {
+		return g.PixelScaleX * g.PixelScaleY
+	}
+	// The area of a cell between two meridians and two parallels on a sphere
+	d2R := math.Pi / 180
+	top := bounds.UpperLeft.Lat - float64(j)*g.PixelScaleY
+	bottom := top - g.PixelScaleY
+	return earthRadiusInMetres * earthRadiusInMetres * g.PixelScaleX * d2R *
+		math.Abs(math.Sin(top*d2R)-math.Sin(bottom*d2R))
+}
+
+// zone accumulates the statistics of the pixels in a zone
+type zone struct {
+	count  int
+	noData int
+	min    float64
+	max    float64
+	sum    float64
+	area   float64
+	// Weighted mean and sum of squared differences, following West (1979)
+	weight float64
+	mean   float64
+	m2     float64
+	values map[float64]float64
+}
+
+func (z *zone) add(v float64, weight float64, area float64) {
+	if z.count == 0 {
+		z.min, z.max = v, v
+		z.values = make(map[float64]float64)
+	}
+	z.count++
+	z.min, z.max = math.Min(z.min, v), math.Max(z.max, v)
+	z.sum += v
+	z.area += area
+	z.weight += weight
+	delta := v - z.mean
+	z.mean += weight / z.weight * delta
+	z.m2 += weight * delta * (v - z.mean)
+	z.values[v] += weight
+}
+
+func (z *zone) stats() ZoneStats {
+	s := ZoneStats{Count: z.count, NoData: z.noData, Sum: z.sum, Area: z.area}
+	if z.count == 0 {
+		nan := math.NaN()
+		s.Min, s.Max, s.Mean, s.StdDev, s.Majority = nan, nan, nan, nan, nan
+		return s
+	}
+	s.Min, s.Max, s.Mean = z.min, z.max, z.mean
+	s.StdDev = math.Sqrt(math.Max(z.m2/z.weight, 0))
+
+	values := make([]float64, 0, len(z.values))
+	for v := range z.values {
+		values = append(values, v)
+	}
+	sort.Float64s(values)
+	best := -1.0
+	for _, v := range values {
+		if w := z.values[v]; w > best {
+			s.Majority, best = v, w
+		}
+	}
+	return s
+}
+
+// mask marks the pixels inside a window of the image
+type mask struct {
+	x0, y0 int
+	x1, y1 int
+	inside []bool
+}
+
+func (m *mask) at(i int, j int) bool {
+	return m.inside[(j-m.y0)*(m.x1-m.x0)+i-m.x0]
+}
+
+func (m *mask) set(i int, j int) {
+	if i >= m.x0 && i < m.x1 && j >= m.y0 && j < m.y1 {
+		m.inside[(j-m.y0)*(m.x1-m.x0)+i-m.x0] = true
+	}
+}
+
+// rasterize marks the pixels inside polygons, given as rings in pixel
+// coordinates with the first ring of each polygon its exterior and the
+// rest its holes
+//
+// Pixels are inside a polygon if their centre is, following the even-odd
+// rule, or if allTouched is set, if any part of them is.
+func (g *GeoTIFF) rasterize(polygons [][][]Point, allTouched bool) *mask {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, polygon := range polygons {
+		for _, ring := range polygon {
+			for _, p := range ring {
+				minX, maxX = math.Min(minX, p.Lon), math.Max(maxX, p.Lon)
+				minY, maxY = math.Min(minY, p.Lat), math.Max(maxY, p.Lat)
+			}
+		}
+	}
+	m := &mask{}
+	if len(polygons) > 0 && minX <= maxX && minY <= maxY {
+		m.x0 = clamp(int(math.Floor(minX)), 0, int(g.imageWidth))
+		m.x1 = clamp(int(math.Floor(maxX))+1, 0, int(g.imageWidth))
+		m.y0 = clamp(int(math.Floor(minY)), 0, int(g.imageLength))
+		m.y1 = clamp(int(math.Floor(maxY))+1, 0, int(g.imageLength))
+	}
+	m.inside = make([]bool, (m.x1-m.x0)*(m.y1-m.y0))
+	if len(m.inside) == 0 {
+		return m
+	}
+
+	crossings := make([]float64, 0, 16)
+	for _, polygon := range polygons {
+		for j := m.y0; j < m.y1; j++ {
+			yc := float64(j) + 0.5
+			crossings = crossings[:0]
+			for _, ring := range polygon {
+				for k := range ring {
+					a, b := ring[k], ring[(k+1)%len(ring)]
+					if (a.Lat <= yc) != (b.Lat <= yc) {
+						crossings = append(crossings, a.Lon+(yc-a.Lat)*(b.Lon-a.Lon)/(b.Lat-a.Lat))
+					}
+				}
+			}
+			sort.Float64s(crossings)
+			for k := 0; k+1 < len(crossings); k += 2 {
+				first := int(math.Ceil(crossings[k] - 0.5))
+				last := int(math.Ceil(crossings[k+1]-0.5)) - 1
+				for i := clamp(first, m.x0, m.x1); i <= last && i < m.x1; i++ {
+					m.set(i, j)
+				}
+			}
+		}
+
+		if allTouched {
+			for _, ring := range polygon {
+				for k := range ring {
+					a, b := ring[k], ring[(k+1)%len(ring)]
+					traverse(a, b, m.set)
+				}
+			}
+		}
+	}
+	return m
+}
+
+// traverse visits every pixel a line segment in pixel coordinates passes
+// through
+//
+// See J. Amanatides and A. Woo (1987), A Fast Voxel Traversal Algorithm for
+// Ray Tracing
+func traverse(a Point, b Point, visit func(i int, j int)) {
+	i, j := int(math.Floor(a.Lon)), int(math.Floor(a.Lat))
+	iEnd, jEnd := int(math.Floor(b.Lon)), int(math.Floor(b.Lat))
+	dx, dy := b.Lon-a.Lon, b.Lat-a.Lat
+
+	step := func(d float64, start float64) (int, float64, float64) {
+		switch {
+		case d > 0:
+			return 1, (math.Floor(start) + 1 - start) / d, 1 / d
+		case d < 0:
+			return -1, (start - math.Floor(start)) / -d, 1 / -d
+		}
+		return 0, math.Inf(1), math.Inf(1)
+	}
+	stepX, tMaxX, tDeltaX := step(dx, a.Lon)
+	stepY, tMaxY, tDeltaY := step(dy, a.Lat)
+
+	visit(i, j)
+	steps := abs(iEnd-i) + abs(jEnd-j)
+	for n := 0; n < steps; n++ {
+		if tMaxX < tMaxY {
+			tMaxX += tDeltaX
+			i += stepX
+		} else {
+			tMaxY += tDeltaY
+			j += stepY
+		}
+		visit(i, j)
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,168 @@
+package geotiff
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+// square returns a polygon feature covering minX, minY to maxX, maxY with
+// optional holes
+func square(minX float64, minY float64, maxX float64, maxY float64, holes ...[]Position) Feature {
+	rings := [][]Position{{{minX, minY}, {maxX, minY}, {maxX, maxY}, {minX, maxY}, {minX, minY}}}
+	rings = append(rings, holes...)
+	return NewFeature(&Geometry{Type: GeometryPolygon, Coordinates: rings}, nil)
+}
+
+func Test_ZonalStats_Happy(t *testing.T) {
+	// rampGeoTIFF holds x + 10 * y in 0.1 degree pixels from 135, -20
+	g := rampGeoTIFF(t, 10, 10)
+
+	t.Run("centre in", func(t *testing.T) {
+		stats, err := g.ZonalStats([]Feature{square(135.2, -20.6, 135.5, -20.3)}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := stats[0]
+		if got.Count != 9 || got.NoData != 0 {
+			t.Fatalf("got count %d nodata %d want 9, 0", got.Count, got.NoData)
+		}
+		if got.Min != 32 || got.Max != 54 || got.Sum != 387 || got.Majority != 32 {
+			t.Errorf("got %s", got)
+		}
+		if !checkToTolerance(got.Mean, 43, 1e-9) {
+			t.Errorf("got mean %v want 43", got.Mean)
+		}
+		if want := math.Sqrt(202.0 / 3); !checkToTolerance(got.StdDev, want, 1e-9) {
+			t.Errorf("got standard deviation %v want %v", got.StdDev, want)
+		}
+	})
+
+	t.Run("all touched", func(t *testing.T) {
+		f := square(135.26, -20.54, 135.44, -20.36)
+		tests := []struct {
+			allTouched bool
+			count      int
+			mean       float64
+		}{
+			{false, 1, 43},
+			{true, 9, 43},
+		}
+		for _, tt := range tests {
+			stats, err := g.ZonalStats([]Feature{f}, &ZonalOptions{AllTouched: tt.allTouched})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats[0].Count != tt.count || !checkToTolerance(stats[0].Mean, tt.mean, 1e-9) {
+				t.Errorf("all touched %v: got %s want count %d mean %v", tt.allTouched, stats[0], tt.count, tt.mean)
+			}
+		}
+	})
+
+	t.Run("holes and multipolygons", func(t *testing.T) {
+		hole := []Position{{135.3, -20.4}, {135.3, -20.3}, {135.4, -20.3}, {135.4, -20.4}, {135.3, -20.4}}
+		multi := NewFeature(&Geometry{Type: GeometryMultiPolygon, Coordinates: [][][]Position{
+			{{{135, -20.1}, {135.1, -20.1}, {135.1, -20}, {135, -20}, {135, -20.1}}},
+			{{{135.9, -21}, {136, -21}, {136, -20.9}, {135.9, -20.9}, {135.9, -21}}},
+		}}, nil)
+		stats, err := g.ZonalStats([]Feature{square(135.1, -20.6, 135.6, -20.1, hole), multi}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats[0].Count != 24 || stats[0].Sum != 25*33-33 {
+			t.Errorf("hole: got %s want count 24 sum %d", stats[0], 25*33-33)
+		}
+		if stats[1].Count != 2 || stats[1].Min != 0 || stats[1].Max != 99 {
+			t.Errorf("multipolygon: got %s want count 2 min 0 max 99", stats[1])
+		}
+	})
+
+	t.Run("area weighted", func(t *testing.T) {
+		// Pixels further south cover less ground, and hold larger values
+		f := square(135, -21, 135.1, -20)
+		plain, err := g.ZonalStats([]Feature{f}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		weighted, err := g.ZonalStats([]Feature{f}, &ZonalOptions{AreaWeighted: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plain[0].Mean != 45 || !(weighted[0].Mean < 45 && weighted[0].Mean > 44.9) {
+			t.Errorf("got mean %v and weighted mean %v", plain[0].Mean, weighted[0].Mean)
+		}
+		if plain[0].Sum != weighted[0].Sum {
+			t.Errorf("got sum %v and weighted sum %v", plain[0].Sum, weighted[0].Sum)
+		}
+		d2R := math.Pi / 180
+		area := earthRadiusInMetres * earthRadiusInMetres * 0.1 * d2R * (math.Sin(20*d2R) - math.Sin(21*d2R)) * -1
+		if !checkToTolerance(weighted[0].Area, area, 1) {
+			t.Errorf("got area %v want %v", weighted[0].Area, area)
+		}
+	})
+
+	t.Run("features in another CRS", func(t *testing.T) {
+		wgs84, _ := CRSFromEPSG(4326)
+		webMercator, _ := CRSFromEPSG(3857)
+		ll, _ := Transform(Point{Lon: 135.2, Lat: -20.6}, wgs84, webMercator)
+		ur, _ := Transform(Point{Lon: 135.5, Lat: -20.3}, wgs84, webMercator)
+		f := square(ll.Lon, ll.Lat, ur.Lon, ur.Lat)
+		stats, err := g.ZonalStats([]Feature{f}, &ZonalOptions{CRS: webMercator})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats[0].Count != 9 || stats[0].Sum != 387 {
+			t.Errorf("got %s want count 9 sum 387", stats[0])
+		}
+	})
+
+	t.Run("nodata and empty zones", func(t *testing.T) {
+		g := rampGeoTIFF(t, 10, 10)
+		g.SetNoData(43)
+		stats, err := g.ZonalStats([]Feature{square(135.2, -20.6, 135.5, -20.3), square(140, -30, 141, -29)}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats[0].Count != 8 || stats[0].NoData != 1 || stats[0].Sum != 387-43 {
+			t.Errorf("got %s want count 8 nodata 1 sum %d", stats[0], 387-43)
+		}
+		if stats[1].Count != 0 || !math.IsNaN(stats[1].Mean) || stats[1].Sum != 0 {
+			t.Errorf("got %s want an empty zone", stats[1])
+		}
+	})
+
+	t.Run("decoded GeoJSON", func(t *testing.T) {
+		var fc FeatureCollection
+		b := []byte(`{"type": "FeatureCollection", "features": [{"type": "Feature", "properties": {"name": "a"},
+			"geometry": {"type": "Polygon", "coordinates": [[[135.2, -20.6], [135.5, -20.6], [135.5, -20.3], [135.2, -20.3], [135.2, -20.6]]]}}]}`)
+		if err := json.Unmarshal(b, &fc); err != nil {
+			t.Fatal(err)
+		}
+		stats, err := g.ZonalStats(fc.Features, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats[0].Count != 9 || stats[0].Sum != 387 {
+			t.Errorf("got %s want count 9 sum 387", stats[0])
+		}
+	})
+}
+
+func Test_ZonalStats_Sad(t *testing.T) {
+	g := rampGeoTIFF(t, 10, 10)
+	tests := []struct {
+		name    string
+		feature Feature
+	}{
+		{"no geometry", NewFeature(nil, nil)},
+		{"point", NewFeature(&Geometry{Type: GeometryPoint, Coordinates: Position{135, -20}}, nil)},
+		{"short position", NewFeature(&Geometry{Type: GeometryPolygon, Coordinates: [][]Position{{{135}, {136, -20}, {136, -21}}}}, nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := g.ZonalStats([]Feature{tt.feature}, nil); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}